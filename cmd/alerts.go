@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"Clash-tester/internal/alert"
+	"Clash-tester/internal/notifier"
+	"Clash-tester/pkg/models"
+)
+
+// evaluateAlerts 评估告警规则，输出到控制台并通过通知渠道发送
+// 没有测试结果或判定为全局故障时不评估，避免把本机故障记为节点数的变化
+func evaluateAlerts(opts cliOptions, report, previous *models.TestReport) {
+	if report == nil || report.TestedNodes == 0 || (report.Canary != nil && report.Canary.GlobalOutage) {
+		return
+	}
+
+	st, err := alert.LoadState(opts.AlertState)
+	if err != nil {
+		log.Printf("⚠️  Failed to load alert state, starting fresh: %v", err)
+		st = &alert.State{Rules: make(map[string]*alert.RuleState)}
+	}
+
+	alerts := opts.Alerts.Evaluate(report, previous, st, time.Now())
+	for _, a := range alerts {
+		fmt.Println(a.Message)
+		if opts.Notifier == nil {
+			continue
+		}
+		title := fmt.Sprintf("[%s] %s", a.Severity, a.Rule)
+		if a.Resolved {
+			title = fmt.Sprintf("[resolved] %s", a.Rule)
+		}
+		opts.Notifier.Send(context.Background(), notifier.Event{
+			Type:     notifier.EventAlert,
+			Time:     a.Time,
+			Title:    title,
+			Message:  a.Message,
+			Report:   report,
+			Rule:     a.Rule,
+			Severity: a.Severity,
+			Resolved: a.Resolved,
+		})
+	}
+
+	if err := st.Save(opts.AlertState); err != nil {
+		log.Printf("⚠️  Failed to save alert state: %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"Clash-tester/internal/config"
+	"Clash-tester/pkg/clashtester"
+	"Clash-tester/pkg/models"
+)
+
+// command 一个子命令
+type command struct {
+	Name    string
+	Aliases []string
+	Args    string // 用法中参数部分，如 "[flags] <node name>"
+	Summary string // 一行说明，显示在命令列表中
+	Help    string // 详细说明，显示在子命令的帮助中
+	Run     func(args []string) int
+}
+
+// commands 全部子命令，按帮助中的显示顺序排列
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{
+			Name:    "test",
+			Args:    "[flags]",
+			Summary: "Test all nodes of a subscription once (default when no command is given)",
+			Help:    "Test all nodes of a subscription once, write the reports and exit.\nSettings are read from defaults, the -config file, CLASH_TESTER_* environment variables and flags, in increasing priority.",
+			Run:     runTest,
+		},
+		{
+			Name:    "list",
+			Args:    "[flags]",
+			Summary: "Print the parsed nodes of a subscription without testing them",
+			Help:    "Download and parse the subscription, apply the filter settings and print the nodes that would be tested.",
+			Run:     runList,
+		},
+		{
+			Name:    "validate",
+			Args:    "[flags] [config file]",
+			Summary: "Check a config file and the subscription it points to",
+			Help:    "Check a config file for unknown keys, type errors and invalid values, reporting each problem with its line number.\nWhen a source is configured, the subscription is also downloaded and parsed.",
+			Run:     runValidate,
+		},
+		{
+			Name:    "test-node",
+			Args:    "[flags] <node name>",
+			Summary: "Test a single node and print every check in detail",
+			Help:    "Test a single node by name and print status codes, timings and errors of every check.\nThe name is matched exactly first, then as a case-insensitive substring. No reports, tags.json, state or metrics are written.",
+			Run:     runTestNode,
+		},
+		{
+			Name:    "diff",
+			Args:    "[flags] <old.json> <new.json> | <output dir>",
+			Summary: "Compare two JSON reports",
+			Help:    "Compare two JSON reports and print added and removed nodes, services that became available or unavailable, and summary changes.\nGiven an output directory, the two most recent reports in it are compared.",
+			Run:     runDiff,
+		},
+		{
+			Name:    "fixture",
+			Args:    "[flags] <trace.json | file.har>",
+			Summary: "Turn captured checks into fixtures for the checker tests",
+			Help:    "Convert the trace.json of a test-node -debug bundle or a HAR file from -har-dir into replayable fixtures, one per check.\nThe expected result is taken from replaying the capture; correct it where the tester got it wrong, then run go test ./internal/tester.",
+			Run:     runFixture,
+		},
+		{
+			Name:    "serve",
+			Args:    "[flags]",
+			Summary: "Test on a schedule and serve reports and Prometheus metrics over HTTP",
+			Help:    "Run a test every -interval and serve the output directory (latest HTML report at /) and Prometheus metrics at /metrics.\nStops gracefully on SIGINT/SIGTERM.",
+			Run:     runServeCommand,
+		},
+		{
+			Name:    "daemon",
+			Args:    "[flags]",
+			Summary: "Test on a schedule without an HTTP listener",
+			Help:    "Run a test every -interval until SIGINT/SIGTERM, like the Docker cron loop but in a single process.",
+			Run:     runDaemon,
+		},
+	}
+}
+
+// runCommand 分派子命令
+// 第一个参数不是子命令 (为空或以 - 开头) 时按 test 处理，兼容旧的用法
+func runCommand(args []string) int {
+	if len(args) == 0 || (strings.HasPrefix(args[0], "-") && !isHelpArg(args[0])) {
+		return runTest(args)
+	}
+	if isHelpArg(args[0]) || args[0] == "help" {
+		if len(args) > 1 && args[0] == "help" {
+			if cmd := findCommand(args[1]); cmd != nil {
+				return cmd.Run([]string{"-h"})
+			}
+		}
+		printUsage(os.Stdout)
+		return exitOK
+	}
+
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+		printUsage(os.Stderr)
+		return exitError
+	}
+	return cmd.Run(args[1:])
+}
+
+func isHelpArg(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd
+		}
+		for _, alias := range cmd.Aliases {
+			if alias == name {
+				return cmd
+			}
+		}
+	}
+	return nil
+}
+
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// printUsage 输出命令列表
+func printUsage(w *os.File) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n\nCommands:\n", programName())
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.Name, cmd.Summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s <command> -h' for the flags of a command.\n", programName())
+	fmt.Fprintf(w, "Flags without a command run 'test', e.g. '%s -source sub.yaml'.\n", programName())
+}
+
+// newFlagSet 创建子命令的参数集，-h 时输出该命令的说明与参数
+func newFlagSet(name string) *flag.FlagSet {
+	cmd := findCommand(name)
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: %s %s %s\n\n%s\n\nFlags:\n", programName(), cmd.Name, cmd.Args, cmd.Help)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseSettings 依次应用 默认值、配置文件、环境变量、命令行参数
+// bind 注册该命令需要的参数，参数直接写回 settings
+func parseSettings(fs *flag.FlagSet, args []string, bind func(*flag.FlagSet, *config.Settings)) (config.Settings, error) {
+	configPath := findConfigPath(args)
+	settings, issues, err := loadSettings(configPath)
+	if err != nil {
+		return settings, fmt.Errorf("invalid -config: %w", err)
+	}
+	if len(issues) > 0 {
+		for _, issue := range issues {
+			fmt.Fprintln(os.Stderr, formatIssue(configPath, issue))
+		}
+		return settings, fmt.Errorf("invalid -config: %d problem(s) found", len(issues))
+	}
+
+	fs.String("config", "", "YAML config file covering all settings (env: "+configEnv+")")
+	bind(fs, &settings)
+	fs.Parse(args)
+	return settings, nil
+}
+
+// loadNodes 下载并解析订阅，返回受支持的全部节点
+// 错误附带 exitSourceUnreachable 退出码
+func loadNodes(ctx context.Context, source string, timeout time.Duration) ([]models.ProxyNode, error) {
+	nodes, err := clashtester.LoadNodes(ctx, source, timeout)
+	if err != nil {
+		return nil, withExitCode(exitSourceUnreachable, err)
+	}
+	return nodes, nil
+}
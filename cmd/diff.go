@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"Clash-tester/internal/reporter"
+)
+
+// runDiff 实现 diff 命令：比较两份 JSON 报告
+func runDiff(args []string) int {
+	fs := newFlagSet("diff")
+	asJSON := fs.Bool("json", false, "Print the differences as JSON")
+	exitCode := fs.Bool("exit-code", false, "Exit with code 1 when nodes or services changed")
+	fs.Parse(args)
+
+	var oldPath, newPath string
+	switch fs.NArg() {
+	case 1:
+		reports, err := reporter.ListJSONReports(fs.Arg(0))
+		if err != nil {
+			log.Printf("❌ %v", err)
+			return exitError
+		}
+		if len(reports) < 2 {
+			log.Printf("❌ Need at least two reports in %s, found %d", fs.Arg(0), len(reports))
+			return exitError
+		}
+		oldPath, newPath = reports[len(reports)-2], reports[len(reports)-1]
+	case 2:
+		var err error
+		if oldPath, err = resolveReport(fs.Arg(0)); err == nil {
+			newPath, err = resolveReport(fs.Arg(1))
+		}
+		if err != nil {
+			log.Printf("❌ %v", err)
+			return exitError
+		}
+	default:
+		fs.Usage()
+		return exitError
+	}
+
+	oldReport, err := reporter.LoadJSON(oldPath)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return exitError
+	}
+	newReport, err := reporter.LoadJSON(newPath)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return exitError
+	}
+
+	d := reporter.DiffReports(oldReport, newReport)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(d); err != nil {
+			log.Printf("❌ %v", err)
+			return exitError
+		}
+	} else {
+		fmt.Printf("--- %s\n+++ %s\n", oldPath, newPath)
+		reporter.PrintDiff(os.Stdout, d)
+	}
+
+	if *exitCode && !d.Empty() {
+		return exitError
+	}
+	return exitOK
+}
+
+// resolveReport 参数为目录时取其中最新的报告
+func resolveReport(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return path, err
+	}
+	reports, err := reporter.ListJSONReports(path)
+	if err != nil {
+		return "", err
+	}
+	if len(reports) == 0 {
+		return "", fmt.Errorf("no reports found in %s", path)
+	}
+	return reports[len(reports)-1], nil
+}
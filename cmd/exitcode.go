@@ -0,0 +1,39 @@
+package main
+
+import "errors"
+
+// 退出码，供 Cron 脚本区分失败原因
+const (
+	exitOK                = 0
+	exitError             = 1 // 其他错误 (参数错误、写入失败等)
+	exitSourceUnreachable = 2 // 订阅源无法获取或解析
+	exitCoreStartup       = 3 // 代理核心启动失败
+	exitQualityGate       = 4 // 未通过质量检查，结果未发布
+	exitPartial           = 5 // 部分节点未测试 (中断、超时或切换失败)，结果已发布
+)
+
+// codedError 携带退出码的错误
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+// withExitCode 为错误附加退出码
+func withExitCode(code int, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+// exitCodeOf 取出错误对应的退出码
+func exitCodeOf(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var ce *codedError
+	if errors.As(err, &ce) {
+		return ce.code
+	}
+	return exitError
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"Clash-tester/internal/fsutil"
+	"Clash-tester/internal/tester"
+)
+
+// runFixture 实现 fixture 命令：将 test-node -debug 的 trace.json 或 -har-dir 的 HAR 文件转换为回放用的 fixture
+// 每次检测保存为 <output>/<服务>/<name>.json，已存在时追加序号
+func runFixture(args []string) int {
+	fs := newFlagSet("fixture")
+	output := fs.String("o", filepath.Join("internal", "tester", "testdata", "fixtures"), "Directory of the fixture corpus")
+	service := fs.String("service", "", "Only convert checks of this service")
+	name := fs.String("name", "capture", "Base file name of the fixtures")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+
+	fixtures, err := readCaptures(fs.Arg(0))
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return exitError
+	}
+
+	saved := 0
+	for _, f := range fixtures {
+		if *service != "" && f.Service != *service {
+			continue
+		}
+		path, err := saveFixture(*output, *name, f)
+		if err != nil {
+			log.Printf("❌ %v", err)
+			return exitError
+		}
+		status := "unavailable"
+		if f.Expect.Available {
+			status = "available"
+		}
+		fmt.Printf("💾 %s (%s, %d exchanges)\n", path, status, len(f.Exchanges))
+		saved++
+	}
+	if saved == 0 {
+		log.Printf("❌ No checks found in %s", fs.Arg(0))
+		return exitError
+	}
+	fmt.Println("📝 The expect fields are taken from replaying the capture, correct them where the tester got it wrong")
+	return exitOK
+}
+
+// readCaptures 按内容区分 trace.json (检测记录数组) 与 HAR 文件
+func readCaptures(path string) ([]tester.Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var checks []tester.CheckRecord
+		if err := json.Unmarshal(data, &checks); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return tester.FixturesFromChecks(checks), nil
+	}
+
+	var har tester.HAR
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tester.FixturesFromHAR(har)
+}
+
+// saveFixture 保存到 dir/<服务>/ 下，不覆盖已有文件
+// HTML 正文中的 < > & 不转义，便于直接阅读和编辑
+func saveFixture(dir, name string, f tester.Fixture) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return "", err
+	}
+
+	dir = filepath.Join(dir, f.Service)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fsutil.SafeName(name)+".json")
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d.json", fsutil.SafeName(name), i))
+	}
+	return path, fsutil.WriteFileAtomic(path, buf.Bytes(), 0644)
+}
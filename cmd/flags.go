@@ -0,0 +1,249 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"Clash-tester/internal/alert"
+	"Clash-tester/internal/config"
+	"Clash-tester/internal/notifier"
+	"Clash-tester/internal/reporter"
+	"Clash-tester/internal/state"
+	"Clash-tester/internal/ui"
+	"Clash-tester/pkg/clashtester"
+)
+
+// configEnv 未指定 -config 时读取的配置文件环境变量
+const configEnv = config.EnvPrefix + "CONFIG"
+
+// findConfigPath 在解析参数前找出 -config 的值，以便先加载配置文件再用命令行覆盖
+func findConfigPath(args []string) string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		if value, ok := strings.CutPrefix(name, "config="); ok {
+			return value
+		}
+		if name == "config" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return os.Getenv(configEnv)
+}
+
+// loadSettings 依次应用 默认值、配置文件、环境变量
+func loadSettings(path string) (config.Settings, []config.Issue, error) {
+	s := config.DefaultSettings()
+	if path != "" {
+		if err := config.LoadSettings(path, &s); err != nil {
+			if issues, ok := err.(config.IssuesError); ok {
+				return s, issues, nil
+			}
+			return s, nil, err
+		}
+	}
+	issues := config.ApplyEnv(&s, os.LookupEnv)
+	return s, issues, nil
+}
+
+// bindSourceFlags 注册订阅来源与节点过滤参数，默认值取自 s，解析结果直接写回 s
+func bindSourceFlags(fs *flag.FlagSet, s *config.Settings) {
+	fs.StringVar(&s.Source.URL, "source", s.Source.URL, "Subscription URL or local YAML file path")
+	fs.DurationVar(&s.Source.Timeout, "source-timeout", s.Source.Timeout, "Timeout for downloading the subscription")
+
+	fs.StringVar(&s.Filter.Include, "include", s.Filter.Include, "Only test nodes whose name matches this regexp")
+	fs.StringVar(&s.Filter.Exclude, "exclude", s.Filter.Exclude, "Skip nodes whose name matches this regexp")
+	listVar(fs, &s.Filter.Types, "types", "Comma-separated proxy types to test, e.g. vless,trojan (empty = all)")
+}
+
+// bindFlags 注册一次测试的全部参数
+func bindFlags(fs *flag.FlagSet, s *config.Settings) {
+	bindSourceFlags(fs, s)
+
+	listVar(fs, &s.Checks.Services, "services", "Comma-separated checks to run: openai, gemini, claude, netflix, disney, youtube, max (empty = all)")
+	fs.DurationVar(&s.Checks.Timeout, "check-timeout", s.Checks.Timeout, "Timeout of a single check request")
+	fs.IntVar(&s.Checks.Retries, "retries", s.Checks.Retries, "Retries of a failed check")
+	fs.StringVar(&s.Checks.SpeedTest.URL, "speedtest-url", s.Checks.SpeedTest.URL, "Download URL for the optional speed test (empty = disabled)")
+	fs.IntVar(&s.Checks.SpeedTest.MaxMB, "speedtest-max-mb", s.Checks.SpeedTest.MaxMB, "Maximum megabytes downloaded per node during speed test")
+	fs.DurationVar(&s.Checks.SpeedTest.Duration, "speedtest-duration", s.Checks.SpeedTest.Duration, "Maximum download time per node during speed test")
+	fs.BoolVar(&s.Checks.SpeedTest.UnlockedOnly, "speedtest-unlocked-only", s.Checks.SpeedTest.UnlockedOnly, "Only speed test nodes that passed at least one unlock check")
+
+	fs.IntVar(&s.Workers.Count, "workers", s.Workers.Count, "Number of concurrent workers")
+	fs.StringVar(&s.Workers.Core, "core", s.Workers.Core, "Proxy core used by the workers: mihomo, sing-box or xray")
+	fs.StringVar(&s.Workers.CorePath, "core-path", s.Workers.CorePath, "Path to the proxy core executable (default: -mihomo for mihomo, sing-box / xray in PATH)")
+	fs.StringVar(&s.Workers.Mihomo, "mihomo", s.Workers.Mihomo, "Path to mihomo executable")
+	fs.IntVar(&s.Workers.PortBase, "port-base", s.Workers.PortBase, "Proxy port of the first worker")
+	fs.IntVar(&s.Workers.PortStep, "port-step", s.Workers.PortStep, "Proxy port distance between workers")
+	fs.IntVar(&s.Workers.APIPortBase, "api-port-base", s.Workers.APIPortBase, "Controller API port of the first worker (one port per worker)")
+	fs.DurationVar(&s.Workers.NodeTimeout, "node-timeout", s.Workers.NodeTimeout, "Time budget for testing a single node (0 = unlimited)")
+
+	fs.DurationVar(&s.Run.Timeout, "run-timeout", s.Run.Timeout, "Maximum duration of the whole run (0 = unlimited)")
+	fs.DurationVar(&s.Run.ShutdownGrace, "shutdown-grace", s.Run.ShutdownGrace, "On SIGINT/SIGTERM, time allowed for in-flight nodes to finish before they are cancelled")
+	fs.StringVar(&s.Run.Checkpoint, "checkpoint", s.Run.Checkpoint, "Checkpoint file for completed node results (default: <output>/checkpoint.jsonl)")
+	fs.BoolVar(&s.Run.Resume, "resume", s.Run.Resume, "Resume an interrupted run from the checkpoint file, skipping already tested nodes")
+	fs.StringVar(&s.Run.State, "state", s.Run.State, "State file for incremental testing (empty = always test everything)")
+	fs.Func("ttl", "Per-service result TTLs for incremental testing, e.g. netflix=24h,disney=24h,openai=1h", func(v string) error {
+		ttls, err := state.ParseTTLs(v)
+		s.Run.TTL = ttls
+		return err
+	})
+	fs.DurationVar(&s.Run.DefaultTTL, "default-ttl", s.Run.DefaultTTL, "TTL for services not listed in -ttl (0 = always retest)")
+	fs.DurationVar(&s.Run.UnavailableTTL, "unavailable-ttl", s.Run.UnavailableTTL, "TTL for unavailable results, capped by the service's TTL (0 = always retest)")
+	fs.IntVar(&s.Run.Rounds, "rounds", s.Run.Rounds, "Run the check suite N times per node to measure stability")
+	fs.DurationVar(&s.Run.RoundWindow, "round-window", s.Run.RoundWindow, "Time window over which the rounds of each node are spread")
+	fs.StringVar(&s.Run.UI, "ui", s.Run.UI, "Progress display: auto (live dashboard on a terminal, plain lines otherwise), tui, plain")
+
+	fs.StringVar(&s.Output.Dir, "output", s.Output.Dir, "Output directory for detailed results")
+	listVar(fs, &s.Output.Formats, "format", "Comma-separated output formats: json, tags (requires -map-output), csv, md, html")
+	fs.StringVar(&s.Output.MapOutput, "map-output", s.Output.MapOutput, "Path to save tags.json (Map format for SubStore)")
+	fs.StringVar(&s.Output.MapPrevious, "map-previous", s.Output.MapPrevious, "Previous tags.json to carry forward results of untested nodes from (default: -map-output)")
+	fs.DurationVar(&s.Output.MapMaxAge, "map-max-age", s.Output.MapMaxAge, "Drop carried-forward tags.json entries older than this (0 = never expire)")
+	fs.BoolVar(&s.Output.MapBackup, "map-backup", s.Output.MapBackup, "Keep the previous tags.json as tags.json.bak before overwriting it")
+	fs.Float64Var(&s.Output.MapMinKeep, "map-min-keep", s.Output.MapMinKeep, "Refuse to overwrite tags.json if the new result has fewer entries than this fraction of the existing file (empty results are always refused)")
+	fs.BoolVar(&s.Output.MapForce, "map-force", s.Output.MapForce, "Overwrite tags.json even if -map-min-keep refuses it, e.g. after removing many nodes from the subscription on purpose")
+	fs.IntVar(&s.Output.HysteresisUp, "hysteresis-up", s.Output.HysteresisUp, "Consecutive available results needed before a service flips to available in tags.json")
+	fs.IntVar(&s.Output.HysteresisDown, "hysteresis-down", s.Output.HysteresisDown, "Consecutive unavailable results needed before a service flips to unavailable in tags.json")
+	fs.StringVar(&s.Output.HARDir, "har-dir", s.Output.HARDir, "Save the HTTP exchanges of failed checks as one HAR 1.2 file per node in this directory (cookies and auth headers redacted)")
+	fs.StringVar(&s.Output.MetricsTextfile, "metrics-textfile", s.Output.MetricsTextfile, "Write Prometheus metrics of the run to this file for node_exporter's textfile collector (*.prom)")
+
+	fs.Float64Var(&s.Gate.MinTestedRatio, "min-tested-ratio", s.Gate.MinTestedRatio, "Quality gate: minimum fraction of subscription nodes that must be tested (0 = disabled)")
+	fs.IntVar(&s.Gate.MinSuccessNodes, "min-success-nodes", s.Gate.MinSuccessNodes, "Quality gate: minimum number of nodes with at least one available AI service (0 = disabled)")
+
+	fs.BoolVar(&s.Canary.Enabled, "canary", s.Canary.Enabled, "Check direct reachability of the target services before and after the run to detect global outages")
+	fs.StringVar(&s.Canary.URL, "canary-url", s.Canary.URL, "Extra URL fetched directly (without proxy) by the canary check, implies -canary")
+	fs.StringVar(&s.Canary.ReferenceProxy, "reference-proxy", s.Canary.ReferenceProxy, "Known-good proxy (http:// or socks5://) also checked by the canary, implies -canary")
+
+	fs.StringVar(&s.Notify.Config, "notify", s.Notify.Config, "Notification config file (YAML) with webhook/telegram/discord/slack channels and thresholds")
+	fs.StringVar(&s.Notify.Alerts, "alerts", s.Notify.Alerts, "Alert rules file (YAML) evaluated against each run's results")
+	fs.StringVar(&s.Notify.AlertState, "alert-state", s.Notify.AlertState, "State file for alert dedup and cooldown (default: <output>/alert_state.json)")
+	fs.StringVar(&s.Notify.State, "notify-state", s.Notify.State, "State file with each service's previous available count for on_change thresholds (default: <output>/notify_state.json)")
+}
+
+// listVar 注册逗号分隔的列表参数，出现时整体替换默认值
+func listVar(fs *flag.FlagSet, list *[]string, name, usage string) {
+	if len(*list) > 0 {
+		usage += fmt.Sprintf(" (default %q)", strings.Join(*list, ","))
+	}
+	fs.Func(name, usage, func(v string) error {
+		*list = config.SplitList(v)
+		return nil
+	})
+}
+
+// optionsFromSettings 将设置转换为一次运行的参数，并加载其引用的规则与通知配置
+func optionsFromSettings(s config.Settings) (cliOptions, error) {
+	if s.Source.URL == "" {
+		return cliOptions{}, fmt.Errorf("please provide -source, source.url in the config file or the SUB_URL environment variable")
+	}
+	if issues := s.Validate(); len(issues) > 0 {
+		return cliOptions{}, config.IssuesError(issues)
+	}
+
+	formats, err := reporter.ParseFormats(strings.Join(s.Output.Formats, ","))
+	if err != nil {
+		return cliOptions{}, fmt.Errorf("invalid -format: %w", err)
+	}
+	if _, err := ui.New(s.Run.UI); err != nil {
+		return cliOptions{}, fmt.Errorf("invalid -ui: %w", err)
+	}
+
+	var notify *notifier.Notifier
+	if s.Notify.Config != "" {
+		if notify, err = notifier.Load(s.Notify.Config); err != nil {
+			return cliOptions{}, fmt.Errorf("invalid -notify: %w", err)
+		}
+	}
+
+	var rules *alert.RuleSet
+	if s.Notify.Alerts != "" {
+		if rules, err = alert.LoadRules(s.Notify.Alerts); err != nil {
+			return cliOptions{}, fmt.Errorf("invalid -alerts: %w", err)
+		}
+	}
+
+	checkpointPath := s.Run.Checkpoint
+	if checkpointPath == "" {
+		checkpointPath = filepath.Join(s.Output.Dir, "checkpoint.jsonl")
+	}
+	alertState := s.Notify.AlertState
+	if alertState == "" {
+		alertState = filepath.Join(s.Output.Dir, "alert_state.json")
+	}
+	notifyState := s.Notify.State
+	if notifyState == "" {
+		notifyState = filepath.Join(s.Output.Dir, "notify_state.json")
+	}
+	retries := s.Checks.Retries
+
+	return cliOptions{
+		Run: clashtester.Options{
+			Source:        s.Source.URL,
+			SourceTimeout: s.Source.Timeout,
+			Filter:        clashtester.Filter(s.Filter),
+			Core:          s.Workers.Core,
+			CorePath:      s.Workers.CorePath,
+			MihomoPath:    s.Workers.Mihomo,
+			Workers:       s.Workers.Count,
+			PortBase:      s.Workers.PortBase,
+			PortStep:      s.Workers.PortStep,
+			APIPortBase:   s.Workers.APIPortBase,
+			RunTimeout:    s.Run.Timeout,
+			NodeTimeout:   s.Workers.NodeTimeout,
+			ShutdownGrace: s.Run.ShutdownGrace,
+			Rounds:        s.Run.Rounds,
+			RoundWindow:   s.Run.RoundWindow,
+			Checks: clashtester.CheckOptions{
+				Services: s.Checks.Services,
+				Timeout:  s.Checks.Timeout,
+				Retries:  &retries,
+				SpeedTest: clashtester.SpeedTestOptions{
+					URL:          s.Checks.SpeedTest.URL,
+					MaxBytes:     int64(s.Checks.SpeedTest.MaxMB) * 1024 * 1024,
+					MaxDuration:  s.Checks.SpeedTest.Duration,
+					OnlyUnlocked: s.Checks.SpeedTest.UnlockedOnly,
+				},
+				HARDir: s.Output.HARDir,
+			},
+			Canary: clashtester.CanaryOptions{
+				Enabled:        s.Canary.Enabled || s.Canary.URL != "" || s.Canary.ReferenceProxy != "",
+				ExtraURL:       s.Canary.URL,
+				ReferenceProxy: s.Canary.ReferenceProxy,
+			},
+			Gate: clashtester.GateOptions{
+				MinTestedRatio:  s.Gate.MinTestedRatio,
+				MinSuccessNodes: s.Gate.MinSuccessNodes,
+			},
+			Checkpoint:     checkpointPath,
+			Resume:         s.Run.Resume,
+			StatePath:      s.Run.State,
+			TTL:            s.Run.TTL,
+			DefaultTTL:     s.Run.DefaultTTL,
+			UnavailableTTL: s.Run.UnavailableTTL,
+		},
+		Output:         s.Output.Dir,
+		MapOutput:      s.Output.MapOutput,
+		MapPrevious:    s.Output.MapPrevious,
+		MapMaxAge:      s.Output.MapMaxAge,
+		HysteresisUp:   s.Output.HysteresisUp,
+		HysteresisDown: s.Output.HysteresisDown,
+		MapBackup:      s.Output.MapBackup,
+		MapMinKeep:     s.Output.MapMinKeep,
+		MapForce:       s.Output.MapForce,
+		Formats:        formats,
+		UI:             s.Run.UI,
+		MetricsFile:    s.Output.MetricsTextfile,
+		Notifier:       notify,
+		Alerts:         rules,
+		AlertState:     alertState,
+		NotifyState:    notifyState,
+	}, nil
+}
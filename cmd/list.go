@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+
+	"Clash-tester/internal/config"
+	"Clash-tester/internal/ui"
+)
+
+// listNameWidth list 输出中节点名称列的最大显示宽度
+const listNameWidth = 40
+
+// listedNode list -json 输出的单个节点
+type listedNode struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Server      string `json:"server"`
+	Port        int    `json:"port"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// runList 实现 list 命令：输出解析并过滤后的节点，不做测试
+func runList(args []string) int {
+	fs := newFlagSet("list")
+	var asJSON bool
+	settings, err := parseSettings(fs, args, func(fs *flag.FlagSet, s *config.Settings) {
+		bindSourceFlags(fs, s)
+		fs.BoolVar(&asJSON, "json", false, "Print the nodes as a JSON array")
+	})
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return exitError
+	}
+	if settings.Source.URL == "" {
+		log.Printf("❌ Please provide -source, source.url in the config file or the SUB_URL environment variable")
+		return exitError
+	}
+
+	nodes, err := loadNodes(context.Background(), settings.Source.URL, settings.Source.Timeout)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return exitCodeOf(err)
+	}
+	total := len(nodes)
+	if nodes, err = settings.Filter.Filter(nodes); err != nil {
+		log.Printf("❌ %v", err)
+		return exitError
+	}
+
+	if asJSON {
+		list := make([]listedNode, 0, len(nodes))
+		for _, node := range nodes {
+			list = append(list, listedNode{
+				Name:        node.Name,
+				Type:        node.Type,
+				Server:      node.Server,
+				Port:        node.Port,
+				Fingerprint: node.Fingerprint(),
+			})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(list); err != nil {
+			log.Printf("❌ %v", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	nameWidth := 4
+	for _, node := range nodes {
+		if w := ui.StringWidth(node.Name); w > nameWidth {
+			nameWidth = min(w, listNameWidth)
+		}
+	}
+	indexWidth := len(strconv.Itoa(len(nodes)))
+	for i, node := range nodes {
+		fmt.Printf("%*d  %s  %-10s %s\n", indexWidth, i+1,
+			ui.PadRight(ui.Truncate(node.Name, nameWidth), nameWidth),
+			node.Type, net.JoinHostPort(node.Server, strconv.Itoa(node.Port)))
+	}
+
+	if len(nodes) == total {
+		fmt.Printf("\n%d nodes\n", total)
+	} else {
+		fmt.Printf("\n%d of %d nodes match the filter\n", len(nodes), total)
+	}
+	return exitOK
+}
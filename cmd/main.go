@@ -1,261 +1,269 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"sync"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"Clash-tester/internal/alert"
 	"Clash-tester/internal/config"
-	"Clash-tester/internal/parser"
-	"Clash-tester/internal/proxy"
+	"Clash-tester/internal/metrics"
+	"Clash-tester/internal/notifier"
 	"Clash-tester/internal/reporter"
-	"Clash-tester/internal/tester"
+	"Clash-tester/internal/ui"
+	"Clash-tester/internal/version"
+	"Clash-tester/pkg/clashtester"
 	"Clash-tester/pkg/models"
 )
 
-type Worker struct {
-	ID         int
-	Core       *proxy.MihomoCore
-	ConfigPath string
+// cliOptions 一次命令行运行的全部参数
+type cliOptions struct {
+	Run            clashtester.Options // 测试本身的参数
+	Output         string
+	MapOutput      string
+	MapPrevious    string        // 上一次的 tags.json，用于沿用未测试节点的结果
+	MapMaxAge      time.Duration // 沿用旧结果的最长时间
+	HysteresisUp   int           // 服务状态翻转为可用所需的连续次数
+	HysteresisDown int           // 服务状态翻转为不可用所需的连续次数
+	MapBackup      bool          // 覆盖 tags.json 前保留 .bak
+	MapMinKeep     float64       // tags.json 条目数缩水保护阈值
+	MapForce       bool          // 跳过 tags.json 缩水保护
+	Formats        []string      // 输出格式，见 reporter.ParseFormats
+	UI             string        // 进度显示模式：auto / tui / plain
+	MetricsFile    string        // node_exporter textfile 输出路径，为空表示不输出
+	Notifier       *notifier.Notifier
+	Alerts         *alert.RuleSet
+	AlertState     string // 告警去重状态文件
+	NotifyState    string // 通知阈值状态文件 (各服务上一次的可用节点数)
 }
 
 func main() {
-	// 命令行参数
-	// mode := flag.String("mode", "cli", "Running mode: cli (server mode removed)") // Deprecated
-	source := flag.String("source", "", "Subscription URL or local YAML file path")
-	output := flag.String("output", "result", "Output directory for detailed results")
-	mapOutput := flag.String("map-output", "", "Path to save tags.json (Map format for SubStore)")
-	mihomoPath := flag.String("mihomo", "mihomo.exe", "Path to mihomo executable")
-	workersCount := flag.Int("workers", 5, "Number of concurrent workers")
-	flag.Parse()
-
-	// 兼容环境变量 (Docker Cron 模式使用)
-	if *source == "" {
-		envSource := os.Getenv("SUB_URL")
-		if envSource != "" {
-			*source = envSource
-		}
-	}
-
-	if *source == "" {
-		log.Fatal("Please provide -source parameter or SUB_URL environment variable")
-	}
-
-	runCLI(*source, *output, *mapOutput, *mihomoPath, *workersCount)
+	os.Exit(runCommand(os.Args[1:]))
 }
 
-func runCLI(source, output, mapOutput, mihomoPath string, workersCount int) {
-	printBanner()
-
-	// 1. 加载配置
-	fmt.Printf("📥 Loading configuration from: %s\n", source)
-	data, err := config.Load(config.LoaderConfig{
-		Source:  source,
-		Timeout: 30,
+// runTest 实现 test 命令：测试订阅中的全部节点一次
+// 兼容旧用法，-serve 指定地址时等同于 serve 命令
+func runTest(args []string) int {
+	fs := newFlagSet("test")
+	settings, err := parseSettings(fs, args, func(fs *flag.FlagSet, s *config.Settings) {
+		bindFlags(fs, s)
+		fs.StringVar(&s.Schedule.Serve, "serve", s.Schedule.Serve, "Run continuously and expose Prometheus metrics on this address (e.g. :9101), same as the serve command")
+		fs.DurationVar(&s.Schedule.Interval, "interval", s.Schedule.Interval, "Time between runs in -serve mode")
 	})
 	if err != nil {
-		log.Fatalf("❌ Failed to load config: %v", err)
+		log.Printf("❌ %v", err)
+		return exitError
 	}
 
-	// 2. 解析节点
-	fmt.Println("🔍 Parsing subscription...")
-	nodes, err := parser.Parse(data)
+	opts, err := optionsFromSettings(settings)
 	if err != nil {
-		log.Fatalf("❌ Failed to parse config: %v", err)
+		log.Printf("❌ %v", err)
+		return exitError
 	}
 
-	fmt.Printf("✅ Found %d supported nodes\n\n", len(nodes))
+	if settings.Schedule.Serve != "" {
+		return runLoop(opts, settings.Schedule.Serve, settings.Schedule.Interval)
+	}
+	return runOnce(opts, nil)
+}
 
-	if len(nodes) == 0 {
-		log.Fatal("❌ No supported nodes found")
+// saveDetailedReports 在输出目录中写入 -format 指定的详细报告
+func saveDetailedReports(report models.TestReport, opts cliOptions) {
+	if len(opts.Formats) == 0 {
+		return
+	}
+	if err := reporter.SaveDetailed(report, opts.Output, opts.Formats); err != nil {
+		log.Printf("⚠️  Failed to save detailed report: %v", err)
+	} else {
+		fmt.Printf("\n💾 Detailed results saved to: %s/\n", opts.Output)
 	}
+}
 
-	// 3. 初始化 Workers
-	fmt.Printf("🚀 Starting %d mihomo workers...\n", workersCount)
-	workers := make([]*Worker, 0, workersCount)
-	
-	// 确保所有核心和临时文件最终都被清理
-	defer func() {
-		fmt.Println("\n🧹 Cleaning up resources...")
-		for _, w := range workers {
-			if w.Core != nil {
-				w.Core.Stop()
-			}
-			if w.ConfigPath != "" {
-				os.Remove(w.ConfigPath)
-			}
+// runOnce 执行一次测试并输出指标，返回进程退出码
+func runOnce(opts cliOptions, exporter *metrics.Exporter) int {
+	// 上一次的报告用于判断阈值是否刚刚被突破，需在本次报告写出前读取
+	var previous *models.TestReport
+	if opts.Notifier != nil || opts.Alerts != nil {
+		var err error
+		if previous, err = reporter.LoadLatestJSON(opts.Output); err != nil {
+			log.Printf("⚠️  Failed to load previous report: %v", err)
 		}
-	}()
-
-	for i := 0; i < workersCount; i++ {
-		workerID := i + 1
-		tempConfig := fmt.Sprintf("temp_worker_%d.yaml", workerID)
-		
-		port := 7890 + (i * 10)
-		apiPort := 9090 + i
+	}
 
-		if err := config.GenerateMihomoConfig(nodes, tempConfig, port, apiPort); err != nil {
-			log.Fatalf("❌ Failed to generate config for worker %d: %v", workerID, err)
-		}
+	started := time.Now()
+	report, err := runCLI(opts)
+	if err != nil {
+		log.Printf("❌ %v", err)
+	}
 
-		core := proxy.NewMihomoCore(mihomoPath, tempConfig, port, apiPort)
-		if err := core.Start(); err != nil {
-			log.Fatalf("❌ Failed to start worker %d: %v", workerID, err)
+	run := metrics.Run{
+		Report:   report,
+		Started:  started,
+		Finished: time.Now(),
+		ExitCode: exitCodeOf(err),
+	}
+	if exporter != nil {
+		exporter.Observe(run)
+	}
+	if opts.MetricsFile != "" {
+		if err := metrics.WriteTextfile(opts.MetricsFile, run); err != nil {
+			log.Printf("⚠️  Failed to write metrics: %v", err)
 		}
-
-		workers = append(workers, &Worker{
-			ID:         workerID,
-			Core:       core,
-			ConfigPath: tempConfig,
-		})
-		fmt.Printf("  ✅ Worker %d started (Port: %d, API: %d)\n", workerID, port, apiPort)
 	}
-	
-	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	if opts.Alerts != nil {
+		evaluateAlerts(opts, report, previous)
+	}
+	if opts.Notifier != nil {
+		notify(opts, report, previous, err, run.ExitCode)
+	}
+	return run.ExitCode
+}
 
-	// 4. 并发测试
-	report := models.TestReport{
-		TestTime:   time.Now(),
-		Source:     source,
-		TotalNodes: len(nodes),
-		Results:    make([]models.NodeTestResult, 0, len(nodes)),
+// notify 发送本次运行的通知，并把各服务的可用节点数写入状态文件供下一次运行比较
+func notify(opts cliOptions, report, previous *models.TestReport, err error, exitCode int) {
+	st, loadErr := notifier.LoadState(opts.NotifyState)
+	if loadErr != nil {
+		log.Printf("⚠️  Failed to load notify state, starting fresh: %v", loadErr)
+		st = &notifier.State{Available: make(map[string]int)}
 	}
 
-	// 通道定义
-	jobs := make(chan models.ProxyNode, len(nodes))
-	results := make(chan models.NodeTestResult, len(nodes))
-	var wg sync.WaitGroup
+	opts.Notifier.Notify(context.Background(), notifier.Outcome{
+		Report:   report,
+		Previous: previous,
+		State:    st,
+		Err:      err,
+		ExitCode: exitCode,
+	})
 
-	// 启动 Worker Goroutines
-	for _, w := range workers {
-		wg.Add(1)
-		go func(worker *Worker) {
-			defer wg.Done()
-			for node := range jobs {
-				// 切换节点
-				if err := worker.Core.SwitchProxy(node.Name); err != nil {
-					log.Printf("⚠️  [Worker %d] Failed to switch to %s: %v", worker.ID, node.Name, err)
-					continue
-				}
+	st.Record(report, time.Now())
+	if err := st.Save(opts.NotifyState); err != nil {
+		log.Printf("⚠️  Failed to save notify state: %v", err)
+	}
+}
 
-				// 等待生效
-			time.Sleep(500 * time.Millisecond)
+// runCLI 在 clashtester.Runner 之上执行一次测试，负责信号处理、控制台输出与结果发布
+func runCLI(opts cliOptions) (*models.TestReport, error) {
+	printBanner()
 
-				// 测试
-				result := tester.TestNode(node, worker.Core.GetProxyURL())
-				results <- result
-			}
-		}(w)
+	progress, err := ui.New(opts.UI)
+	if err != nil {
+		return nil, err
 	}
+	runOpts := opts.Run
+	runOpts.Progress = progress
+	runOpts.Output = os.Stdout
+	runOpts.Logger = log.Default()
 
-	// 投递任务
-	for _, node := range nodes {
-		jobs <- node
+	runner, err := clashtester.New(runOpts)
+	if err != nil {
+		return nil, err
 	}
-	close(jobs)
 
-	// 等待完成并关闭结果通道
+	// 第一次信号：停止派发，进行中的节点在宽限期内继续完成
+	// 再次收到信号：立即取消进行中的节点
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	// 5. 收集结果与进度显示
-	processedCount := 0
-	for result := range results {
-		processedCount++
-		report.Results = append(report.Results, result)
-		report.TestedNodes++
-		
-		if tester.IsNodeSuccess(result) {
-			report.SuccessNodes++
+		select {
+		case sig := <-sigCh:
+			log.Printf("🛑 Received %s, stopping after in-flight nodes (grace %s, signal again to abort)...", sig, opts.Run.ShutdownGrace)
+			cancel(errors.New("interrupted by signal"))
+		case <-done:
+			return
+		}
+		select {
+		case <-sigCh:
+			runner.Abort()
+		case <-done:
 		}
+	}()
 
-		// 打印进度
-		printProgress(processedCount, len(nodes), result)
+	report, err := runner.Run(ctx)
+	switch {
+	case errors.Is(err, clashtester.ErrGlobalOutage):
+		saveDetailedReports(*report, opts)
+		return report, withExitCode(exitQualityGate, fmt.Errorf("quality gate failed, %w", err))
+	case errors.Is(err, clashtester.ErrSourceUnreachable):
+		return nil, withExitCode(exitSourceUnreachable, err)
+	case errors.Is(err, clashtester.ErrCoreStartup):
+		return nil, withExitCode(exitCoreStartup, err)
+	case err != nil:
+		return nil, err
 	}
 
-	fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-
-	// 6. 生成摘要
-	report.Summary = tester.GenerateSummary(report.Results)
-
-	// 7. 输出结果
-	reporter.PrintConsole(report)
+	// 输出结果
+	reporter.PrintConsole(*report)
 
 	// 保存详细报告
-	if err := reporter.SaveJSON(report, output); err != nil {
-		log.Printf("⚠️  Failed to save detailed JSON: %v", err)
-	} else {
-		fmt.Printf("\n💾 Detailed results saved to: %s/\n", output)
+	saveDetailedReports(*report, opts)
+
+	// 未通过质量检查 (如本机网络故障导致全部失败)：不发布结果
+	if len(report.GateFailures) > 0 {
+		return report, withExitCode(exitQualityGate, fmt.Errorf("quality gate failed, results not published: %s",
+			strings.Join(report.GateFailures, "; ")))
 	}
 
 	// 保存 Map 格式报告 (如果指定)
-	if mapOutput != "" {
-		if err := reporter.SaveTagMapJSON(report, mapOutput); err != nil {
-			log.Printf("⚠️  Failed to save Map JSON: %v", err)
-			os.Exit(1) // 重要：如果生成 tags.json 失败，应该返回非 0 退出码，以便 Cron 脚本感知
-		} else {
-			fmt.Printf("💾 Tag Map JSON saved to: %s\n", mapOutput)
+	if opts.MapOutput != "" && reporter.HasFormat(opts.Formats, reporter.FormatTags) {
+		// 切换失败等原因未测试的节点沿用上一次的结果，避免 SubStore 丢失标签
+		mapOpts := reporter.TagMapOptions{
+			PreviousPath:  opts.MapPrevious,
+			PresentNodes:  make([]string, 0, report.TotalNodes),
+			MaxAge:        opts.MapMaxAge,
+			UpThreshold:   opts.HysteresisUp,
+			DownThreshold: opts.HysteresisDown,
+			Backup:        opts.MapBackup,
+			MinKeepRatio:  opts.MapMinKeep,
+			Force:         opts.MapForce,
+		}
+		for _, node := range runner.Nodes() {
+			mapOpts.PresentNodes = append(mapOpts.PresentNodes, node.Name)
 		}
+		if err := reporter.SaveTagMapJSON(*report, opts.MapOutput, mapOpts); err != nil {
+			// 缩水保护与质量检查一样表示结果不可信，未发布
+			if errors.Is(err, reporter.ErrShrinkGuard) {
+				return report, withExitCode(exitQualityGate, fmt.Errorf("tags.json not published: %w", err))
+			}
+			// 重要：如果生成 tags.json 失败，应该返回非 0 退出码，以便 Cron 脚本感知
+			return report, fmt.Errorf("failed to save Map JSON: %w", err)
+		}
+		fmt.Printf("💾 Tag Map JSON saved to: %s\n", opts.MapOutput)
+	}
+
+	// 部分节点未测试：结果已发布 (未测试节点沿用旧结果)，但以单独的退出码告知调用方
+	if report.TestedNodes < report.TotalNodes {
+		reason := "some nodes could not be tested"
+		if report.Incomplete {
+			reason = report.IncompleteReason + ", resume with -resume"
+		}
+		return report, withExitCode(exitPartial, fmt.Errorf("partial run: %s (%d/%d nodes tested)",
+			reason, report.TestedNodes, report.TotalNodes))
 	}
 
 	fmt.Println("\n✨ Test completed!")
+	return report, nil
 }
 
 func printBanner() {
 	banner := `
 ╔═══════════════════════════════════════════════════════╗
 ║                                                       ║
-║        Clash AI Service Tester v1.3                  ║
+║        %-47s║
 ║        Cron Mode Ready                                
 ║                                                       ║
 ╚═══════════════════════════════════════════════════════╝
 `
-	fmt.Println(banner)
-}
-
-func printProgress(current, total int, result models.NodeTestResult) {
-	status := "❌"
-	if tester.IsNodeSuccess(result) {
-		status = "✅"
-	}
-	
-	// 组装简短信息
-	openai := getServiceStatusShort(result.Tests["openai"])
-	netflix := getStreamStatusShort(result.StreamTests["netflix"])
-	disney := getStreamStatusShort(result.StreamTests["disney"])
-	
-	fmt.Printf("[%3d/%d] %s %-20s (Chat:%s NF:%s D+:%s)\n", 
-		current, total, status, truncateString(result.NodeName, 20), openai, netflix, disney)
-}
-
-func getServiceStatusShort(test models.ServiceTest) string {
-	if !test.Available {
-		return "✗"
-	}
-	if test.Country != "" {
-		return test.Country
-	}
-	return "✓"
-}
-
-func getStreamStatusShort(test models.StreamTest) string {
-	if !test.Available {
-		return "✗"
-	}
-	if test.Region != "" {
-		return test.Region
-	}
-	return "✓"
-}
-
-func truncateString(s string, maxLen int) string {
-	if len(s) > maxLen {
-		return s[:maxLen-3] + "..."
-	}
-	return s
+	fmt.Printf(banner+"\n", "Clash AI Service Tester "+version.Version)
 }
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"Clash-tester/internal/config"
+	"Clash-tester/internal/fakenet"
+	"Clash-tester/internal/notifier"
+	"Clash-tester/internal/reporter"
+	"Clash-tester/internal/tester"
+)
+
+// testOptions 使用假核心与假互联网的命令行参数，输出写入临时目录
+func testOptions(t *testing.T, nodes map[string]fakenet.Node) cliOptions {
+	t.Helper()
+	dir := t.TempDir()
+	s := config.DefaultSettings()
+	s.Source.URL = fakenet.WriteSubscription(t, "")
+	s.Workers.Count = 2
+	s.Checks.Retries = 0
+	s.Checks.Timeout = 5 * time.Second
+	s.Run.UI = "plain"
+	s.Output.Dir = filepath.Join(dir, "result")
+	s.Output.Formats = []string{"json", "tags"}
+	s.Output.MapOutput = filepath.Join(dir, "tags.json")
+
+	opts, err := optionsFromSettings(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots, err := fakenet.RootCAs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Run.NewCore = fakenet.Factory(nodes)
+	opts.Run.Checks.RootCAs = roots
+	return opts
+}
+
+func TestRunCLI(t *testing.T) {
+	opts := testOptions(t, fakenet.SubscriptionBehavior(t))
+
+	report, err := runCLI(opts)
+	if code := exitCodeOf(err); code != exitOK {
+		t.Fatalf("exit code %d: %v", code, err)
+	}
+	if report.TestedNodes != 3 || report.SuccessNodes != 1 {
+		t.Errorf("tested/success = %d/%d, want 3/1", report.TestedNodes, report.SuccessNodes)
+	}
+
+	data, err := os.ReadFile(opts.MapOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tags map[string]reporter.NodeTagData
+	if err := json.Unmarshal(data, &tags); err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 3 {
+		t.Errorf("tags.json has %d nodes, want 3", len(tags))
+	}
+	if us := tags[fakenet.NodeUS]; us.OpenAI == nil || !us.OpenAI.Available || us.Netflix == nil || !us.Netflix.Available {
+		t.Errorf("US tags = %+v, want OpenAI and Netflix available", us)
+	}
+	if cn := tags[fakenet.NodeCN]; cn.Claude == nil || cn.Claude.Available {
+		t.Errorf("CN tags = %+v, want Claude unavailable", cn)
+	}
+
+	if _, err := reporter.LoadLatestJSON(opts.Output); err != nil {
+		t.Errorf("detailed JSON report not saved: %v", err)
+	}
+}
+
+func TestRunCLIPartial(t *testing.T) {
+	behavior := fakenet.SubscriptionBehavior(t)
+	behavior[fakenet.NodeCN] = fakenet.Node{SelectError: fakenet.ErrSelect}
+	behavior[fakenet.NodeDown] = fakenet.Node{SelectError: fakenet.ErrSelect}
+	opts := testOptions(t, behavior)
+
+	report, err := runCLI(opts)
+	if code := exitCodeOf(err); code != exitPartial {
+		t.Fatalf("exit code %d (%v), want %d", code, err, exitPartial)
+	}
+	if report.TestedNodes != 1 {
+		t.Errorf("tested = %d, want 1", report.TestedNodes)
+	}
+	// 未测试的节点仍发布到 tags.json (没有旧结果可沿用时不出现)
+	if _, err := os.Stat(opts.MapOutput); err != nil {
+		t.Errorf("tags.json not published on a partial run: %v", err)
+	}
+}
+
+func TestRunCLIQualityGate(t *testing.T) {
+	opts := testOptions(t, map[string]fakenet.Node{})
+	opts.Run.Gate.MinSuccessNodes = 1
+
+	_, err := runCLI(opts)
+	if code := exitCodeOf(err); code != exitQualityGate {
+		t.Fatalf("exit code %d (%v), want %d", code, err, exitQualityGate)
+	}
+	if _, err := os.Stat(opts.MapOutput); !os.IsNotExist(err) {
+		t.Errorf("tags.json published although the quality gate failed (err %v)", err)
+	}
+}
+
+func TestRunCLISourceUnreachable(t *testing.T) {
+	opts := testOptions(t, map[string]fakenet.Node{})
+	opts.Run.Source = filepath.Join(t.TempDir(), "missing.yaml")
+
+	_, err := runCLI(opts)
+	if code := exitCodeOf(err); code != exitSourceUnreachable {
+		t.Fatalf("exit code %d (%v), want %d", code, err, exitSourceUnreachable)
+	}
+}
+
+// TestRunCLIGlobalOutage 直连探测全部失败时以质量检查的退出码结束，不发布 tags.json
+func TestRunCLIGlobalOutage(t *testing.T) {
+	direct := fakenet.NewTestInternet(t, tester.DefaultEndpoints, fakenet.Unlocked("US"))
+	endpoints := tester.DefaultEndpoints
+	endpoints.Canary = []tester.CanaryTarget{{Name: "openai", URL: direct.DirectURL("/")}}
+	direct.Close()
+
+	opts := testOptions(t, fakenet.SubscriptionBehavior(t))
+	opts.Run.Checks.Endpoints = &endpoints
+	opts.Run.Canary.Enabled = true
+	if err := os.WriteFile(opts.MapOutput, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := runCLI(opts)
+	if code := exitCodeOf(err); code != exitQualityGate {
+		t.Fatalf("exit code %d (%v), want %d", code, err, exitQualityGate)
+	}
+	if got, _ := os.ReadFile(opts.MapOutput); string(got) != "{}" {
+		t.Errorf("tags.json overwritten during a global outage: %s", got)
+	}
+}
+
+func TestRunCLIShrinkGuard(t *testing.T) {
+	behavior := make(map[string]fakenet.Node)
+	for _, node := range fakenet.SubscriptionNodes() {
+		behavior[node.Name] = fakenet.Node{SelectError: fakenet.ErrSelect}
+	}
+	opts := testOptions(t, behavior)
+	// 旧条目已过期，不会沿用，新结果为空
+	opts.MapMaxAge = time.Hour
+	old := make(map[string]reporter.NodeTagData)
+	for name := range behavior {
+		old[name] = reporter.NodeTagData{UpdateTime: time.Now().Add(-2 * time.Hour)}
+	}
+	data, err := json.Marshal(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(opts.MapOutput, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = runCLI(opts)
+	if code := exitCodeOf(err); code != exitQualityGate {
+		t.Fatalf("exit code %d (%v), want %d", code, err, exitQualityGate)
+	}
+	if got, _ := os.ReadFile(opts.MapOutput); string(got) != string(data) {
+		t.Errorf("tags.json overwritten by the shrink guard refusal")
+	}
+
+	opts.MapForce = true
+	_, err = runCLI(opts)
+	if code := exitCodeOf(err); code != exitPartial {
+		t.Fatalf("forced: exit code %d (%v), want %d", code, err, exitPartial)
+	}
+}
+
+// TestRunOnceNotifyOnChange 输出目录每轮不同 (如 Docker 中每轮清空) 时，on_change 阈值依据状态文件只触发一次
+func TestRunOnceNotifyOnChange(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Event   string `json:"event"`
+			Service string `json:"service"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("request body is not JSON: %v", err)
+		}
+		mu.Lock()
+		events = append(events, body.Event+":"+body.Service)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	n, err := notifier.New(notifier.Config{
+		Channels:   []notifier.Channel{{Type: "webhook", URL: srv.URL, Events: []string{notifier.EventThreshold}}},
+		Thresholds: []notifier.Threshold{{Service: "netflix", Min: 5, OnChange: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	statePath := filepath.Join(t.TempDir(), "notify_state.json")
+	behavior := fakenet.SubscriptionBehavior(t)
+
+	for cycle, want := range []string{"threshold:netflix", ""} {
+		opts := testOptions(t, behavior)
+		opts.Notifier = n
+		opts.NotifyState = statePath
+		if code := runOnce(opts, nil); code != exitOK {
+			t.Fatalf("cycle %d: exit code %d", cycle+1, code)
+		}
+		mu.Lock()
+		got := strings.Join(events, ",")
+		events = nil
+		mu.Unlock()
+		if got != want {
+			t.Errorf("cycle %d: events %q, want %q", cycle+1, got, want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"Clash-tester/internal/config"
+	"Clash-tester/internal/metrics"
+	"Clash-tester/internal/reporter"
+)
+
+// defaultServeAddr serve 命令未指定 -listen 时的监听地址
+const defaultServeAddr = ":9101"
+
+// runServeCommand 实现 serve 命令
+func runServeCommand(args []string) int {
+	fs := newFlagSet("serve")
+	settings, err := parseSettings(fs, args, func(fs *flag.FlagSet, s *config.Settings) {
+		bindFlags(fs, s)
+		if s.Schedule.Serve == "" {
+			s.Schedule.Serve = defaultServeAddr
+		}
+		fs.StringVar(&s.Schedule.Serve, "listen", s.Schedule.Serve, "HTTP listen address for reports and /metrics")
+		fs.DurationVar(&s.Schedule.Interval, "interval", s.Schedule.Interval, "Time between runs")
+	})
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return exitError
+	}
+	return runScheduled(settings, settings.Schedule.Serve)
+}
+
+// runDaemon 实现 daemon 命令：与 serve 相同，但不监听 HTTP
+func runDaemon(args []string) int {
+	fs := newFlagSet("daemon")
+	settings, err := parseSettings(fs, args, func(fs *flag.FlagSet, s *config.Settings) {
+		bindFlags(fs, s)
+		fs.DurationVar(&s.Schedule.Interval, "interval", s.Schedule.Interval, "Time between runs")
+	})
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return exitError
+	}
+	return runScheduled(settings, "")
+}
+
+func runScheduled(settings config.Settings, addr string) int {
+	if settings.Schedule.Interval <= 0 {
+		log.Printf("❌ Invalid -interval: must be positive")
+		return exitError
+	}
+	opts, err := optionsFromSettings(settings)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return exitError
+	}
+	return runLoop(opts, addr, settings.Schedule.Interval)
+}
+
+// runLoop 常驻模式：按 interval 循环测试
+// addr 非空时通过 HTTP 提供 /metrics 以及输出目录中的 HTML/CSV/Markdown 报告 (首页为最新的 HTML 报告)
+// 收到 SIGINT/SIGTERM 时，进行中的测试按正常流程优雅停止，之后退出
+func runLoop(opts cliOptions, addr string, interval time.Duration) int {
+	var exporter *metrics.Exporter
+	if addr != "" {
+		exporter = metrics.NewExporter()
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", exporter)
+		mux.Handle("/", reportHandler(opts.Output))
+
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			log.Printf("❌ Failed to listen on %s: %v", addr, err)
+			return exitError
+		}
+		srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go srv.Serve(ln)
+		defer srv.Close()
+		fmt.Printf("📈 Serving reports on http://%s/ and metrics on http://%s/metrics\n", ln.Addr(), ln.Addr())
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	for {
+		runOnce(opts, exporter)
+
+		fmt.Printf("💤 Next run in %s\n", interval)
+		select {
+		case <-sigCh:
+			return exitOK
+		case <-time.After(interval):
+		}
+	}
+}
+
+// servedReport 可通过 HTTP 访问的报告文件名
+// JSON 报告含订阅地址，检查点、状态文件与 HAR 等也可能写在输出目录中，均不对外提供
+var servedReport = regexp.MustCompile(`^test_result_\d{8}_\d{6}\.(html|csv|md)$`)
+
+// reportHandler 只提供输出目录中的报告文件，/ 为最新的 HTML 报告
+func reportHandler(dir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if name == "" {
+			name = reporter.HTMLIndexName
+		}
+		if name != reporter.HTMLIndexName && !servedReport.MatchString(name) {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join(dir, name))
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReportHandler(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"index.html",
+		"test_result_20260101_120000.html",
+		"test_result_20260101_120000.csv",
+		"test_result_20260101_120000.md",
+		"test_result_20260101_120000.json",
+		"checkpoint.jsonl",
+		"alert_state.json",
+		"notify_state.json",
+		"node.har",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := httptest.NewServer(reportHandler(dir))
+	defer srv.Close()
+
+	tests := []struct {
+		path string
+		body string // 为空表示应返回 404
+	}{
+		{"/", "index.html"},
+		{"/test_result_20260101_120000.html", "test_result_20260101_120000.html"},
+		{"/test_result_20260101_120000.csv", "test_result_20260101_120000.csv"},
+		{"/test_result_20260101_120000.md", "test_result_20260101_120000.md"},
+		{"/test_result_20260101_120000.json", ""},
+		{"/checkpoint.jsonl", ""},
+		{"/alert_state.json", ""},
+		{"/notify_state.json", ""},
+		{"/node.har", ""},
+		{"/../tags.json", ""},
+		{"/result/", ""},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if tt.body == "" {
+			if resp.StatusCode != http.StatusNotFound {
+				t.Errorf("GET %s: status %d, want 404", tt.path, resp.StatusCode)
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK || string(body) != tt.body {
+			t.Errorf("GET %s: status %d body %q, want 200 %q", tt.path, resp.StatusCode, body, tt.body)
+		}
+	}
+}
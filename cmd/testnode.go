@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"Clash-tester/internal/config"
+	"Clash-tester/internal/reporter"
+	"Clash-tester/pkg/clashtester"
+	"Clash-tester/pkg/models"
+)
+
+// runTestNode 实现 test-node 命令：只测试一个节点并输出每项检测的详细信息
+// 不写报告、tags.json、状态文件与指标，也不发送通知，不会影响定时任务的结果
+// 指定 -debug 时记录全部请求与判定依据，保存为排查目录
+func runTestNode(args []string) int {
+	fs := newFlagSet("test-node")
+	var debugDir string
+	settings, err := parseSettings(fs, args, func(fs *flag.FlagSet, s *config.Settings) {
+		bindFlags(fs, s)
+		fs.StringVar(&debugDir, "debug", "", "Record every request, redirect, response and heuristic of the checks into a debug bundle under this directory")
+	})
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return exitError
+	}
+	name := strings.Join(fs.Args(), " ")
+	if name == "" {
+		fs.Usage()
+		return exitError
+	}
+	if settings.Source.URL == "" {
+		log.Printf("❌ Please provide -source, source.url in the config file or the SUB_URL environment variable")
+		return exitError
+	}
+
+	nodes, err := loadNodes(context.Background(), settings.Source.URL, settings.Source.Timeout)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return exitCodeOf(err)
+	}
+	node, err := findNode(nodes, name)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return exitError
+	}
+
+	// 检查点放在临时目录，避免覆盖定时任务中断后留下的检查点
+	tmpDir, err := os.MkdirTemp("", "clash-tester-node-")
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return exitError
+	}
+	defer os.RemoveAll(tmpDir)
+
+	settings.Filter = config.FilterSettings{Names: []string{node.Name}}
+	settings.Workers.Count = 1
+	settings.Run.Checkpoint = filepath.Join(tmpDir, "checkpoint.jsonl")
+	settings.Run.Resume = false
+	settings.Run.State = ""
+	settings.Run.UI = "plain"
+	settings.Output.Formats = nil
+	settings.Output.MapOutput = ""
+	settings.Output.MetricsTextfile = ""
+	settings.Gate = config.GateSettings{}
+	settings.Notify = config.NotifySettings{}
+
+	opts, err := optionsFromSettings(settings)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return exitError
+	}
+
+	var rec *clashtester.Recorder
+	if debugDir != "" {
+		rec = &clashtester.Recorder{}
+		opts.Run.Checks.Recorder = rec
+	}
+
+	report, err := runCLI(opts)
+	if report != nil {
+		for _, result := range report.Results {
+			fmt.Println()
+			reporter.PrintNodeDetail(os.Stdout, result)
+
+			if rec != nil {
+				bundle, saveErr := reporter.SaveDebugBundle(debugDir, result, rec.Checks())
+				if saveErr != nil {
+					log.Printf("⚠️  Failed to save debug bundle: %v", saveErr)
+				} else {
+					fmt.Printf("\n🐞 Debug bundle saved to: %s\n", bundle)
+				}
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("❌ %v", err)
+	}
+	return exitCodeOf(err)
+}
+
+// findNode 按名称查找节点：先精确匹配，再不区分大小写的子串匹配
+// 子串匹配到多个节点时返回错误并列出候选
+func findNode(nodes []models.ProxyNode, name string) (models.ProxyNode, error) {
+	for _, node := range nodes {
+		if node.Name == name {
+			return node, nil
+		}
+	}
+
+	var matches []models.ProxyNode
+	lower := strings.ToLower(name)
+	for _, node := range nodes {
+		if strings.Contains(strings.ToLower(node.Name), lower) {
+			matches = append(matches, node)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return models.ProxyNode{}, fmt.Errorf("node %q not found, use the list command to see all nodes", name)
+	case 1:
+		return matches[0], nil
+	}
+
+	names := make([]string, 0, len(matches))
+	for _, node := range matches {
+		names = append(names, "  "+node.Name)
+	}
+	return models.ProxyNode{}, fmt.Errorf("%d nodes match %q, use the full name:\n%s", len(matches), name, strings.Join(names, "\n"))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"Clash-tester/internal/config"
+)
+
+// runValidate 实现 validate 命令：检查配置文件与环境变量，逐条输出问题及行号
+// 配置了订阅来源时，同时下载并解析订阅
+func runValidate(args []string) int {
+	fs := newFlagSet("validate")
+	path := fs.String("config", os.Getenv(configEnv), "YAML config file to validate (env: "+configEnv+")")
+	source := fs.String("source", "", "Subscription to check instead of source.url from the config")
+	offline := fs.Bool("offline", false, "Only check the config file, do not fetch the subscription")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		*path = fs.Arg(0)
+	}
+
+	settings, issues, err := loadSettings(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitError
+	}
+	if len(issues) == 0 {
+		issues = settings.Validate()
+	}
+	if len(issues) > 0 {
+		for _, issue := range issues {
+			fmt.Fprintln(os.Stderr, formatIssue(*path, issue))
+		}
+		fmt.Fprintf(os.Stderr, "❌ %d problem(s) found\n", len(issues))
+		return exitError
+	}
+	if *path != "" {
+		fmt.Printf("✅ %s is valid\n", *path)
+	}
+
+	if *source != "" {
+		settings.Source.URL = *source
+	}
+	if *offline || settings.Source.URL == "" {
+		if *path == "" {
+			fmt.Fprintln(os.Stderr, "❌ Nothing to validate, give a config file, -source or "+configEnv)
+			return exitError
+		}
+		return exitOK
+	}
+	return validateSubscription(settings)
+}
+
+// validateSubscription 下载并解析订阅，输出节点统计与会导致测试出错的问题
+func validateSubscription(settings config.Settings) int {
+	fmt.Printf("📥 Loading subscription: %s\n", settings.Source.URL)
+	nodes, err := loadNodes(context.Background(), settings.Source.URL, settings.Source.Timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitCodeOf(err)
+	}
+	if len(nodes) == 0 {
+		fmt.Fprintln(os.Stderr, "❌ No supported nodes found")
+		return exitSourceUnreachable
+	}
+
+	byType := make(map[string]int)
+	names := make(map[string]int)
+	var problems []string
+	for _, node := range nodes {
+		byType[node.Type]++
+		names[node.Name]++
+		if node.Server == "" || node.Port <= 0 || node.Port > 65535 {
+			problems = append(problems, fmt.Sprintf("node %q: missing or invalid server/port", node.Name))
+		}
+	}
+	for name, count := range names {
+		if count > 1 {
+			// mihomo 按名称切换节点，重名节点只有第一个能被测试
+			problems = append(problems, fmt.Sprintf("node name %q is used %d times, only the first one is tested", name, count))
+		}
+	}
+	sort.Strings(problems)
+
+	types := make([]string, 0, len(byType))
+	for t, count := range byType {
+		types = append(types, fmt.Sprintf("%s %d", t, count))
+	}
+	sort.Strings(types)
+	fmt.Printf("✅ %d supported nodes (%s)\n", len(nodes), strings.Join(types, ", "))
+
+	kept, err := settings.Filter.Filter(nodes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitError
+	}
+	if len(kept) != len(nodes) {
+		fmt.Printf("🔎 %d nodes match the filter\n", len(kept))
+	}
+	if len(kept) == 0 {
+		problems = append(problems, "no nodes left after applying the filter")
+	}
+
+	if len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "⚠️  %s\n", p)
+		}
+		fmt.Fprintf(os.Stderr, "❌ %d problem(s) found\n", len(problems))
+		return exitError
+	}
+	return exitOK
+}
+
+// formatIssue 按 file:line: path: message 的格式输出，便于编辑器跳转
+func formatIssue(file string, issue config.Issue) string {
+	if file == "" {
+		return issue.String()
+	}
+	var b strings.Builder
+	b.WriteString(file)
+	if issue.Line > 0 {
+		fmt.Fprintf(&b, ":%d", issue.Line)
+	}
+	b.WriteString(": ")
+	if issue.Path != "" {
+		b.WriteString(issue.Path + ": ")
+	}
+	b.WriteString(issue.Message)
+	return b.String()
+}
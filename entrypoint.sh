@@ -23,39 +23,67 @@ fi
 # 确保 mihomo 有执行权限
 chmod +x /app/mihomo
 
+# 作为 PID 1 运行时 sh 不会把 docker stop 的 SIGTERM 传给子进程，
+# 这里手动转发，让 clash-tester 优雅停止、写出部分报告并清理 mihomo 进程
+CHILD_PID=""
+on_stop() {
+    echo "[$(date)] 🛑 Stop signal received, shutting down..."
+    if [ -n "$CHILD_PID" ]; then
+        kill -TERM "$CHILD_PID" 2>/dev/null
+        wait "$CHILD_PID"
+    fi
+    rm -rf "${TMPDIR:-/tmp}"/clash-tester-*
+    exit 143
+}
+trap on_stop TERM INT
+
 while true; do
     echo "[$(date)] 🔄 Starting new test cycle..."
     
     # 1. 执行测试
     # -output 指向一个临时目录，避免污染
-    # -map-output 指向临时文件，实现原子写入
+    # -map-output 直接指向发布文件，由程序内部通过 临时文件 + fsync + rename 原子替换，
+    #   即使 SubStore 正在读取 tags.json 也不会读到半截数据
+    # -map-backup 保留上一个版本为 tags.json.bak
     # -mihomo 指向当前目录下的二进制
+    # 后台运行并 wait，使 trap 能在测试进行中及时响应
     /app/clash-tester \
         -source "$SUB_URL" \
         -output "/app/result_temp" \
-        -map-output "/data/tags.json.tmp" \
+        -map-output "/data/tags.json" \
+        -map-backup \
+        -min-tested-ratio "${MIN_TESTED_RATIO:-0.5}" \
+        -min-success-nodes "${MIN_SUCCESS_NODES:-1}" \
+        -canary-url "${CANARY_URL:-}" \
+        -reference-proxy "${REFERENCE_PROXY:-}" \
+        -metrics-textfile "${METRICS_TEXTFILE:-}" \
+        -notify "${NOTIFY_CONFIG:-}" \
+        -alerts "${ALERT_RULES:-}" \
+        -alert-state "/data/alert_state.json" \
+        -notify-state "/data/notify_state.json" \
         -mihomo "/app/mihomo" \
-        -workers 5
-    
+        -workers 5 &
+    CHILD_PID=$!
+    wait "$CHILD_PID"
     EXIT_CODE=$?
+    CHILD_PID=""
     
-    if [ $EXIT_CODE -eq 0 ] && [ -f "/data/tags.json.tmp" ]; then
-        # 2. 原子移动 (Atomic Move)
-        # 即使 SubStore 正在读取 tags.json，mv 操作也是瞬间完成的，不会读到半截数据
-        mv /data/tags.json.tmp /data/tags.json
-        echo "[$(date)] ✅ Test finished. JSON updated."
-    else
-        echo "[$(date)] ❌ Test failed or no output generated (Exit Code: $EXIT_CODE)."
-        # 失败不覆盖旧文件，保留上次成功的结果
-        # 清理残余临时文件
-        rm -f /data/tags.json.tmp
-    fi
+    # 失败时程序不会覆盖旧文件，保留上次成功的结果
+    case $EXIT_CODE in
+        0) echo "[$(date)] ✅ Test finished. JSON updated." ;;
+        2) echo "[$(date)] ❌ Subscription source unreachable, JSON not updated." ;;
+        3) echo "[$(date)] ❌ Proxy core failed to start, JSON not updated." ;;
+        4) echo "[$(date)] 🚫 Quality gate failed, JSON not updated." ;;
+        5) echo "[$(date)] ⚠️  Partial run, JSON updated (untested nodes keep previous results)." ;;
+        *) echo "[$(date)] ❌ Test failed or no output generated (Exit Code: $EXIT_CODE)." ;;
+    esac
     
-    # 清理 mihomo 产生的临时配置
-    rm -f /app/temp_worker_*.yaml
+    # 清理代理核心的临时配置 (正常退出时已删除，这里处理被强制结束的情况)
+    rm -rf "${TMPDIR:-/tmp}"/clash-tester-*
     rm -rf /app/result_temp
     
     # 3. 等待下一次周期
     echo "[$(date)] 💤 Sleeping for $INTERVAL seconds..."
-    sleep $INTERVAL
+    sleep $INTERVAL &
+    wait $!
 done
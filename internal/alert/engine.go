@@ -0,0 +1,159 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"Clash-tester/internal/fsutil"
+	"Clash-tester/pkg/models"
+)
+
+// Alert 一条告警 (或恢复通知)
+type Alert struct {
+	Rule        string    `json:"rule"`
+	Severity    string    `json:"severity"`
+	Description string    `json:"description,omitempty"`
+	Resolved    bool      `json:"resolved,omitempty"` // 规则不再触发
+	Value       float64   `json:"value"`              // 本次比较的值 (change 规则为变化量)
+	Current     float64   `json:"current"`            // 本次指标值
+	Previous    *float64  `json:"previous,omitempty"` // 上次指标值
+	Message     string    `json:"message"`
+	Time        time.Time `json:"time"`
+}
+
+// State 告警去重状态，跨运行持久化
+type State struct {
+	Rules map[string]*RuleState `json:"rules"`
+}
+
+// RuleState 单条规则的状态
+type RuleState struct {
+	Firing    bool      `json:"firing"`
+	Since     time.Time `json:"since"`     // 本次持续触发的开始时间
+	LastSent  time.Time `json:"last_sent"` // 最近一次发送告警的时间
+	LastValue *float64  `json:"last_value,omitempty"`
+}
+
+// LoadState 读取状态文件，文件不存在时返回空状态
+func LoadState(path string) (*State, error) {
+	st := &State{Rules: make(map[string]*RuleState)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("invalid alert state %s: %w", path, err)
+	}
+	if st.Rules == nil {
+		st.Rules = make(map[string]*RuleState)
+	}
+	return st, nil
+}
+
+// Save 原子写入状态文件
+func (st *State) Save(path string) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, data, 0644)
+}
+
+// Evaluate 对本次报告评估全部规则，返回需要发送的告警，并更新 st
+// 上次的指标值优先取自状态文件，没有时从 previous 报告计算
+func (rs *RuleSet) Evaluate(report, previous *models.TestReport, st *State, now time.Time) []Alert {
+	var alerts []Alert
+
+	for i := range rs.Rules {
+		r := &rs.Rules[i]
+		rst := st.Rules[r.Name]
+		if rst == nil {
+			rst = &RuleState{}
+			st.Rules[r.Name] = rst
+		}
+
+		current := r.value(report)
+		prev := rst.LastValue
+		if prev == nil && previous != nil {
+			v := r.value(previous)
+			prev = &v
+		}
+		rst.LastValue = &current
+
+		value, ok := current, true
+		switch r.Change {
+		case ChangeDelta:
+			if prev == nil {
+				ok = false
+			} else {
+				value = current - *prev
+			}
+		case ChangePercent:
+			if prev == nil || *prev == 0 {
+				ok = false
+			} else {
+				value = (current - *prev) / *prev * 100
+			}
+		}
+		firing := false
+		if ok {
+			firing, _ = compare(r.Op, value, r.Value)
+		}
+
+		alert := Alert{
+			Rule:        r.Name,
+			Severity:    r.Severity,
+			Description: r.Description,
+			Value:       value,
+			Current:     current,
+			Previous:    prev,
+			Time:        now,
+		}
+
+		switch {
+		case firing && !rst.Firing:
+			rst.Firing, rst.Since, rst.LastSent = true, now, now
+			alert.Message = r.message(alert)
+			alerts = append(alerts, alert)
+		case firing && r.Cooldown > 0 && now.Sub(rst.LastSent) >= r.Cooldown:
+			// 持续触发：冷却时间过后再次提醒
+			rst.LastSent = now
+			alert.Message = r.message(alert) + fmt.Sprintf(" (firing since %s)", rst.Since.Format("2006-01-02 15:04"))
+			alerts = append(alerts, alert)
+		case !firing && rst.Firing:
+			rst.Firing, rst.Since = false, time.Time{}
+			alert.Resolved = true
+			alert.Message = r.message(alert)
+			alerts = append(alerts, alert)
+		}
+	}
+
+	return alerts
+}
+
+func (r *Rule) message(a Alert) string {
+	status := "🚨 [" + a.Severity + "]"
+	if a.Resolved {
+		status = "✅ [resolved]"
+	}
+	msg := fmt.Sprintf("%s %s: %s = %s", status, r.Name, r.describe(), formatValue(a.Value))
+	if !a.Resolved {
+		msg += fmt.Sprintf(" (%s %s)", r.Op, formatValue(r.Value))
+	}
+	if a.Previous != nil && r.Change == "" {
+		msg += fmt.Sprintf(", previous %s", formatValue(*a.Previous))
+	}
+	if r.Description != "" {
+		msg += "\n" + r.Description
+	}
+	return msg
+}
+
+func formatValue(v float64) string {
+	return fmt.Sprintf("%.4g", v)
+}
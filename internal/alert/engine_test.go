@@ -0,0 +1,106 @@
+package alert
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"Clash-tester/pkg/models"
+)
+
+func compileRules(t *testing.T, rules ...Rule) *RuleSet {
+	t.Helper()
+	rs := &RuleSet{Rules: rules}
+	if err := rs.compile(); err != nil {
+		t.Fatal(err)
+	}
+	return rs
+}
+
+// TestEvaluateDedupeCooldown 持续触发时只在冷却时间过后重复发送，恢复时发送一次；状态经文件跨运行保存
+func TestEvaluateDedupeCooldown(t *testing.T) {
+	rs := compileRules(t, Rule{Name: "no-success", Metric: MetricSuccessNodes, Op: "<", Value: 1, Cooldown: time.Hour})
+	path := filepath.Join(t.TempDir(), "alert_state.json")
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		after   time.Duration
+		success int
+		want    string // 空表示不发送
+	}{
+		{0, 0, "firing"},
+		{10 * time.Minute, 0, ""},
+		{61 * time.Minute, 0, "repeat"},
+		{90 * time.Minute, 0, ""},
+		{100 * time.Minute, 2, "resolved"},
+		{110 * time.Minute, 2, ""},
+		{120 * time.Minute, 0, "firing"},
+	}
+	for i, step := range steps {
+		st, err := LoadState(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := &models.TestReport{TotalNodes: 5, TestedNodes: 5, SuccessNodes: step.success}
+		alerts := rs.Evaluate(report, nil, st, start.Add(step.after))
+		if err := st.Save(path); err != nil {
+			t.Fatal(err)
+		}
+
+		got := ""
+		if len(alerts) > 1 {
+			t.Fatalf("step %d: %d alerts", i, len(alerts))
+		}
+		if len(alerts) == 1 {
+			a := alerts[0]
+			switch {
+			case a.Resolved:
+				got = "resolved"
+			case strings.Contains(a.Message, "firing since 2026-01-01 12:00"):
+				got = "repeat"
+			default:
+				got = "firing"
+			}
+		}
+		if got != step.want {
+			t.Errorf("step %d (+%s, success %d): got %q, want %q", i, step.after, step.success, got, step.want)
+		}
+	}
+}
+
+func TestEvaluateWithoutCooldown(t *testing.T) {
+	rs := compileRules(t, Rule{Name: "no-success", Metric: MetricSuccessNodes, Op: "<", Value: 1})
+	st := &State{Rules: make(map[string]*RuleState)}
+	start := time.Now()
+	report := &models.TestReport{TestedNodes: 3}
+
+	if alerts := rs.Evaluate(report, nil, st, start); len(alerts) != 1 {
+		t.Fatalf("first evaluation: %d alerts, want 1", len(alerts))
+	}
+	if alerts := rs.Evaluate(report, nil, st, start.Add(24*time.Hour)); len(alerts) != 0 {
+		t.Errorf("alert repeated without a cooldown: %v", alerts)
+	}
+}
+
+func TestEvaluateChange(t *testing.T) {
+	rs := compileRules(t, Rule{Name: "tested-drop", Metric: MetricTestedNodes, Change: ChangeDelta, Op: "<=", Value: -3})
+	st := &State{Rules: make(map[string]*RuleState)}
+	now := time.Now()
+
+	// 没有上一次的值时不比较
+	if alerts := rs.Evaluate(&models.TestReport{TestedNodes: 10}, nil, st, now); len(alerts) != 0 {
+		t.Fatalf("alert without a previous value: %v", alerts)
+	}
+	// 上一次的值取自状态
+	alerts := rs.Evaluate(&models.TestReport{TestedNodes: 6}, nil, st, now)
+	if len(alerts) != 1 || alerts[0].Value != -4 || alerts[0].Previous == nil || *alerts[0].Previous != 10 {
+		t.Fatalf("alerts = %+v, want one with value -4 and previous 10", alerts)
+	}
+	// 状态中没有值时退回上一次的报告
+	st = &State{Rules: make(map[string]*RuleState)}
+	alerts = rs.Evaluate(&models.TestReport{TestedNodes: 6}, &models.TestReport{TestedNodes: 8}, st, now)
+	if len(alerts) != 0 {
+		t.Errorf("delta -2 fired: %+v", alerts)
+	}
+}
@@ -0,0 +1,233 @@
+package alert
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+)
+
+// 告警级别
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// 可用的指标
+const (
+	MetricAvailableNodes = "available_nodes" // 服务可用 (并满足过滤条件) 的节点数
+	MetricAvailableRatio = "available_ratio" // 上述节点数占已测试节点的比例
+	MetricSuccessNodes   = "success_nodes"   // 至少一个 AI 服务可用的节点数
+	MetricTestedNodes    = "tested_nodes"    // 已测试节点数
+	MetricTestedRatio    = "tested_ratio"    // 已测试节点占订阅节点的比例
+)
+
+// 与上一次运行比较的方式
+const (
+	ChangeDelta   = "delta"   // 本次值 - 上次值
+	ChangePercent = "percent" // (本次值 - 上次值) / 上次值 * 100
+)
+
+// RuleSet 告警规则文件 (YAML)
+type RuleSet struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule 一条告警规则
+// 例："美国节点中 Netflix 完整解锁的少于 2 个"
+//
+//	metric: available_nodes, service: netflix, region: US, detail: Full, op: "<", value: 2
+type Rule struct {
+	Name        string        `yaml:"name"`
+	Severity    string        `yaml:"severity"` // info / warning / critical，默认 warning
+	Description string        `yaml:"description"`
+	Metric      string        `yaml:"metric"`
+	Service     string        `yaml:"service"`    // available_nodes / available_ratio 必填
+	Region      string        `yaml:"region"`     // 只统计检测到该地区的节点
+	Detail      string        `yaml:"detail"`     // 只统计详情包含该文本的结果，如 Netflix 的 Full
+	NodeMatch   string        `yaml:"node_match"` // 只统计名称匹配该正则的节点
+	Change      string        `yaml:"change"`     // 为空比较当前值；delta / percent 比较与上次运行的变化
+	Op          string        `yaml:"op"`         // < <= > >= == !=
+	Value       float64       `yaml:"value"`
+	Cooldown    time.Duration `yaml:"cooldown"` // 持续触发时重复发送的间隔，0 表示只在开始触发时发送一次
+
+	nodeRe *regexp.Regexp
+}
+
+// LoadRules 读取并校验规则文件
+func LoadRules(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rs RuleSet
+	if err := yaml.Unmarshal(data, &rs); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+	if err := rs.compile(); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+func (rs *RuleSet) compile() error {
+	seen := make(map[string]bool)
+	for i := range rs.Rules {
+		r := &rs.Rules[i]
+		if r.Name == "" {
+			return fmt.Errorf("rule #%d: name is required", i+1)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("rule %s: duplicate name", r.Name)
+		}
+		seen[r.Name] = true
+
+		switch r.Severity {
+		case "":
+			r.Severity = SeverityWarning
+		case SeverityInfo, SeverityWarning, SeverityCritical:
+		default:
+			return fmt.Errorf("rule %s: unknown severity %q", r.Name, r.Severity)
+		}
+
+		switch r.Metric {
+		case MetricAvailableNodes, MetricAvailableRatio:
+			if !tester.IsService(r.Service) {
+				return fmt.Errorf("rule %s: unknown service %q", r.Name, r.Service)
+			}
+		case MetricSuccessNodes, MetricTestedNodes, MetricTestedRatio:
+		default:
+			return fmt.Errorf("rule %s: unknown metric %q", r.Name, r.Metric)
+		}
+
+		switch r.Change {
+		case "", ChangeDelta, ChangePercent:
+		default:
+			return fmt.Errorf("rule %s: unknown change %q", r.Name, r.Change)
+		}
+
+		if _, ok := compare(r.Op, 0, 0); !ok {
+			return fmt.Errorf("rule %s: unknown op %q", r.Name, r.Op)
+		}
+
+		if r.NodeMatch != "" {
+			re, err := regexp.Compile(r.NodeMatch)
+			if err != nil {
+				return fmt.Errorf("rule %s: invalid node_match: %w", r.Name, err)
+			}
+			r.nodeRe = re
+		}
+	}
+	return nil
+}
+
+// value 计算规则指标在某次报告上的值
+func (r *Rule) value(report *models.TestReport) float64 {
+	switch r.Metric {
+	case MetricSuccessNodes:
+		return float64(report.SuccessNodes)
+	case MetricTestedNodes:
+		return float64(report.TestedNodes)
+	case MetricTestedRatio:
+		if report.TotalNodes == 0 {
+			return 0
+		}
+		return float64(report.TestedNodes) / float64(report.TotalNodes)
+	}
+
+	count, tested := 0, 0
+	for _, node := range report.Results {
+		if r.nodeRe != nil && !r.nodeRe.MatchString(node.NodeName) {
+			continue
+		}
+		tested++
+		if r.matches(node) {
+			count++
+		}
+	}
+	if r.Metric == MetricAvailableRatio {
+		if tested == 0 {
+			return 0
+		}
+		return float64(count) / float64(tested)
+	}
+	return float64(count)
+}
+
+// matches 节点上该服务是否可用并满足地区、详情过滤条件
+func (r *Rule) matches(node models.NodeTestResult) bool {
+	var available bool
+	var regions []string
+	var detail string
+	if t, ok := node.Tests[r.Service]; ok {
+		available, regions = t.Available, []string{t.Country, t.Region}
+	} else if t, ok := node.StreamTests[r.Service]; ok {
+		available, regions, detail = t.Available, []string{t.Region}, t.Details
+	}
+	if !available {
+		return false
+	}
+	if r.Region != "" && !containsFold(regions, r.Region) {
+		return false
+	}
+	if r.Detail != "" && !strings.Contains(strings.ToLower(detail), strings.ToLower(r.Detail)) {
+		return false
+	}
+	return true
+}
+
+// describe 规则所统计对象的简短描述，用于告警文本
+func (r *Rule) describe() string {
+	parts := []string{r.Metric}
+	if r.Service != "" {
+		parts = append(parts, r.Service)
+	}
+	if r.Region != "" {
+		parts = append(parts, "region="+r.Region)
+	}
+	if r.Detail != "" {
+		parts = append(parts, "detail="+r.Detail)
+	}
+	if r.NodeMatch != "" {
+		parts = append(parts, "node=~"+r.NodeMatch)
+	}
+	s := strings.Join(parts, " ")
+	if r.Change != "" {
+		s += " (" + r.Change + " vs previous run)"
+	}
+	return s
+}
+
+func compare(op string, a, b float64) (bool, bool) {
+	switch op {
+	case "<":
+		return a < b, true
+	case "<=":
+		return a <= b, true
+	case ">":
+		return a > b, true
+	case ">=":
+		return a >= b, true
+	case "==":
+		return a == b, true
+	case "!=":
+		return a != b, true
+	}
+	return false, false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
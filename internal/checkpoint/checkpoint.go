@@ -0,0 +1,112 @@
+package checkpoint
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"Clash-tester/pkg/models"
+)
+
+// Header 检查点文件的首行，记录本次运行的基本信息
+type Header struct {
+	TestTime time.Time `json:"test_time"`
+	Source   string    `json:"source"`
+	Filter   string    `json:"filter,omitempty"` // 节点筛选条件，续测时与 Source 一起比较
+}
+
+// record 检查点文件中的一行 (JSON Lines)
+type record struct {
+	Header *Header                `json:"header,omitempty"`
+	Result *models.NodeTestResult `json:"result,omitempty"`
+}
+
+// State 从检查点恢复出的运行状态
+type State struct {
+	Header  Header
+	Results map[string]models.NodeTestResult // key: 节点指纹
+}
+
+// Writer 以追加方式写入检查点，每条结果落盘后才返回
+type Writer struct {
+	file *os.File
+	enc  *json.Encoder
+}
+
+// Create 创建检查点文件
+// resume 为 true 时在已有文件后追加，否则覆盖旧文件并写入新的 Header
+func Create(path string, header Header, resume bool) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{file: file, enc: json.NewEncoder(file)}
+
+	// 续测时沿用旧文件中的 Header
+	if info, _ := file.Stat(); info == nil || info.Size() == 0 {
+		if err := w.write(record{Header: &header}); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// Append 追加一个节点的测试结果
+func (w *Writer) Append(result models.NodeTestResult) error {
+	return w.write(record{Result: &result})
+}
+
+func (w *Writer) write(r record) error {
+	if err := w.enc.Encode(r); err != nil {
+		return err
+	}
+	// 立即落盘，进程崩溃时最多丢失正在写的一行
+	return w.file.Sync()
+}
+
+// Close 关闭检查点文件
+func (w *Writer) Close() error {
+	return w.file.Close()
+}
+
+// Load 读取检查点文件
+// 进程崩溃可能留下写了一半的最后一行，无法解析的行会被跳过
+func Load(path string) (*State, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	state := &State{Results: make(map[string]models.NodeTestResult)}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		if r.Header != nil {
+			state.Header = *r.Header
+		}
+		if r.Result != nil && r.Result.Fingerprint != "" {
+			state.Results[r.Result.Fingerprint] = *r.Result
+		}
+	}
+
+	return state, scanner.Err()
+}
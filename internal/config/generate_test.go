@@ -0,0 +1,184 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"Clash-tester/pkg/models"
+)
+
+const generateNodes = `proxies:
+  - {name: "reality", type: vless, server: r.example, port: 443, uuid: u1, tls: true, servername: www.example.com, flow: xtls-rprx-vision, reality-opts: {public-key: pk, short-id: ab}}
+  - {name: "reality-fp", type: vless, server: f.example, port: 443, uuid: u2, tls: true, client-fingerprint: firefox, reality-opts: {public-key: pk}}
+  - {name: "trojan", type: trojan, server: t.example, port: 443, password: p, sni: t.example}
+  - {name: "ws", type: vmess, server: w.example, port: 443, uuid: u3, tls: true, network: ws, ws-opts: {path: /ray, headers: {Host: w.example}}}
+  - {name: "dup", type: ss, server: d1.example, port: 1, cipher: aes-128-gcm, password: x}
+  - {name: "dup", type: ss, server: d2.example, port: 2, cipher: aes-128-gcm, password: x}
+  - {name: "snell", type: snell, server: s.example, port: 1, psk: x}
+`
+
+func loadGenerateNodes(t *testing.T) []models.ProxyNode {
+	t.Helper()
+	var sub struct {
+		Proxies []models.ProxyNode `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal([]byte(generateNodes), &sub); err != nil {
+		t.Fatal(err)
+	}
+	return sub.Proxies
+}
+
+func readJSON(t *testing.T, path string) map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// dig 按键路径读取嵌套的 JSON 值
+func dig(v interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+func TestGenerateSingBoxConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sing-box.json")
+	unsupported, err := GenerateSingBoxConfig(loadGenerateNodes(t), path, 7890, 9090)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unsupported) != 1 || unsupported["snell"] == nil {
+		t.Errorf("unsupported = %v, want only snell", unsupported)
+	}
+
+	config := readJSON(t, path)
+	outbounds := map[string]interface{}{}
+	for _, o := range config["outbounds"].([]interface{}) {
+		tag := dig(o, "tag").(string)
+		if _, dup := outbounds[tag]; dup {
+			t.Errorf("duplicate outbound tag %q", tag)
+		}
+		outbounds[tag] = o
+	}
+	if got := len(dig(outbounds[SingBoxSelector], "outbounds").([]interface{})); got != 5 {
+		t.Errorf("selector has %d outbounds, want 5", got)
+	}
+	if server := dig(outbounds["dup"], "server"); server != "d1.example" {
+		t.Errorf("dup server = %v, want the first node d1.example", server)
+	}
+
+	tests := []struct {
+		tag, want string
+	}{
+		{"reality", "chrome"},
+		{"reality-fp", "firefox"},
+		{"trojan", ""},
+	}
+	for _, tt := range tests {
+		tls := dig(outbounds[tt.tag], "tls")
+		fp, _ := dig(tls, "utls", "fingerprint").(string)
+		if fp != tt.want {
+			t.Errorf("%s: utls fingerprint = %q, want %q", tt.tag, fp, tt.want)
+		}
+		if tt.want != "" && dig(tls, "utls", "enabled") != true {
+			t.Errorf("%s: utls not enabled", tt.tag)
+		}
+	}
+	if reality := dig(outbounds["reality"], "tls", "reality"); dig(reality, "public_key") != "pk" || dig(reality, "short_id") != "ab" {
+		t.Errorf("reality = %v", reality)
+	}
+	if sni := dig(outbounds["reality"], "tls", "server_name"); sni != "www.example.com" {
+		t.Errorf("reality server_name = %v", sni)
+	}
+	if transport := dig(outbounds["ws"], "transport"); dig(transport, "type") != "ws" || dig(transport, "path") != "/ray" {
+		t.Errorf("ws transport = %v", transport)
+	}
+}
+
+func TestGenerateXrayConfig(t *testing.T) {
+	nodes := make(map[string]models.ProxyNode)
+	for _, node := range loadGenerateNodes(t) {
+		nodes[node.Name] = node
+	}
+
+	tests := []struct {
+		name        string
+		security    string
+		fingerprint string
+		network     string
+	}{
+		{"reality", "reality", "chrome", "tcp"},
+		{"reality-fp", "reality", "firefox", "tcp"},
+		{"trojan", "tls", "", "tcp"},
+		{"ws", "tls", "", "ws"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := nodes[tt.name]
+			path := filepath.Join(t.TempDir(), "xray.json")
+			if err := GenerateXrayConfig(&node, path, 7890); err != nil {
+				t.Fatal(err)
+			}
+			outbounds := readJSON(t, path)["outbounds"].([]interface{})
+			if len(outbounds) != 1 {
+				t.Fatalf("%d outbounds, want 1", len(outbounds))
+			}
+			stream := dig(outbounds[0], "streamSettings")
+			if got := dig(stream, "security"); got != tt.security {
+				t.Errorf("security = %v, want %s", got, tt.security)
+			}
+			if got := dig(stream, "network"); got != tt.network {
+				t.Errorf("network = %v, want %s", got, tt.network)
+			}
+			if tt.security == "reality" {
+				if got := dig(stream, "realitySettings", "fingerprint"); got != tt.fingerprint {
+					t.Errorf("reality fingerprint = %v, want %s", got, tt.fingerprint)
+				}
+			}
+		})
+	}
+
+	snell := nodes["snell"]
+	if err := GenerateXrayConfig(&snell, filepath.Join(t.TempDir(), "xray.json"), 7890); err == nil {
+		t.Error("snell node converted, want an error")
+	}
+}
+
+func TestGenerateMihomoConfigDuplicates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mihomo.yaml")
+	if err := GenerateMihomoConfig(loadGenerateNodes(t), path, 7890, 9090); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Proxies []models.ProxyNode `yaml:"proxies"`
+		Groups  []struct {
+			Proxies []string `yaml:"proxies"`
+		} `yaml:"proxy-groups"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Proxies) != 6 || len(config.Groups[0].Proxies) != 6 {
+		t.Errorf("%d proxies and %d group members, want 6 each", len(config.Proxies), len(config.Groups[0].Proxies))
+	}
+}
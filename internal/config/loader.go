@@ -1,6 +1,7 @@
 package config
 
 import (
+	"context"
 	"encoding/base64"
 	"io"
 	"net/http"
@@ -15,18 +16,22 @@ type LoaderConfig struct {
 }
 
 // Load 加载配置（自动判断在线/本地）
-func Load(cfg LoaderConfig) ([]byte, error) {
+func Load(ctx context.Context, cfg LoaderConfig) ([]byte, error) {
 	if strings.HasPrefix(cfg.Source, "http://") ||
 		strings.HasPrefix(cfg.Source, "https://") {
-		return loadFromURL(cfg.Source, cfg.Timeout)
+		return loadFromURL(ctx, cfg.Source, cfg.Timeout)
 	}
 	return loadFromFile(cfg.Source)
 }
 
 // loadFromURL 从在线订阅加载
-func loadFromURL(url string, timeout int) ([]byte, error) {
+func loadFromURL(ctx context.Context, url string, timeout int) ([]byte, error) {
 	client := &http.Client{Timeout: time.Duration(timeout) * time.Second}
-	resp, err := client.Get(url)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -8,7 +8,9 @@ import (
 )
 
 // GenerateMihomoConfig 为测试生成mihomo配置
+// 重名节点只写入第一个，mihomo 遇到重名的代理会拒绝整个配置
 func GenerateMihomoConfig(nodes []models.ProxyNode, outputPath string, port, apiPort int) error {
+	nodes = uniqueNodes(nodes)
 	config := map[string]interface{}{
 		"port":                port,
 		"socks-port":          port + 1,
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"Clash-tester/pkg/models"
+)
+
+// defaultFingerprint 节点未指定 client-fingerprint 而核心要求 uTLS 指纹时 (如 Reality) 使用的指纹
+const defaultFingerprint = "chrome"
+
+// uniqueNodes 去掉重名节点，只保留第一个
+func uniqueNodes(nodes []models.ProxyNode) []models.ProxyNode {
+	seen := make(map[string]bool, len(nodes))
+	unique := make([]models.ProxyNode, 0, len(nodes))
+	for _, node := range nodes {
+		if seen[node.Name] {
+			continue
+		}
+		seen[node.Name] = true
+		unique = append(unique, node)
+	}
+	return unique
+}
+
+// nodeString 读取节点的字符串参数，依次尝试多个键名 (Clash 配置中同一参数常有多种写法)
+func nodeString(node models.ProxyNode, keys ...string) string {
+	for _, key := range keys {
+		if v, ok := node.Params[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+// nodeBool 读取节点的布尔参数
+func nodeBool(node models.ProxyNode, keys ...string) bool {
+	for _, key := range keys {
+		switch v := node.Params[key].(type) {
+		case bool:
+			return v
+		case string:
+			b, _ := strconv.ParseBool(v)
+			return b
+		}
+	}
+	return false
+}
+
+// nodeInt 读取节点的整数参数
+func nodeInt(node models.ProxyNode, keys ...string) int {
+	for _, key := range keys {
+		switch v := node.Params[key].(type) {
+		case int:
+			return v
+		case float64:
+			return int(v)
+		case string:
+			if n, err := strconv.Atoi(v); err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// nodeMap 读取节点的嵌套参数，如 ws-opts、reality-opts
+func nodeMap(node models.ProxyNode, key string) map[string]interface{} {
+	m, _ := node.Params[key].(map[string]interface{})
+	return m
+}
+
+// nodeStrings 读取节点的字符串列表参数，如 alpn
+func nodeStrings(node models.ProxyNode, key string) []string {
+	list, _ := node.Params[key].([]interface{})
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		out = append(out, fmt.Sprint(v))
+	}
+	return out
+}
+
+// mapString 读取嵌套参数中的字符串
+func mapString(m map[string]interface{}, key string) string {
+	if v, ok := m[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// nodeHost 节点的 TLS 服务器名，未设置时使用 server
+func nodeHost(node models.ProxyNode) string {
+	if sni := nodeString(node, "sni", "servername"); sni != "" {
+		return sni
+	}
+	return node.Server
+}
+
+// writeJSONConfig 写入 JSON 格式的核心配置
+func writeJSONConfig(path string, config interface{}) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
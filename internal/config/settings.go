@@ -0,0 +1,583 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+)
+
+// EnvPrefix 覆盖配置项的环境变量前缀
+// 环境变量名由配置路径转换而来，如 workers.count -> CLASH_TESTER_WORKERS_COUNT
+const EnvPrefix = "CLASH_TESTER_"
+
+// Settings 全部测试设置，对应 -config 指定的 YAML 文件
+// 优先级：默认值 < 配置文件 < 环境变量 < 命令行参数
+type Settings struct {
+	Source   SourceSettings   `yaml:"source"`
+	Filter   FilterSettings   `yaml:"filter"`
+	Checks   CheckSettings    `yaml:"checks"`
+	Workers  WorkerSettings   `yaml:"workers"`
+	Run      RunSettings      `yaml:"run"`
+	Output   OutputSettings   `yaml:"output"`
+	Gate     GateSettings     `yaml:"gate"`
+	Canary   CanarySettings   `yaml:"canary"`
+	Notify   NotifySettings   `yaml:"notify"`
+	Schedule ScheduleSettings `yaml:"schedule"`
+}
+
+// SourceSettings 订阅来源
+type SourceSettings struct {
+	URL     string        `yaml:"url"`     // 订阅 URL 或本地 YAML 文件路径
+	Timeout time.Duration `yaml:"timeout"` // 下载订阅的超时时间
+}
+
+// FilterSettings 节点过滤，先按 names 与 include 保留，再按 exclude 排除
+type FilterSettings struct {
+	Names   []string `yaml:"names"`   // 只保留这些名称的节点 (精确匹配)，为空表示全部
+	Include string   `yaml:"include"` // 节点名称正则，为空表示全部保留
+	Exclude string   `yaml:"exclude"` // 节点名称正则，为空表示不排除
+	Types   []string `yaml:"types"`   // 只保留这些协议类型，为空表示全部
+}
+
+// CheckSettings 检测项与请求参数
+type CheckSettings struct {
+	Services  []string          `yaml:"services"` // 启用的检测项，为空表示全部
+	Timeout   time.Duration     `yaml:"timeout"`  // 单次请求超时
+	Retries   int               `yaml:"retries"`  // 失败后的重试次数
+	SpeedTest SpeedTestSettings `yaml:"speedtest"`
+}
+
+// SpeedTestSettings 下载测速
+type SpeedTestSettings struct {
+	URL          string        `yaml:"url"` // 为空表示不测速
+	MaxMB        int           `yaml:"max_mb"`
+	Duration     time.Duration `yaml:"duration"`
+	UnlockedOnly bool          `yaml:"unlocked_only"`
+}
+
+// WorkerSettings 并发 Worker 与代理核心
+type WorkerSettings struct {
+	Count       int           `yaml:"count"`
+	Core        string        `yaml:"core"`      // 代理核心：mihomo / sing-box / xray
+	CorePath    string        `yaml:"core_path"` // 代理核心可执行文件路径，为空时 mihomo 使用 mihomo 项，其他核心在 PATH 中查找
+	Mihomo      string        `yaml:"mihomo"`    // mihomo 可执行文件路径
+	PortBase    int           `yaml:"port_base"` // 第 i 个 Worker 的代理端口为 port_base + i*port_step
+	PortStep    int           `yaml:"port_step"`
+	APIPortBase int           `yaml:"api_port_base"` // 第 i 个 Worker 的控制端口为 api_port_base + i
+	NodeTimeout time.Duration `yaml:"node_timeout"`  // 单个节点的时间预算，0 表示不限
+}
+
+// RunSettings 单次运行的控制
+type RunSettings struct {
+	Timeout        time.Duration            `yaml:"timeout"` // 整次运行的时间上限，0 表示不限
+	ShutdownGrace  time.Duration            `yaml:"shutdown_grace"`
+	Checkpoint     string                   `yaml:"checkpoint"` // 默认 <output.dir>/checkpoint.jsonl
+	Resume         bool                     `yaml:"resume"`
+	Rounds         int                      `yaml:"rounds"`
+	RoundWindow    time.Duration            `yaml:"round_window"`
+	State          string                   `yaml:"state"` // 增量测试状态文件，为空表示每次全量测试
+	DefaultTTL     time.Duration            `yaml:"default_ttl"`
+	TTL            map[string]time.Duration `yaml:"ttl"`
+	UnavailableTTL time.Duration            `yaml:"unavailable_ttl"` // 不可用结果的有效期
+	UI             string                   `yaml:"ui"`              // auto / tui / plain
+}
+
+// OutputSettings 输出
+type OutputSettings struct {
+	Dir             string        `yaml:"dir"`
+	Formats         []string      `yaml:"formats"`
+	MapOutput       string        `yaml:"map_output"`
+	MapPrevious     string        `yaml:"map_previous"`
+	MapMaxAge       time.Duration `yaml:"map_max_age"`
+	MapBackup       bool          `yaml:"map_backup"`
+	MapMinKeep      float64       `yaml:"map_min_keep"`
+	MapForce        bool          `yaml:"map_force"` // 跳过 tags.json 缩水保护
+	HysteresisUp    int           `yaml:"hysteresis_up"`
+	HysteresisDown  int           `yaml:"hysteresis_down"`
+	MetricsTextfile string        `yaml:"metrics_textfile"`
+	HARDir          string        `yaml:"har_dir"` // 失败检测的 HAR 保存目录，为空表示不保存
+}
+
+// GateSettings 质量检查
+type GateSettings struct {
+	MinTestedRatio  float64 `yaml:"min_tested_ratio"`
+	MinSuccessNodes int     `yaml:"min_success_nodes"`
+}
+
+// CanarySettings 本机连通性自检
+type CanarySettings struct {
+	Enabled        bool   `yaml:"enabled"`
+	URL            string `yaml:"url"`
+	ReferenceProxy string `yaml:"reference_proxy"`
+}
+
+// NotifySettings 通知与告警
+type NotifySettings struct {
+	Config     string `yaml:"config"`      // 通知渠道配置文件
+	Alerts     string `yaml:"alerts"`      // 告警规则文件
+	AlertState string `yaml:"alert_state"` // 默认 <output.dir>/alert_state.json
+	State      string `yaml:"state"`       // 各服务上一次的可用节点数，默认 <output.dir>/notify_state.json
+}
+
+// ScheduleSettings 常驻模式
+type ScheduleSettings struct {
+	Serve    string        `yaml:"serve"`    // HTTP 监听地址，为空表示单次运行
+	Interval time.Duration `yaml:"interval"` // 两次运行之间的间隔
+}
+
+// DefaultSettings 返回默认设置，与命令行参数的默认值一致
+func DefaultSettings() Settings {
+	return Settings{
+		Source: SourceSettings{Timeout: 30 * time.Second},
+		Checks: CheckSettings{
+			Timeout: 10 * time.Second,
+			Retries: 2,
+			SpeedTest: SpeedTestSettings{
+				MaxMB:    20,
+				Duration: 10 * time.Second,
+			},
+		},
+		Workers: WorkerSettings{
+			Count:       5,
+			Core:        "mihomo",
+			Mihomo:      "mihomo.exe",
+			PortBase:    7890,
+			PortStep:    10,
+			APIPortBase: 9090,
+			NodeTimeout: 5 * time.Minute,
+		},
+		Run: RunSettings{
+			ShutdownGrace:  15 * time.Second,
+			Rounds:         1,
+			RoundWindow:    10 * time.Minute,
+			UnavailableTTL: 30 * time.Minute,
+			UI:             "auto",
+		},
+		Output: OutputSettings{
+			Dir:            "result",
+			Formats:        []string{"json", "tags", "html"},
+			MapMaxAge:      24 * time.Hour,
+			MapMinKeep:     0.5,
+			HysteresisUp:   1,
+			HysteresisDown: 1,
+		},
+		Schedule: ScheduleSettings{Interval: time.Hour},
+	}
+}
+
+// Issue 配置中的一个问题
+type Issue struct {
+	Line    int    // 所在行号，0 表示未知
+	Path    string // 配置路径，如 workers.count
+	Message string
+}
+
+func (i Issue) String() string {
+	var b strings.Builder
+	if i.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", i.Line)
+	}
+	if i.Path != "" {
+		b.WriteString(i.Path + ": ")
+	}
+	b.WriteString(i.Message)
+	return b.String()
+}
+
+// IssuesError 多个配置问题
+type IssuesError []Issue
+
+func (e IssuesError) Error() string {
+	parts := make([]string, len(e))
+	for i, issue := range e {
+		parts[i] = issue.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+var yamlLineRe = regexp.MustCompile(`line (\d+): (.*)`)
+
+// LoadSettings 读取配置文件并覆盖到 s 上
+// 未知字段、类型错误与取值错误都会以带行号的 IssuesError 返回
+func LoadSettings(path string, s *Settings) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return IssuesError(yamlIssues(err))
+	}
+
+	// 类型错误与未知字段不会中断解码，其余字段仍会生效，与取值错误一并报告
+	var issues []Issue
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(s); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return IssuesError(yamlIssues(err))
+		}
+		issues = yamlIssues(err)
+	}
+
+	lines := nodeLines(&root)
+	for _, issue := range s.Validate() {
+		issue.Line = lines[issue.Path]
+		issues = append(issues, issue)
+	}
+	if len(issues) > 0 {
+		sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+		return IssuesError(issues)
+	}
+	return nil
+}
+
+// yamlIssues 将 yaml 库的错误拆分为带行号的问题
+func yamlIssues(err error) []Issue {
+	var msgs []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	} else {
+		msgs = []string{strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+
+	issues := make([]Issue, 0, len(msgs))
+	for _, msg := range msgs {
+		issue := Issue{Message: msg}
+		if m := yamlLineRe.FindStringSubmatch(msg); m != nil {
+			issue.Line, _ = strconv.Atoi(m[1])
+			issue.Message = m[2]
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// nodeLines 建立 配置路径 -> 行号 的索引
+func nodeLines(root *yaml.Node) map[string]int {
+	lines := make(map[string]int)
+	var walk func(n *yaml.Node, path string)
+	walk = func(n *yaml.Node, path string) {
+		switch n.Kind {
+		case yaml.DocumentNode:
+			for _, c := range n.Content {
+				walk(c, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key := n.Content[i].Value
+				if path != "" {
+					key = path + "." + key
+				}
+				lines[key] = n.Content[i].Line
+				walk(n.Content[i+1], key)
+			}
+		}
+	}
+	walk(root, "")
+	return lines
+}
+
+// Validate 检查取值是否合法
+func (s *Settings) Validate() []Issue {
+	var issues []Issue
+	add := func(path, format string, args ...any) {
+		issues = append(issues, Issue{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Source.Timeout <= 0 {
+		add("source.timeout", "must be positive")
+	}
+	if _, err := regexp.Compile(s.Filter.Include); err != nil {
+		add("filter.include", "invalid regexp: %v", err)
+	}
+	if _, err := regexp.Compile(s.Filter.Exclude); err != nil {
+		add("filter.exclude", "invalid regexp: %v", err)
+	}
+	for _, name := range s.Checks.Services {
+		if !tester.IsService(name) {
+			add("checks.services", "unknown service %q", name)
+		}
+	}
+	if s.Checks.Timeout <= 0 {
+		add("checks.timeout", "must be positive")
+	}
+	if s.Checks.Retries < 0 {
+		add("checks.retries", "must not be negative")
+	}
+	if s.Checks.SpeedTest.MaxMB <= 0 {
+		add("checks.speedtest.max_mb", "must be positive")
+	}
+	if s.Workers.Count < 1 {
+		add("workers.count", "must be at least 1")
+	}
+	switch s.Workers.Core {
+	case "mihomo", "sing-box", "xray":
+	default:
+		add("workers.core", "must be one of mihomo, sing-box, xray")
+	}
+	if s.Workers.PortBase < 1 || s.Workers.PortBase+s.Workers.Count*s.Workers.PortStep > 65535 {
+		add("workers.port_base", "port range %d..%d out of bounds", s.Workers.PortBase, s.Workers.PortBase+s.Workers.Count*s.Workers.PortStep)
+	}
+	if s.Workers.PortStep < 1 {
+		add("workers.port_step", "must be at least 1")
+	}
+	if s.Workers.APIPortBase < 1 || s.Workers.APIPortBase+s.Workers.Count > 65535 {
+		add("workers.api_port_base", "port range %d..%d out of bounds", s.Workers.APIPortBase, s.Workers.APIPortBase+s.Workers.Count)
+	}
+	if s.Run.Rounds < 1 {
+		add("run.rounds", "must be at least 1")
+	}
+	for name := range s.Run.TTL {
+		if !tester.IsService(name) {
+			add("run.ttl."+name, "unknown service %q", name)
+		}
+	}
+	switch s.Run.UI {
+	case "auto", "tui", "plain":
+	default:
+		add("run.ui", "must be one of auto, tui, plain")
+	}
+	for _, f := range s.Output.Formats {
+		switch f {
+		case "json", "tags", "csv", "md", "markdown", "html":
+		default:
+			add("output.formats", "unknown format %q", f)
+		}
+	}
+	if s.Output.MapMinKeep < 0 || s.Output.MapMinKeep > 1 {
+		add("output.map_min_keep", "must be between 0 and 1")
+	}
+	if s.Output.HysteresisUp < 1 {
+		add("output.hysteresis_up", "must be at least 1")
+	}
+	if s.Output.HysteresisDown < 1 {
+		add("output.hysteresis_down", "must be at least 1")
+	}
+	if s.Gate.MinTestedRatio < 0 || s.Gate.MinTestedRatio > 1 {
+		add("gate.min_tested_ratio", "must be between 0 and 1")
+	}
+	if s.Schedule.Serve != "" && s.Schedule.Interval <= 0 {
+		add("schedule.interval", "must be positive")
+	}
+	return issues
+}
+
+// ApplyEnv 用环境变量覆盖配置项，返回取值非法的环境变量
+// 兼容旧的 SUB_URL 与 INTERVAL (秒) 环境变量，优先级低于 CLASH_TESTER_*
+func ApplyEnv(s *Settings, lookup func(string) (string, bool)) []Issue {
+	var issues []Issue
+
+	if v, ok := lookup("SUB_URL"); ok && v != "" {
+		s.Source.URL = v
+	}
+	if v, ok := lookup("INTERVAL"); ok && v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+			s.Schedule.Interval = time.Duration(secs) * time.Second
+		} else {
+			issues = append(issues, Issue{Path: "INTERVAL", Message: "must be a positive number of seconds"})
+		}
+	}
+
+	for _, f := range settingFields(reflect.ValueOf(s).Elem(), "") {
+		name := EnvName(f.path)
+		v, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setFromString(f.value, v); err != nil {
+			issues = append(issues, Issue{Path: name, Message: err.Error()})
+		}
+	}
+	return issues
+}
+
+// EnvName 配置路径对应的环境变量名
+func EnvName(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// EnvNames 列出全部可用的环境变量及对应的配置路径
+func EnvNames() []string {
+	s := DefaultSettings()
+	var names []string
+	for _, f := range settingFields(reflect.ValueOf(&s).Elem(), "") {
+		names = append(names, EnvName(f.path)+" -> "+f.path)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type settingField struct {
+	path  string
+	value reflect.Value
+}
+
+// settingFields 递归列出全部叶子配置项
+func settingFields(v reflect.Value, prefix string) []settingField {
+	var fields []settingField
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		path := tag
+		if prefix != "" {
+			path = prefix + "." + tag
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			fields = append(fields, settingFields(fv, path)...)
+			continue
+		}
+		fields = append(fields, settingField{path: path, value: fv})
+	}
+	return fields
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setFromString 按字段类型解析字符串
+// 列表为逗号分隔；ttl 这类映射写作 key=value,key=value
+func setFromString(v reflect.Value, s string) error {
+	s = strings.TrimSpace(s)
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		v.Set(reflect.ValueOf(SplitList(s)))
+	case v.Kind() == reflect.Map && v.Type().Elem() == durationType:
+		m, err := ParseDurationMap(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// SplitList 解析逗号分隔的列表，忽略空项
+func SplitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// ParseDurationMap 解析形如 "netflix=24h,openai=1h" 的映射
+func ParseDurationMap(spec string) (map[string]time.Duration, error) {
+	m := make(map[string]time.Duration)
+	for _, item := range SplitList(spec) {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid item %q, expected name=duration", item)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration for %s: %w", name, err)
+		}
+		m[strings.TrimSpace(name)] = d
+	}
+	return m, nil
+}
+
+// Filter 按设置过滤节点
+func (f FilterSettings) Filter(nodes []models.ProxyNode) ([]models.ProxyNode, error) {
+	if len(f.Names) == 0 && f.Include == "" && f.Exclude == "" && len(f.Types) == 0 {
+		return nodes, nil
+	}
+	var include, exclude *regexp.Regexp
+	var err error
+	if f.Include != "" {
+		if include, err = regexp.Compile(f.Include); err != nil {
+			return nil, fmt.Errorf("invalid filter.include: %w", err)
+		}
+	}
+	if f.Exclude != "" {
+		if exclude, err = regexp.Compile(f.Exclude); err != nil {
+			return nil, fmt.Errorf("invalid filter.exclude: %w", err)
+		}
+	}
+
+	kept := make([]models.ProxyNode, 0, len(nodes))
+	for _, node := range nodes {
+		if len(f.Names) > 0 && !containsString(f.Names, node.Name) {
+			continue
+		}
+		if include != nil && !include.MatchString(node.Name) {
+			continue
+		}
+		if exclude != nil && exclude.MatchString(node.Name) {
+			continue
+		}
+		if len(f.Types) > 0 && !containsFold(f.Types, node.Type) {
+			continue
+		}
+		kept = append(kept, node)
+	}
+	return kept, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"fmt"
+
+	"Clash-tester/pkg/models"
+)
+
+// SingBoxSelector sing-box 配置中包含全部节点的 selector 出站，通过 Clash API 切换
+const SingBoxSelector = "GLOBAL"
+
+// GenerateSingBoxConfig 为测试生成 sing-box 配置
+// 本地 mixed 入站监听 port，Clash API 监听 apiPort；
+// 无法转换的节点不写入配置，以节点名为键返回原因；
+// 出站 tag 即节点名，重名节点只写入第一个 (与 mihomo 一致，重复的 tag 会使整个配置无效)
+func GenerateSingBoxConfig(nodes []models.ProxyNode, outputPath string, port, apiPort int) (map[string]error, error) {
+	unsupported := make(map[string]error)
+	outbounds := []map[string]interface{}{nil} // 第一个位置留给 selector
+	tags := make([]string, 0, len(nodes))
+	for _, node := range uniqueNodes(nodes) {
+		outbound, err := singBoxOutbound(node)
+		if err != nil {
+			unsupported[node.Name] = err
+			continue
+		}
+		outbounds = append(outbounds, outbound)
+		tags = append(tags, node.Name)
+	}
+	if len(tags) == 0 {
+		return unsupported, fmt.Errorf("no nodes can be converted for sing-box")
+	}
+	outbounds[0] = map[string]interface{}{
+		"type":      "selector",
+		"tag":       SingBoxSelector,
+		"outbounds": tags,
+	}
+
+	config := map[string]interface{}{
+		"log": map[string]interface{}{"disabled": true},
+		"inbounds": []map[string]interface{}{
+			{
+				"type":        "mixed",
+				"tag":         "mixed-in",
+				"listen":      "127.0.0.1",
+				"listen_port": port,
+			},
+		},
+		"outbounds": outbounds,
+		"route": map[string]interface{}{
+			"final": SingBoxSelector,
+		},
+		"experimental": map[string]interface{}{
+			"clash_api": map[string]interface{}{
+				"external_controller": fmt.Sprintf("127.0.0.1:%d", apiPort),
+			},
+		},
+	}
+
+	return unsupported, writeJSONConfig(outputPath, config)
+}
+
+// singBoxOutbound 将 Clash 格式的节点转换为 sing-box 出站
+func singBoxOutbound(node models.ProxyNode) (map[string]interface{}, error) {
+	out := map[string]interface{}{
+		"tag":         node.Name,
+		"server":      node.Server,
+		"server_port": node.Port,
+	}
+
+	switch node.Type {
+	case "ss":
+		if plugin := nodeString(node, "plugin"); plugin != "" {
+			return nil, fmt.Errorf("shadowsocks plugin %q is not supported", plugin)
+		}
+		out["type"] = "shadowsocks"
+		out["method"] = node.Cipher
+		out["password"] = node.Password
+	case "trojan":
+		out["type"] = "trojan"
+		out["password"] = node.Password
+		out["tls"] = singBoxTLS(node)
+	case "vmess":
+		out["type"] = "vmess"
+		out["uuid"] = node.UUID
+		out["alter_id"] = nodeInt(node, "alterId")
+		out["security"] = node.Cipher
+		if node.Cipher == "" {
+			out["security"] = "auto"
+		}
+		if nodeBool(node, "tls") {
+			out["tls"] = singBoxTLS(node)
+		}
+	case "vless":
+		out["type"] = "vless"
+		out["uuid"] = node.UUID
+		if flow := nodeString(node, "flow"); flow != "" {
+			out["flow"] = flow
+		}
+		if nodeBool(node, "tls") || nodeMap(node, "reality-opts") != nil {
+			out["tls"] = singBoxTLS(node)
+		}
+	case "hysteria2":
+		out["type"] = "hysteria2"
+		out["password"] = node.Password
+		out["tls"] = singBoxTLS(node)
+		if obfs := nodeString(node, "obfs"); obfs != "" {
+			out["obfs"] = map[string]interface{}{
+				"type":     obfs,
+				"password": nodeString(node, "obfs-password"),
+			}
+		}
+	default:
+		return nil, fmt.Errorf("type %q is not supported", node.Type)
+	}
+
+	if node.Type != "ss" && node.Type != "hysteria2" {
+		transport, err := singBoxTransport(node)
+		if err != nil {
+			return nil, err
+		}
+		if transport != nil {
+			out["transport"] = transport
+		}
+	}
+	return out, nil
+}
+
+// singBoxTLS 节点的 TLS 设置，含 Reality 与 uTLS 指纹
+// sing-box 的 Reality 必须启用 uTLS，节点未指定指纹时使用 defaultFingerprint
+func singBoxTLS(node models.ProxyNode) map[string]interface{} {
+	tls := map[string]interface{}{
+		"enabled":     true,
+		"server_name": nodeHost(node),
+		"insecure":    nodeBool(node, "skip-cert-verify"),
+	}
+	if alpn := nodeStrings(node, "alpn"); len(alpn) > 0 {
+		tls["alpn"] = alpn
+	}
+	fp := nodeString(node, "client-fingerprint")
+	reality := nodeMap(node, "reality-opts")
+	if fp == "" && reality != nil {
+		fp = defaultFingerprint
+	}
+	if fp != "" {
+		tls["utls"] = map[string]interface{}{"enabled": true, "fingerprint": fp}
+	}
+	if reality != nil {
+		tls["reality"] = map[string]interface{}{
+			"enabled":    true,
+			"public_key": mapString(reality, "public-key"),
+			"short_id":   mapString(reality, "short-id"),
+		}
+	}
+	return tls
+}
+
+// singBoxTransport 节点的传输层设置，tcp 返回 nil
+func singBoxTransport(node models.ProxyNode) (map[string]interface{}, error) {
+	switch network := nodeString(node, "network"); network {
+	case "", "tcp":
+		return nil, nil
+	case "ws":
+		ws := nodeMap(node, "ws-opts")
+		transport := map[string]interface{}{"type": "ws", "path": mapString(ws, "path")}
+		if headers, ok := ws["headers"].(map[string]interface{}); ok {
+			transport["headers"] = headers
+		}
+		return transport, nil
+	case "grpc":
+		return map[string]interface{}{
+			"type":         "grpc",
+			"service_name": mapString(nodeMap(node, "grpc-opts"), "grpc-service-name"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("network %q is not supported", network)
+	}
+}
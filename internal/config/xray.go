@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+
+	"Clash-tester/pkg/models"
+)
+
+// GenerateXrayConfig 为测试生成 xray 配置
+// xray 没有运行时切换出站的接口，配置中只包含一个节点，切换节点时重新生成并重启；
+// node 为 nil 时生成丢弃全部流量的配置，用于启动核心
+func GenerateXrayConfig(node *models.ProxyNode, outputPath string, port int) error {
+	outbound := map[string]interface{}{"protocol": "blackhole"}
+	if node != nil {
+		var err error
+		if outbound, err = xrayOutbound(*node); err != nil {
+			return err
+		}
+	}
+
+	config := map[string]interface{}{
+		"log": map[string]interface{}{"loglevel": "none"},
+		"inbounds": []map[string]interface{}{
+			{
+				"tag":      "http-in",
+				"listen":   "127.0.0.1",
+				"port":     port,
+				"protocol": "http",
+			},
+		},
+		"outbounds": []map[string]interface{}{outbound},
+	}
+
+	return writeJSONConfig(outputPath, config)
+}
+
+// xrayOutbound 将 Clash 格式的节点转换为 xray 出站
+func xrayOutbound(node models.ProxyNode) (map[string]interface{}, error) {
+	out := map[string]interface{}{"tag": "proxy"}
+	stream := map[string]interface{}{}
+
+	switch node.Type {
+	case "ss":
+		if plugin := nodeString(node, "plugin"); plugin != "" {
+			return nil, fmt.Errorf("shadowsocks plugin %q is not supported", plugin)
+		}
+		out["protocol"] = "shadowsocks"
+		out["settings"] = map[string]interface{}{
+			"servers": []map[string]interface{}{{
+				"address":  node.Server,
+				"port":     node.Port,
+				"method":   node.Cipher,
+				"password": node.Password,
+			}},
+		}
+		return out, nil
+	case "trojan":
+		out["protocol"] = "trojan"
+		out["settings"] = map[string]interface{}{
+			"servers": []map[string]interface{}{{
+				"address":  node.Server,
+				"port":     node.Port,
+				"password": node.Password,
+			}},
+		}
+		stream["security"] = "tls"
+		stream["tlsSettings"] = xrayTLS(node)
+	case "vmess":
+		security := node.Cipher
+		if security == "" {
+			security = "auto"
+		}
+		out["protocol"] = "vmess"
+		out["settings"] = map[string]interface{}{
+			"vnext": []map[string]interface{}{{
+				"address": node.Server,
+				"port":    node.Port,
+				"users": []map[string]interface{}{{
+					"id":       node.UUID,
+					"alterId":  nodeInt(node, "alterId"),
+					"security": security,
+				}},
+			}},
+		}
+		if nodeBool(node, "tls") {
+			stream["security"] = "tls"
+			stream["tlsSettings"] = xrayTLS(node)
+		}
+	case "vless":
+		user := map[string]interface{}{"id": node.UUID, "encryption": "none"}
+		if flow := nodeString(node, "flow"); flow != "" {
+			user["flow"] = flow
+		}
+		out["protocol"] = "vless"
+		out["settings"] = map[string]interface{}{
+			"vnext": []map[string]interface{}{{
+				"address": node.Server,
+				"port":    node.Port,
+				"users":   []map[string]interface{}{user},
+			}},
+		}
+		if reality := nodeMap(node, "reality-opts"); reality != nil {
+			// xray 的 Reality 必须指定指纹
+			fp := nodeString(node, "client-fingerprint")
+			if fp == "" {
+				fp = defaultFingerprint
+			}
+			stream["security"] = "reality"
+			stream["realitySettings"] = map[string]interface{}{
+				"serverName":  nodeHost(node),
+				"publicKey":   mapString(reality, "public-key"),
+				"shortId":     mapString(reality, "short-id"),
+				"fingerprint": fp,
+			}
+		} else if nodeBool(node, "tls") {
+			stream["security"] = "tls"
+			stream["tlsSettings"] = xrayTLS(node)
+		}
+	default:
+		return nil, fmt.Errorf("type %q is not supported by xray", node.Type)
+	}
+
+	switch network := nodeString(node, "network"); network {
+	case "", "tcp":
+		stream["network"] = "tcp"
+	case "ws":
+		ws := nodeMap(node, "ws-opts")
+		settings := map[string]interface{}{"path": mapString(ws, "path")}
+		if headers, ok := ws["headers"].(map[string]interface{}); ok {
+			settings["headers"] = headers
+		}
+		stream["network"] = "ws"
+		stream["wsSettings"] = settings
+	case "grpc":
+		stream["network"] = "grpc"
+		stream["grpcSettings"] = map[string]interface{}{
+			"serviceName": mapString(nodeMap(node, "grpc-opts"), "grpc-service-name"),
+		}
+	default:
+		return nil, fmt.Errorf("network %q is not supported", network)
+	}
+	out["streamSettings"] = stream
+	return out, nil
+}
+
+// xrayTLS 节点的 TLS 设置
+func xrayTLS(node models.ProxyNode) map[string]interface{} {
+	tls := map[string]interface{}{
+		"serverName":    nodeHost(node),
+		"allowInsecure": nodeBool(node, "skip-cert-verify"),
+	}
+	if alpn := nodeStrings(node, "alpn"); len(alpn) > 0 {
+		tls["alpn"] = alpn
+	}
+	if fp := nodeString(node, "client-fingerprint"); fp != "" {
+		tls["fingerprint"] = fp
+	}
+	return tls
+}
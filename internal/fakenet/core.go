@@ -0,0 +1,212 @@
+package fakenet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"time"
+
+	"Clash-tester/internal/proxy"
+)
+
+// Node 经某个节点访问网络时的表现
+type Node struct {
+	Internet    *Internet     // 经该节点访问的网络，nil 表示节点不可用 (代理返回 502)
+	Delay       time.Duration // 每个连接的额外延迟
+	SelectError error         // 切换到该节点时返回的错误
+}
+
+// ErrSelect 可用作 Node.SelectError，模拟核心拒绝切换
+var ErrSelect = errors.New("failed to switch proxy: 500")
+
+// Core 假代理核心：本地 HTTP 代理 (支持 CONNECT)，按选中节点的 Node 转发或失败
+type Core struct {
+	nodes map[string]Node
+	names map[string]bool // 配置中的全部节点，切换到其他名称时失败
+
+	mu       sync.Mutex
+	selected string
+	switches []string
+	ln       net.Listener
+	srv      *http.Server
+	conns    map[net.Conn]bool // CONNECT 隧道，Stop 时一并关闭
+}
+
+var _ proxy.Core = (*Core)(nil)
+
+// NewCore 创建假核心，nodes 为各节点的表现，未列出的节点视为不可用
+// cfg 中只使用节点列表，代理监听 127.0.0.1 的随机端口，避免测试之间端口冲突
+func NewCore(cfg proxy.Options, nodes map[string]Node) *Core {
+	names := make(map[string]bool, len(cfg.Nodes))
+	for _, node := range cfg.Nodes {
+		names[node.Name] = true
+	}
+	return &Core{nodes: nodes, names: names, conns: make(map[net.Conn]bool)}
+}
+
+// Factory 返回可用作 clashtester.Options.NewCore 的构造函数，创建的核心共用 nodes
+func Factory(nodes map[string]Node) func(proxy.Options) (proxy.Core, error) {
+	return func(cfg proxy.Options) (proxy.Core, error) {
+		return NewCore(cfg, nodes), nil
+	}
+}
+
+// Start 开始监听
+func (c *Core) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.ln = ln
+	c.srv = &http.Server{Handler: http.HandlerFunc(c.serve)}
+	srv := c.srv
+	c.mu.Unlock()
+	go srv.Serve(ln)
+	return nil
+}
+
+// Stop 关闭代理与进行中的隧道
+func (c *Core) Stop() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.srv == nil {
+		return nil
+	}
+	err := c.srv.Close()
+	for conn := range c.conns {
+		conn.Close()
+	}
+	c.srv = nil
+	return err
+}
+
+// SelectNode 切换节点
+func (c *Core) SelectNode(ctx context.Context, name string) error {
+	if !c.names[name] {
+		return fmt.Errorf("failed to switch proxy: %d", http.StatusNotFound)
+	}
+	if err := c.nodes[name].SelectError; err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.selected = name
+	c.switches = append(c.switches, name)
+	c.mu.Unlock()
+	return nil
+}
+
+// Healthy 是否已启动且未停止
+func (c *Core) Healthy(ctx context.Context) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.srv != nil
+}
+
+// ProxyURL 本地代理地址，Start 之后才有效
+func (c *Core) ProxyURL() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return "http://" + c.ln.Addr().String()
+}
+
+// Switches 返回依次切换过的节点
+func (c *Core) Switches() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.switches...)
+}
+
+func (c *Core) serve(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	node, ok := c.nodes[c.selected]
+	c.mu.Unlock()
+	if !ok || node.Internet == nil {
+		http.Error(w, "node unreachable", http.StatusBadGateway)
+		return
+	}
+
+	if node.Delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(node.Delay):
+		}
+	}
+
+	if r.Method != http.MethodConnect {
+		// 普通 HTTP 请求 (如 ip-api.com)：转发到 Internet 的 HTTP 服务，保留 Host
+		rp := &httputil.ReverseProxy{
+			Rewrite: func(pr *httputil.ProxyRequest) {
+				pr.Out.URL.Scheme = "http"
+				pr.Out.URL.Host = node.Internet.addr(false)
+				pr.Out.Host = r.Host
+			},
+		}
+		rp.ServeHTTP(w, r)
+		return
+	}
+
+	// CONNECT：所有 443 端口的连接都接到 Internet 的 HTTPS 服务
+	upstream, err := net.Dial("tcp", node.Internet.addr(true))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	client, _, err := hj.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	if _, err := io.WriteString(client, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		client.Close()
+		upstream.Close()
+		return
+	}
+
+	if !c.track(client, upstream) {
+		return
+	}
+	go func() {
+		io.Copy(upstream, client)
+		upstream.Close()
+	}()
+	io.Copy(client, upstream)
+	client.Close()
+	c.untrack(client, upstream)
+}
+
+// track 登记隧道的两端，核心已停止时直接关闭并返回 false
+func (c *Core) track(conns ...net.Conn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.srv == nil {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		return false
+	}
+	for _, conn := range conns {
+		c.conns[conn] = true
+	}
+	return true
+}
+
+func (c *Core) untrack(conns ...net.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, conn := range conns {
+		delete(c.conns, conn)
+	}
+}
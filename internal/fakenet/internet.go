@@ -0,0 +1,222 @@
+// Package fakenet 离线测试用的替身：假代理核心与本地的"假互联网"
+//
+// Internet 为任意域名签发证书，按 URL 返回预设的响应；Core 是一个本地 HTTP 代理，
+// 实现 proxy.Core，按当前选中的节点把请求转发到对应的 Internet 或模拟失败。
+// 检测代码不需要任何改动即可访问 chatgpt.com、www.netflix.com 等真实域名。
+package fakenet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Response 一个预设的响应
+type Response struct {
+	Status  int               // 默认 200
+	Headers map[string]string // 如 Location、Content-Type
+	Body    string
+}
+
+// authority 全部 Internet 共用的根证书，多个 Internet 同时使用时只需信任一个证书池
+var authority struct {
+	once sync.Once
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+	err  error
+}
+
+// RootCAs 返回签发全部 Internet 证书的根证书池
+func RootCAs() (*x509.CertPool, error) {
+	authority.once.Do(func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			authority.err = err
+			return
+		}
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "fakenet CA"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(24 * time.Hour),
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		if err != nil {
+			authority.err = err
+			return
+		}
+		if authority.cert, authority.err = x509.ParseCertificate(der); authority.err != nil {
+			return
+		}
+		authority.key = key
+		authority.pool = x509.NewCertPool()
+		authority.pool.AddCert(authority.cert)
+	})
+	return authority.pool, authority.err
+}
+
+// Internet 本地的 HTTPS 与 HTTP 服务，按 URL 返回预设的响应，未设置的 URL 返回 404
+type Internet struct {
+	RootCAs *x509.CertPool // 同 RootCAs()，信任该证书池即可校验 Internet 为任意域名签发的证书
+
+	tlsSrv  *http.Server
+	httpSrv *http.Server
+	tlsLn   net.Listener
+	httpLn  net.Listener
+
+	mu     sync.Mutex
+	routes map[string]Response // key: host + path (含 query)
+	certs  map[string]*tls.Certificate
+	hits   map[string]int
+}
+
+// NewInternet 启动 HTTPS 与 HTTP 服务，均监听 127.0.0.1 的随机端口
+func NewInternet() (*Internet, error) {
+	roots, err := RootCAs()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Internet{
+		RootCAs: roots,
+		routes:  make(map[string]Response),
+		certs:   make(map[string]*tls.Certificate),
+		hits:    make(map[string]int),
+	}
+	if n.tlsLn, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		return nil, err
+	}
+	if n.httpLn, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		n.tlsLn.Close()
+		return nil, err
+	}
+
+	handler := http.HandlerFunc(n.serve)
+	n.tlsSrv = &http.Server{Handler: handler, TLSConfig: &tls.Config{GetCertificate: n.certificate}}
+	n.httpSrv = &http.Server{Handler: handler}
+	go n.tlsSrv.ServeTLS(n.tlsLn, "", "")
+	go n.httpSrv.Serve(n.httpLn)
+	return n, nil
+}
+
+// Close 停止服务
+func (n *Internet) Close() {
+	n.tlsSrv.Close()
+	n.httpSrv.Close()
+}
+
+// Serve 设置访问 rawURL 时的响应，http 与 https 共用
+func (n *Internet) Serve(rawURL string, resp Response) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	n.mu.Lock()
+	n.routes[u.Hostname()+u.RequestURI()] = resp
+	n.mu.Unlock()
+}
+
+// Hits 返回 rawURL 被访问的次数
+func (n *Internet) Hits(rawURL string) int {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.hits[u.Hostname()+u.RequestURI()]
+}
+
+// DirectURL 不经过核心、直接访问 HTTP 服务的地址，如自检的直连探测
+func (n *Internet) DirectURL(path string) string {
+	return "http://" + n.addr(false) + path
+}
+
+// addr 返回 https 或 http 服务的地址，供 Core 转发
+func (n *Internet) addr(https bool) string {
+	if https {
+		return n.tlsLn.Addr().String()
+	}
+	return n.httpLn.Addr().String()
+}
+
+func (n *Internet) serve(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	n.mu.Lock()
+	key := host + r.URL.RequestURI()
+	resp, ok := n.routes[key]
+	if !ok {
+		key = host + r.URL.Path
+		resp, ok = n.routes[key]
+	}
+	if ok {
+		n.hits[key]++
+	}
+	n.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	for k, v := range resp.Headers {
+		w.Header().Set(k, v)
+	}
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(resp.Body))
+}
+
+// certificate 按 SNI 签发 (并缓存) 证书
+func (n *Internet) certificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := hello.ServerName
+	if name == "" {
+		name = "localhost"
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if cert, ok := n.certs[name]; ok {
+		return cert, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, authority.cert, &key.PublicKey, authority.key)
+	if err != nil {
+		return nil, err
+	}
+	cert := &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	n.certs[name] = cert
+	return cert, nil
+}
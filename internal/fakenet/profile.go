@@ -0,0 +1,103 @@
+package fakenet
+
+import (
+	"fmt"
+
+	"Clash-tester/internal/tester"
+)
+
+// Netflix 解锁程度，与检测结果的 Details 一致
+const (
+	NetflixFull      = "Full"
+	NetflixOriginals = "Originals Only"
+)
+
+// Profile 一个出口的各项服务解锁情况
+type Profile struct {
+	Country string // ip-api 与 cdn-cgi/trace 返回的国家代码
+	OpenAI  bool
+	Gemini  bool
+	Claude  bool
+	Netflix string // NetflixFull / NetflixOriginals，为空表示不可用
+	Disney  bool
+	YouTube bool
+	Max     bool
+}
+
+// Unlocked 全部服务可用
+func Unlocked(country string) Profile {
+	return Profile{
+		Country: country,
+		OpenAI:  true, Gemini: true, Claude: true,
+		Netflix: NetflixFull, Disney: true, YouTube: true, Max: true,
+	}
+}
+
+// Blocked 全部服务不可用，但网络本身是通的
+func Blocked(country string) Profile {
+	return Profile{Country: country}
+}
+
+// ServeProfile 按 Profile 为 e 中的全部地址设置响应，内容模仿各站点的真实响应
+func (n *Internet) ServeProfile(e tester.Endpoints, p Profile) {
+	n.Serve(e.IPInfo, Response{
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    fmt.Sprintf(`{"countryCode":%q}`, p.Country),
+	})
+
+	if p.OpenAI {
+		n.Serve(e.OpenAI, Response{Body: fmt.Sprintf("fl=29f\nh=chatgpt.com\nip=203.0.113.7\nts=1700000000.123\nloc=%s\ntls=TLSv1.3\nwarp=off\n", p.Country)})
+	} else {
+		n.Serve(e.OpenAI, Response{Status: 403, Body: "<html><title>Attention Required! | Cloudflare</title>Sorry, you have been blocked</html>"})
+	}
+
+	if p.Gemini {
+		n.Serve(e.Gemini, Response{Status: 302, Headers: map[string]string{
+			"Location": "https://accounts.google.com/ServiceLogin?continue=https://gemini.google.com/app",
+		}})
+	} else {
+		n.Serve(e.Gemini, Response{Status: 302, Headers: map[string]string{
+			"Location": "https://gemini.google.com/faq?hl=en#unsupported-region",
+		}})
+	}
+
+	if p.Claude {
+		n.Serve(e.Claude, Response{Body: "<html><head><title>Claude</title></head><body>Log in or sign up</body></html>"})
+	} else {
+		n.Serve(e.Claude, Response{Body: "<html><head><title>Claude</title></head><body><h1>App unavailable</h1>Unfortunately, Claude is only available in certain regions right now.</body></html>"})
+	}
+
+	page := func(title string) Response {
+		return Response{Body: fmt.Sprintf(`<html><title>%s | Netflix</title><script>{"current_country":%q}</script><a class="watch-video">Play</a></html>`, title, p.Country)}
+	}
+	notAvailable := Response{Status: 404, Body: "<html><title>Netflix</title>Title not available in your country</html>"}
+	switch p.Netflix {
+	case NetflixFull:
+		n.Serve(e.NetflixFull, page("Breaking Bad"))
+		n.Serve(e.NetflixOriginals, page("Squid Game"))
+	case NetflixOriginals:
+		n.Serve(e.NetflixFull, notAvailable)
+		n.Serve(e.NetflixOriginals, page("Squid Game"))
+	default:
+		n.Serve(e.NetflixFull, notAvailable)
+		n.Serve(e.NetflixOriginals, notAvailable)
+	}
+
+	if p.Disney {
+		n.Serve(e.Disney, Response{Status: 302, Headers: map[string]string{"Location": "https://www.disneyplus.com/en-us/login"}})
+	} else {
+		n.Serve(e.Disney, Response{Status: 302, Headers: map[string]string{"Location": "https://www.disneyplus.com/unavailable"}})
+	}
+
+	if p.YouTube {
+		n.Serve(e.YouTube, Response{Body: fmt.Sprintf(`<html><script>var ytcfg={"countryCode":%q};</script>YouTube Premium</html>`, p.Country)})
+	} else {
+		n.Serve(e.YouTube, Response{Status: 403, Body: "Forbidden"})
+	}
+
+	if p.Max {
+		n.Serve(e.Max, Response{Body: "<html><title>Max | Stream HBO</title></html>"})
+	} else {
+		n.Serve(e.Max, Response{Body: "<html><title>Max</title>Max is Not Available in your region</html>"})
+	}
+}
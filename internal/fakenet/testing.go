@@ -0,0 +1,89 @@
+package fakenet
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"Clash-tester/internal/proxy"
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+)
+
+// 测试订阅中的节点
+const (
+	NodeUS   = "🇺🇸 US 01" // 全部解锁
+	NodeCN   = "🇨🇳 CN 02" // 全部被封
+	NodeDown = "Down 03"  // 不可用
+)
+
+// Subscription 各包测试共用的订阅 (Clash YAML)，解析结果同 SubscriptionNodes
+const Subscription = `proxies:
+  - {name: "🇺🇸 US 01", type: ss, server: 1.1.1.1, port: 1, cipher: aes-128-gcm, password: x}
+  - {name: "🇨🇳 CN 02", type: trojan, server: 1.1.1.2, port: 2, password: x}
+  - {name: "Down 03", type: vmess, server: 1.1.1.3, port: 3, uuid: abc}
+`
+
+// SubscriptionNodes Subscription 中的节点
+func SubscriptionNodes() []models.ProxyNode {
+	return []models.ProxyNode{
+		{Name: NodeUS, Type: "ss", Server: "1.1.1.1", Port: 1, Cipher: "aes-128-gcm", Password: "x"},
+		{Name: NodeCN, Type: "trojan", Server: "1.1.1.2", Port: 2, Password: "x"},
+		{Name: NodeDown, Type: "vmess", Server: "1.1.1.3", Port: 3, UUID: "abc"},
+	}
+}
+
+// WriteSubscription 将 Subscription 与 extra 写入临时目录，返回文件路径
+func WriteSubscription(t testing.TB, extra string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sub.yaml")
+	if err := os.WriteFile(path, []byte(Subscription+extra), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// SubscriptionBehavior Subscription 中各节点的表现 (检测地址为 tester.DefaultEndpoints)：
+// US 全部解锁、CN 全部被封、Down 不可用；返回的 map 可以修改
+func SubscriptionBehavior(t testing.TB) map[string]Node {
+	t.Helper()
+	return map[string]Node{
+		NodeUS:   {Internet: NewTestInternet(t, tester.DefaultEndpoints, Unlocked("US"))},
+		NodeCN:   {Internet: NewTestInternet(t, tester.DefaultEndpoints, Blocked("CN"))},
+		NodeDown: {},
+	}
+}
+
+// NewTestInternet 启动按 profile 响应 e 中各地址的 Internet，测试结束时关闭
+func NewTestInternet(t testing.TB, e tester.Endpoints, p Profile) *Internet {
+	t.Helper()
+	n, err := NewInternet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(n.Close)
+	n.ServeProfile(e, p)
+	return n
+}
+
+// StartTestCore 启动假核心，节点名即 nodes 的键，测试结束时停止
+func StartTestCore(t testing.TB, nodes map[string]Node) *Core {
+	t.Helper()
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var list []models.ProxyNode
+	for _, name := range names {
+		list = append(list, models.ProxyNode{Name: name, Type: "ss", Server: name + ".example", Port: 443})
+	}
+	core := NewCore(proxy.Options{Nodes: list}, nodes)
+	if err := core.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { core.Stop() })
+	return core
+}
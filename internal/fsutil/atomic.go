@@ -0,0 +1,93 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteFileAtomic 原子写入文件
+// 先写入同目录下的临时文件并 fsync，再 rename 覆盖目标文件，
+// 读取方在任何时刻都只会看到完整的旧文件或完整的新文件
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// 临时文件必须与目标在同一目录 (同一文件系统)，rename 才是原子的
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // rename 成功后这里删除的是不存在的文件，无副作用
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	syncDir(dir)
+	return nil
+}
+
+// Backup 将现有文件原子地复制为 path + ".bak"，文件不存在时什么也不做
+func Backup(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	return WriteFileAtomic(path+".bak", data, perm)
+}
+
+// syncDir 持久化目录项，确保 rename 在断电后依然生效
+// 部分平台 (如 Windows) 不支持对目录 fsync，忽略错误
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// SafeName 将任意名称 (如节点名) 转换为可用作文件名的字符串
+func SafeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		if r < 0x20 {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		return "node"
+	}
+	return name
+}
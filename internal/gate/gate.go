@@ -0,0 +1,38 @@
+package gate
+
+import (
+	"fmt"
+
+	"Clash-tester/pkg/models"
+)
+
+// Config 发布结果前的最低质量要求
+type Config struct {
+	MinTestedRatio  float64 // 已测试节点占订阅节点的最低比例，0 表示不检查
+	MinSuccessNodes int     // 至少有多少个节点可用 (见 tester.IsNodeSuccess)，0 表示不检查
+}
+
+// Evaluate 检查测试报告是否满足质量要求，返回未通过的条目
+func Evaluate(report models.TestReport, cfg Config) []string {
+	var failures []string
+
+	if cfg.MinTestedRatio > 0 && report.TotalNodes > 0 {
+		ratio := float64(report.TestedNodes) / float64(report.TotalNodes)
+		if ratio < cfg.MinTestedRatio {
+			failures = append(failures, fmt.Sprintf("tested ratio %.2f below minimum %.2f (%d/%d nodes)",
+				ratio, cfg.MinTestedRatio, report.TestedNodes, report.TotalNodes))
+		}
+	}
+
+	if cfg.MinSuccessNodes > 0 && report.SuccessNodes < cfg.MinSuccessNodes {
+		failures = append(failures, fmt.Sprintf("%d success nodes below minimum %d",
+			report.SuccessNodes, cfg.MinSuccessNodes))
+	}
+
+	// 自检判定为全局故障：节点失败不代表节点本身不可用，不能发布
+	if report.Canary != nil && report.Canary.GlobalOutage {
+		failures = append(failures, "global outage: "+report.Canary.Reason)
+	}
+
+	return failures
+}
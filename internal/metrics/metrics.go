@@ -0,0 +1,249 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Clash-tester/internal/fsutil"
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+)
+
+// Run 一次测试运行的结果，用于生成 Prometheus 指标
+type Run struct {
+	Report   *models.TestReport // 在测试开始前就失败时为 nil
+	Started  time.Time
+	Finished time.Time
+	ExitCode int
+}
+
+// WriteTextfile 将指标写入 node_exporter textfile collector 目录下的文件
+// 文件名需以 .prom 结尾，原子替换避免 node_exporter 读到半截内容
+func WriteTextfile(path string, run Run) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	Render(&buf, run)
+	return fsutil.WriteFileAtomic(path, buf.Bytes(), 0644)
+}
+
+// Render 按 Prometheus 文本格式输出一次运行的指标
+func Render(w io.Writer, run Run) {
+	e := &encoder{w: w}
+
+	e.gauge("clash_tester_last_run_timestamp_seconds", "Unix time the last run finished.",
+		sample{value: float64(run.Finished.Unix())})
+	e.gauge("clash_tester_last_run_duration_seconds", "Duration of the last run.",
+		sample{value: run.Finished.Sub(run.Started).Seconds()})
+	e.gauge("clash_tester_last_run_exit_code", "Exit code of the last run (0 = success).",
+		sample{value: float64(run.ExitCode)})
+
+	report := run.Report
+	if report == nil {
+		return
+	}
+
+	e.gauge("clash_tester_nodes", "Number of nodes in the last run by state.",
+		sample{labels: []string{"state", "total"}, value: float64(report.TotalNodes)},
+		sample{labels: []string{"state", "tested"}, value: float64(report.TestedNodes)},
+		sample{labels: []string{"state", "succeeded"}, value: float64(report.SuccessNodes)},
+		sample{labels: []string{"state", "cached"}, value: float64(report.CachedNodes)},
+	)
+	e.gauge("clash_tester_last_run_incomplete", "Whether the last run was interrupted or timed out.",
+		sample{value: boolValue(report.Incomplete)})
+	e.gauge("clash_tester_quality_gate_failures", "Number of quality gate checks the last run failed.",
+		sample{value: float64(len(report.GateFailures))})
+	if report.Canary != nil {
+		e.gauge("clash_tester_global_outage", "Whether the last run was classified as a global outage of the tester.",
+			sample{value: boolValue(report.Canary.GlobalOutage)})
+	}
+
+	// 各服务可用节点数
+	summary := []struct {
+		name string
+		s    models.ServiceSummary
+	}{
+		{"openai", report.Summary.OpenAI},
+		{"gemini", report.Summary.Gemini},
+		{"claude", report.Summary.Claude},
+	}
+	var services []sample
+	for _, item := range summary {
+		services = append(services, sample{labels: []string{"service", item.name}, value: float64(item.s.Available)})
+	}
+	for _, name := range sortedKeys(report.Summary.Streaming) {
+		services = append(services, sample{labels: []string{"service", name}, value: float64(report.Summary.Streaming[name].Available)})
+	}
+	e.gauge("clash_tester_service_available_nodes", "Number of nodes on which the service is available.", services...)
+
+	// 每个节点每个服务的可用性与延迟
+	var available, latency, speed, errs []sample
+	errorCounts := make(map[[2]string]int)
+	for _, node := range report.Results {
+		for _, name := range sortedKeys(node.Tests) {
+			t := node.Tests[name]
+			labels := []string{"node", node.NodeName, "service", name}
+			available = append(available, sample{labels: labels, value: boolValue(t.Available)})
+			if t.ResponseTime > 0 {
+				latency = append(latency, sample{labels: labels, value: float64(t.ResponseTime) / 1000})
+			}
+			if c := tester.ErrorCategory(t.Error); c != "" {
+				errorCounts[[2]string{name, c}]++
+			}
+		}
+		for _, name := range sortedKeys(node.StreamTests) {
+			t := node.StreamTests[name]
+			labels := []string{"node", node.NodeName, "service", name}
+			available = append(available, sample{labels: labels, value: boolValue(t.Available)})
+			if t.ResponseTime > 0 {
+				latency = append(latency, sample{labels: labels, value: float64(t.ResponseTime) / 1000})
+			}
+			if c := tester.ErrorCategory(t.Error); c != "" {
+				errorCounts[[2]string{name, c}]++
+			}
+		}
+		if node.SpeedTest != nil && node.SpeedTest.Available {
+			speed = append(speed, sample{labels: []string{"node", node.NodeName}, value: node.SpeedTest.DownloadMbps})
+		}
+	}
+	e.gauge("clash_tester_node_service_available", "Whether the service is available through the node (1) or not (0).", available...)
+	e.gauge("clash_tester_node_service_latency_seconds", "Response time of the service check through the node.", latency...)
+	if len(speed) > 0 {
+		e.gauge("clash_tester_node_download_mbps", "Measured download throughput through the node.", speed...)
+	}
+
+	keys := make([][2]string, 0, len(errorCounts))
+	for k := range errorCounts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, k := range keys {
+		errs = append(errs, sample{labels: []string{"service", k[0], "category", k[1]}, value: float64(errorCounts[k])})
+	}
+	e.gauge("clash_tester_check_errors", "Number of failed checks in the last run by service and error category.", errs...)
+
+	var restarts []sample
+	for _, w := range report.Workers {
+		restarts = append(restarts, sample{labels: []string{"worker", strconv.Itoa(w.ID)}, value: float64(w.Restarts)})
+	}
+	e.gauge("clash_tester_core_restarts", "Number of times the worker's proxy core was restarted during the last run.", restarts...)
+}
+
+// Exporter 在常驻模式下通过 /metrics 提供最近一次运行的指标
+type Exporter struct {
+	mu   sync.Mutex
+	last *Run
+	runs map[int]int // 按退出码统计的运行次数
+}
+
+func NewExporter() *Exporter {
+	return &Exporter{runs: make(map[int]int)}
+}
+
+// Observe 记录一次运行
+func (x *Exporter) Observe(run Run) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.last = &run
+	x.runs[run.ExitCode]++
+}
+
+func (x *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	codes := make([]int, 0, len(x.runs))
+	for code := range x.runs {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	var runs []sample
+	for _, code := range codes {
+		runs = append(runs, sample{labels: []string{"exit_code", strconv.Itoa(code)}, value: float64(x.runs[code])})
+	}
+	e := &encoder{w: w}
+	e.write("clash_tester_runs_total", "counter", "Number of completed runs by exit code.", runs...)
+
+	if x.last != nil {
+		Render(w, *x.last)
+	}
+}
+
+// sample 一条指标样本，labels 为 name, value 交替排列
+type sample struct {
+	labels []string
+	value  float64
+}
+
+type encoder struct {
+	w io.Writer
+}
+
+func (e *encoder) gauge(name, help string, samples ...sample) {
+	e.write(name, "gauge", help, samples...)
+}
+
+func (e *encoder) write(name, kind, help string, samples ...sample) {
+	if len(samples) == 0 {
+		return
+	}
+	fmt.Fprintf(e.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	for _, s := range samples {
+		fmt.Fprintf(e.w, "%s%s %s\n", name, formatLabels(s.labels), strconv.FormatFloat(s.value, 'g', -1, 64))
+	}
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(labels[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// labelEscaper 按 Prometheus 文本格式转义标签值
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
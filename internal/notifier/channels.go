@@ -0,0 +1,152 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"Clash-tester/pkg/models"
+)
+
+// SendTimeout 单次通知请求的超时时间
+const SendTimeout = 10 * time.Second
+
+// discordMaxLength Discord 单条消息的长度上限
+const discordMaxLength = 2000
+
+// sendRetries 请求失败、返回 429 或 5xx 时的重试次数
+const sendRetries = 2
+
+// retryDelay 第一次重试前的等待时间，之后每次加倍
+var retryDelay = 2 * time.Second
+
+// webhookPayload 通用 JSON webhook 的请求体
+type webhookPayload struct {
+	Event        string              `json:"event"`
+	Time         time.Time           `json:"time"`
+	Title        string              `json:"title"`
+	Message      string              `json:"message"`
+	ExitCode     int                 `json:"exit_code"`
+	Error        string              `json:"error,omitempty"`
+	Service      string              `json:"service,omitempty"`
+	Count        int                 `json:"count,omitempty"`
+	Min          int                 `json:"min,omitempty"`
+	Rule         string              `json:"rule,omitempty"`
+	Severity     string              `json:"severity,omitempty"`
+	Resolved     bool                `json:"resolved,omitempty"`
+	TotalNodes   int                 `json:"total_nodes"`
+	TestedNodes  int                 `json:"tested_nodes"`
+	SuccessNodes int                 `json:"success_nodes"`
+	Summary      *models.TestSummary `json:"summary,omitempty"`
+}
+
+// send 按渠道类型组装请求并发送
+func (ch *Channel) send(ctx context.Context, ev Event, text string) error {
+	var target string
+	var body any
+
+	switch ch.Type {
+	case "webhook":
+		target = ch.URL
+		payload := webhookPayload{
+			Event:    ev.Type,
+			Time:     ev.Time,
+			Title:    ev.Title,
+			Message:  text,
+			ExitCode: ev.ExitCode,
+			Error:    ev.Error,
+			Service:  ev.Service,
+			Count:    ev.Count,
+			Min:      ev.Min,
+			Rule:     ev.Rule,
+			Severity: ev.Severity,
+			Resolved: ev.Resolved,
+		}
+		if ev.Report != nil {
+			payload.TotalNodes = ev.Report.TotalNodes
+			payload.TestedNodes = ev.Report.TestedNodes
+			payload.SuccessNodes = ev.Report.SuccessNodes
+			payload.Summary = &ev.Report.Summary
+		}
+		body = payload
+	case "telegram":
+		base := ch.APIBase
+		if base == "" {
+			base = "https://api.telegram.org"
+		}
+		target = fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimRight(base, "/"), ch.BotToken)
+		body = map[string]any{
+			"chat_id":                  ch.ChatID,
+			"text":                     text,
+			"disable_web_page_preview": true,
+		}
+	case "discord":
+		target = ch.URL
+		if len([]rune(text)) > discordMaxLength {
+			text = string([]rune(text)[:discordMaxLength-3]) + "..."
+		}
+		body = map[string]string{"content": text}
+	case "slack":
+		target = ch.URL
+		body = map[string]string{"text": text}
+	default:
+		return fmt.Errorf("unknown channel type %q", ch.Type)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := ch.post(ctx, target, data)
+		if err == nil || !retry || attempt == sendRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// post 发送一次请求，retry 表示失败可能是暂时的 (网络错误、429、5xx)
+func (ch *Channel) post(ctx context.Context, target string, data []byte) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, SendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", target, bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range ch.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// 请求错误中可能包含 Telegram bot token，避免写入日志
+		msg := err.Error()
+		if ch.BotToken != "" {
+			msg = strings.ReplaceAll(msg, ch.BotToken, "***")
+		}
+		return true, fmt.Errorf("request failed: %s", msg)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(excerpt)))
+	}
+	return false, nil
+}
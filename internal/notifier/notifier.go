@@ -0,0 +1,286 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+)
+
+// 事件类型
+const (
+	EventRunFinished = "run_finished" // 运行正常结束 (退出码为 0)
+	EventRunFailed   = "run_failed"   // 运行失败或不完整 (订阅不可用、核心启动失败、未通过质量检查、部分节点未测试等)
+	EventThreshold   = "threshold"    // 某项服务的可用节点数低于阈值
+	EventAlert       = "alert"        // 告警规则触发或恢复 (见 internal/alert)
+)
+
+// Config 通知配置文件 (YAML)
+type Config struct {
+	Channels   []Channel   `yaml:"channels"`
+	Thresholds []Threshold `yaml:"thresholds"`
+}
+
+// Channel 一个通知渠道
+type Channel struct {
+	Name     string            `yaml:"name"`
+	Type     string            `yaml:"type"`      // webhook / telegram / discord / slack
+	URL      string            `yaml:"url"`       // webhook / discord / slack 的地址
+	Headers  map[string]string `yaml:"headers"`   // 附加请求头 (如鉴权)
+	BotToken string            `yaml:"bot_token"` // telegram
+	ChatID   string            `yaml:"chat_id"`   // telegram
+	APIBase  string            `yaml:"api_base"`  // telegram API 地址，默认 https://api.telegram.org
+	Events   []string          `yaml:"events"`    // 订阅的事件，为空表示全部
+	Template string            `yaml:"template"`  // 消息模板 (text/template)，为空使用默认文本
+
+	tmpl *template.Template
+}
+
+// Threshold 服务可用节点数阈值
+type Threshold struct {
+	Service  string `yaml:"service"`   // openai / gemini / claude / netflix / ...
+	Min      int    `yaml:"min"`       // 可用节点数低于该值时触发
+	OnChange bool   `yaml:"on_change"` // 仅在从达标变为不达标时触发 (需要上一次的报告)
+}
+
+// Event 一条通知，也是消息模板的数据
+type Event struct {
+	Type     string
+	Time     time.Time
+	Title    string
+	Message  string             // 默认消息文本
+	Report   *models.TestReport // 运行在测试前失败时为 nil
+	Error    string
+	ExitCode int
+	// 阈值事件
+	Service string
+	Count   int
+	Min     int
+	// 告警事件
+	Rule     string
+	Severity string
+	Resolved bool
+}
+
+// Outcome 一次运行的结果，用于生成通知
+type Outcome struct {
+	Report   *models.TestReport
+	Previous *models.TestReport // 上一次运行的报告，可能为 nil
+	State    *State             // 跨运行保存的各服务计数，优先于 Previous，可能为 nil
+	Err      error
+	ExitCode int
+}
+
+// Notifier 按配置把事件发送到各渠道
+type Notifier struct {
+	cfg Config
+}
+
+// Load 读取并校验通知配置
+func Load(path string) (*Notifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid notify config %s: %w", path, err)
+	}
+	return New(cfg)
+}
+
+// New 校验配置并编译消息模板
+func New(cfg Config) (*Notifier, error) {
+	for i := range cfg.Channels {
+		ch := &cfg.Channels[i]
+		if ch.Name == "" {
+			ch.Name = fmt.Sprintf("%s#%d", ch.Type, i+1)
+		}
+		switch ch.Type {
+		case "webhook", "discord", "slack":
+			if ch.URL == "" {
+				return nil, fmt.Errorf("channel %s: url is required", ch.Name)
+			}
+		case "telegram":
+			if ch.BotToken == "" || ch.ChatID == "" {
+				return nil, fmt.Errorf("channel %s: bot_token and chat_id are required", ch.Name)
+			}
+		default:
+			return nil, fmt.Errorf("channel %s: unknown type %q", ch.Name, ch.Type)
+		}
+		for _, ev := range ch.Events {
+			if ev != EventRunFinished && ev != EventRunFailed && ev != EventThreshold && ev != EventAlert {
+				return nil, fmt.Errorf("channel %s: unknown event %q", ch.Name, ev)
+			}
+		}
+		if ch.Template != "" {
+			tmpl, err := template.New(ch.Name).Parse(ch.Template)
+			if err != nil {
+				return nil, fmt.Errorf("channel %s: invalid template: %w", ch.Name, err)
+			}
+			ch.tmpl = tmpl
+		}
+	}
+	for _, t := range cfg.Thresholds {
+		if !tester.IsService(t.Service) {
+			return nil, fmt.Errorf("threshold: unknown service %q", t.Service)
+		}
+	}
+	return &Notifier{cfg: cfg}, nil
+}
+
+// Events 根据运行结果生成需要发送的事件
+func (n *Notifier) Events(out Outcome) []Event {
+	now := time.Now()
+	var events []Event
+
+	if out.Err != nil {
+		events = append(events, Event{
+			Type:     EventRunFailed,
+			Time:     now,
+			Title:    "Clash-tester run failed",
+			Message:  fmt.Sprintf("❌ Clash-tester run failed (exit code %d): %v", out.ExitCode, out.Err),
+			Report:   out.Report,
+			Error:    out.Err.Error(),
+			ExitCode: out.ExitCode,
+		})
+	} else {
+		events = append(events, Event{
+			Type:     EventRunFinished,
+			Time:     now,
+			Title:    "Clash-tester run finished",
+			Message:  finishedMessage(out.Report),
+			Report:   out.Report,
+			ExitCode: out.ExitCode,
+		})
+	}
+
+	// 没有测试结果 (或全局故障) 时服务计数没有意义，不检查阈值
+	report := out.Report
+	if !countable(report) {
+		return events
+	}
+	for _, t := range n.cfg.Thresholds {
+		count := availableCount(report, t.Service)
+		if count >= t.Min {
+			continue
+		}
+		if previous, ok := out.previousCount(t.Service); t.OnChange && ok && previous < t.Min {
+			continue
+		}
+		events = append(events, Event{
+			Type:  EventThreshold,
+			Time:  now,
+			Title: fmt.Sprintf("%s available nodes below %d", t.Service, t.Min),
+			Message: fmt.Sprintf("⚠️ %s: only %d available nodes (minimum %d), %d nodes tested",
+				t.Service, count, t.Min, report.TestedNodes),
+			Report:   report,
+			ExitCode: out.ExitCode,
+			Service:  t.Service,
+			Count:    count,
+			Min:      t.Min,
+		})
+	}
+	return events
+}
+
+// Notify 生成事件并发送到订阅了该事件的渠道，发送失败只记录日志
+func (n *Notifier) Notify(ctx context.Context, out Outcome) {
+	for _, ev := range n.Events(out) {
+		n.Send(ctx, ev)
+	}
+}
+
+// Send 发送单个事件
+func (n *Notifier) Send(ctx context.Context, ev Event) {
+	for i := range n.cfg.Channels {
+		ch := &n.cfg.Channels[i]
+		if !ch.subscribed(ev.Type) {
+			continue
+		}
+		text, err := ch.render(ev)
+		if err != nil {
+			log.Printf("⚠️  Notify %s: %v", ch.Name, err)
+			continue
+		}
+		if err := ch.send(ctx, ev, text); err != nil {
+			log.Printf("⚠️  Notify %s: %v", ch.Name, err)
+		}
+	}
+}
+
+func (ch *Channel) subscribed(event string) bool {
+	if len(ch.Events) == 0 {
+		return true
+	}
+	for _, e := range ch.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// render 使用渠道模板生成消息文本
+func (ch *Channel) render(ev Event) (string, error) {
+	if ch.tmpl == nil {
+		return ev.Message, nil
+	}
+	var buf bytes.Buffer
+	if err := ch.tmpl.Execute(&buf, ev); err != nil {
+		return "", fmt.Errorf("template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func finishedMessage(report *models.TestReport) string {
+	if report == nil {
+		return "✅ Clash-tester run finished"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "✅ Clash-tester run finished: %d/%d nodes tested, %d available",
+		report.TestedNodes, report.TotalNodes, report.SuccessNodes)
+	if report.Incomplete {
+		fmt.Fprintf(&b, " (incomplete: %s)", report.IncompleteReason)
+	}
+	fmt.Fprintf(&b, "\nOpenAI: %d | Gemini: %d | Claude: %d",
+		report.Summary.OpenAI.Available, report.Summary.Gemini.Available, report.Summary.Claude.Available)
+	for _, name := range tester.StreamServices {
+		if s, ok := report.Summary.Streaming[name]; ok {
+			fmt.Fprintf(&b, " | %s: %d", name, s.Available)
+		}
+	}
+	return b.String()
+}
+
+// previousCount 上一次运行中服务的可用节点数，优先取自状态文件，没有时从上一次的报告计算
+func (out Outcome) previousCount(service string) (int, bool) {
+	if out.State != nil {
+		if count, ok := out.State.Available[service]; ok {
+			return count, true
+		}
+	}
+	if out.Previous != nil {
+		return availableCount(out.Previous, service), true
+	}
+	return 0, false
+}
+
+// countable 报告中的服务计数是否有意义
+func countable(report *models.TestReport) bool {
+	return report != nil && report.TestedNodes > 0 && (report.Canary == nil || !report.Canary.GlobalOutage)
+}
+
+func availableCount(report *models.TestReport, service string) int {
+	s, _ := report.Summary.Service(service)
+	return s.Available
+}
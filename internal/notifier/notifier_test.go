@@ -0,0 +1,272 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"Clash-tester/pkg/models"
+)
+
+// request 替身服务收到的一次请求
+type request struct {
+	Path    string
+	Headers http.Header
+	Body    map[string]any
+}
+
+// newServer 按顺序以 statuses 响应 (用完后返回 200)，记录收到的请求
+func newServer(t *testing.T, statuses ...int) (*httptest.Server, func() []request) {
+	t.Helper()
+	var mu sync.Mutex
+	var got []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("request body is not JSON: %q", data)
+		}
+		mu.Lock()
+		got = append(got, request{Path: r.URL.Path, Headers: r.Header, Body: body})
+		n := len(got)
+		mu.Unlock()
+		if n <= len(statuses) {
+			http.Error(w, "try again", statuses[n-1])
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]request(nil), got...)
+	}
+}
+
+func testReport() *models.TestReport {
+	return &models.TestReport{
+		TotalNodes:   10,
+		TestedNodes:  10,
+		SuccessNodes: 3,
+		Summary: models.TestSummary{
+			OpenAI:    models.ServiceSummary{Available: 0, Unavailable: 10},
+			Claude:    models.ServiceSummary{Available: 3, Unavailable: 7},
+			Streaming: map[string]models.ServiceSummary{"netflix": {Available: 2, Unavailable: 8}},
+		},
+	}
+}
+
+func TestWebhookPayload(t *testing.T) {
+	srv, requests := newServer(t)
+	n, err := New(Config{
+		Channels: []Channel{{
+			Type:    "webhook",
+			URL:     srv.URL + "/hook",
+			Headers: map[string]string{"Authorization": "Bearer abc"},
+		}},
+		Thresholds: []Threshold{{Service: "openai", Min: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n.Notify(context.Background(), Outcome{Report: testReport()})
+
+	got := requests()
+	if len(got) != 2 {
+		t.Fatalf("got %d requests, want run_finished and threshold", len(got))
+	}
+	finished, threshold := got[0], got[1]
+	if finished.Path != "/hook" || finished.Headers.Get("Authorization") != "Bearer abc" ||
+		finished.Headers.Get("Content-Type") != "application/json" {
+		t.Errorf("request path %q, headers %v", finished.Path, finished.Headers)
+	}
+	if finished.Body["event"] != EventRunFinished || finished.Body["tested_nodes"] != 10.0 || finished.Body["success_nodes"] != 3.0 {
+		t.Errorf("run_finished payload = %v", finished.Body)
+	}
+	summary, _ := finished.Body["summary"].(map[string]any)
+	if claude, _ := summary["claude"].(map[string]any); claude["available_count"] != 3.0 {
+		t.Errorf("summary = %v, want claude available 3", summary)
+	}
+	if !strings.Contains(finished.Body["message"].(string), "Claude: 3") {
+		t.Errorf("message = %q", finished.Body["message"])
+	}
+	if threshold.Body["event"] != EventThreshold || threshold.Body["service"] != "openai" ||
+		threshold.Body["count"] != nil || threshold.Body["min"] != 1.0 {
+		t.Errorf("threshold payload = %v", threshold.Body)
+	}
+}
+
+func TestTelegramPayload(t *testing.T) {
+	srv, requests := newServer(t)
+	n, err := New(Config{Channels: []Channel{{
+		Type:     "telegram",
+		BotToken: "123:secret",
+		ChatID:   "-100200",
+		APIBase:  srv.URL + "/",
+		Events:   []string{EventRunFailed},
+		Template: "{{.Title}} (exit {{.ExitCode}}): {{.Error}}",
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 未订阅 run_finished
+	n.Notify(context.Background(), Outcome{Report: testReport()})
+	if got := requests(); len(got) != 0 {
+		t.Fatalf("unsubscribed event sent: %v", got)
+	}
+
+	n.Notify(context.Background(), Outcome{Err: errors.New("subscription unreachable"), ExitCode: 2})
+	got := requests()
+	if len(got) != 1 {
+		t.Fatalf("got %d requests, want 1", len(got))
+	}
+	if got[0].Path != "/bot123:secret/sendMessage" {
+		t.Errorf("path = %q", got[0].Path)
+	}
+	if got[0].Body["chat_id"] != "-100200" || got[0].Body["disable_web_page_preview"] != true {
+		t.Errorf("payload = %v", got[0].Body)
+	}
+	if text := got[0].Body["text"]; text != "Clash-tester run failed (exit 2): subscription unreachable" {
+		t.Errorf("text = %q", text)
+	}
+}
+
+func TestChatPayloads(t *testing.T) {
+	srv, requests := newServer(t)
+	n, err := New(Config{Channels: []Channel{
+		{Type: "discord", URL: srv.URL + "/discord"},
+		{Type: "slack", URL: srv.URL + "/slack"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n.Send(context.Background(), Event{Type: EventAlert, Message: strings.Repeat("长", discordMaxLength+10)})
+	got := requests()
+	if len(got) != 2 {
+		t.Fatalf("got %d requests, want 2", len(got))
+	}
+	if content := got[0].Body["content"].(string); len([]rune(content)) != discordMaxLength || !strings.HasSuffix(content, "...") {
+		t.Errorf("discord content has %d characters, want %d ending with ...", len([]rune(content)), discordMaxLength)
+	}
+	if text := got[1].Body["text"].(string); len([]rune(text)) != discordMaxLength+10 {
+		t.Errorf("slack text truncated to %d characters", len([]rune(text)))
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int
+		ok       bool
+	}{
+		{"success", nil, 1, true},
+		{"recovers after 5xx", []int{http.StatusBadGateway, http.StatusServiceUnavailable}, 3, true},
+		{"rate limited", []int{http.StatusTooManyRequests}, 2, true},
+		{"gives up", []int{500, 500, 500, 500}, sendRetries + 1, false},
+		{"client error not retried", []int{http.StatusBadRequest}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := newServer(t, tt.statuses...)
+			ch := &Channel{Name: "hook", Type: "webhook", URL: srv.URL}
+			err := ch.send(context.Background(), Event{Type: EventRunFinished}, "hello")
+			if (err == nil) != tt.ok {
+				t.Errorf("err = %v, want ok %v", err, tt.ok)
+			}
+			if got := len(requests()); got != tt.requests {
+				t.Errorf("got %d requests, want %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+func TestSendRedactsBotToken(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+
+	ch := &Channel{Name: "tg", Type: "telegram", BotToken: "123:secret", ChatID: "1", APIBase: "http://127.0.0.1:1"}
+	err := ch.send(context.Background(), Event{}, "hello")
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("err = %v, want a request error without the bot token", err)
+	}
+}
+
+func TestThresholdEvents(t *testing.T) {
+	n, err := New(Config{Thresholds: []Threshold{
+		{Service: "openai", Min: 1},
+		{Service: "netflix", Min: 5, OnChange: true},
+		{Service: "claude", Min: 3},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := testReport()
+
+	thresholds := func(out Outcome) string {
+		var services []string
+		for _, ev := range n.Events(out) {
+			if ev.Type == EventThreshold {
+				services = append(services, ev.Service)
+			}
+		}
+		return strings.Join(services, ",")
+	}
+
+	// 没有上一次的报告时 on_change 阈值同样触发
+	if got := thresholds(Outcome{Report: report}); got != "openai,netflix" {
+		t.Errorf("thresholds = %q, want openai,netflix", got)
+	}
+	// 上一次已经低于阈值：on_change 不再重复发送
+	if got := thresholds(Outcome{Report: report, Previous: testReport()}); got != "openai" {
+		t.Errorf("thresholds = %q, want openai", got)
+	}
+	// 状态文件中的计数优先于上一次的报告
+	st := &State{Available: map[string]int{"netflix": 5}}
+	if got := thresholds(Outcome{Report: report, Previous: testReport(), State: st}); got != "openai,netflix" {
+		t.Errorf("thresholds = %q with netflix previously at 5, want openai,netflix", got)
+	}
+	// 没有测试结果时不检查阈值
+	if got := thresholds(Outcome{Report: &models.TestReport{}}); got != "" {
+		t.Errorf("thresholds = %q for an empty report", got)
+	}
+}
+
+func TestStateRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify_state.json")
+	st, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	st.Record(testReport(), now)
+	// 没有测试结果时保留上一次的计数
+	st.Record(&models.TestReport{}, now.Add(time.Hour))
+	if err := st.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err = LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Available["claude"] != 3 || st.Available["netflix"] != 2 || !st.Updated.Equal(now) {
+		t.Errorf("state = %+v, want claude 3, netflix 2 updated at %s", st, now)
+	}
+	if _, ok := st.Available["disney"]; ok {
+		t.Errorf("state records disney, which the report did not test")
+	}
+}
@@ -0,0 +1,61 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"Clash-tester/internal/fsutil"
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+)
+
+// State 各服务上一次的可用节点数，跨运行保存，供 on_change 阈值判断
+// 输出目录可能每轮清空 (如 Docker 中的临时目录)，因此不依赖上一次的报告
+type State struct {
+	Updated   time.Time      `json:"updated"`
+	Available map[string]int `json:"available"`
+}
+
+// LoadState 读取状态文件，文件不存在时返回空状态
+func LoadState(path string) (*State, error) {
+	st := &State{Available: make(map[string]int)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("invalid notify state %s: %w", path, err)
+	}
+	if st.Available == nil {
+		st.Available = make(map[string]int)
+	}
+	return st, nil
+}
+
+// Save 原子写入状态文件
+func (st *State) Save(path string) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, data, 0644)
+}
+
+// Record 记录本次报告中各服务的可用节点数
+// 没有测试结果或判定为全局故障时不记录，保留上一次有效的计数
+func (st *State) Record(report *models.TestReport, now time.Time) {
+	if !countable(report) {
+		return
+	}
+	for _, name := range tester.Services() {
+		if s, ok := report.Summary.Service(name); ok {
+			st.Available[name] = s.Available
+		}
+	}
+	st.Updated = now
+}
@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"Clash-tester/pkg/models"
+)
+
+// 支持的代理核心
+const (
+	KindMihomo  = "mihomo"
+	KindSingBox = "sing-box"
+	KindXray    = "xray"
+)
+
+// Kinds 全部支持的代理核心，第一个为默认
+var Kinds = []string{KindMihomo, KindSingBox, KindXray}
+
+// Core 本地代理核心
+// 加载全部待测节点，通过 SelectNode 切换出口节点，对外提供一个 HTTP 代理 (见 ProxyURL)
+type Core interface {
+	// Start 启动核心进程，ctx 只控制启动等待过程，进程本身的生命周期由 Stop 管理
+	Start(ctx context.Context) error
+	// Stop 停止核心并回收进程
+	Stop() error
+	// SelectNode 将出口切换到指定名称的节点
+	SelectNode(ctx context.Context, name string) error
+	// Healthy 核心是否仍在响应
+	Healthy(ctx context.Context) bool
+	// ProxyURL 本地 HTTP 代理地址
+	ProxyURL() string
+}
+
+// Options 创建代理核心的参数
+type Options struct {
+	Kind       string // 见 Kinds，为空表示 mihomo
+	BinaryPath string // 可执行文件路径，为空时使用 DefaultBinary
+	ConfigPath string // 生成的配置文件路径
+	Port       int    // 本地 HTTP 代理端口
+	APIPort    int    // 控制端口 (xray 不使用)
+	Nodes      []models.ProxyNode
+}
+
+// DefaultBinary 各代理核心默认的可执行文件
+func DefaultBinary(kind string) string {
+	switch kind {
+	case KindSingBox:
+		return "sing-box"
+	case KindXray:
+		return "xray"
+	default:
+		return "mihomo.exe"
+	}
+}
+
+// New 按 Kind 生成配置文件并创建代理核心，此时尚未启动
+func New(opts Options) (Core, error) {
+	if opts.BinaryPath == "" {
+		opts.BinaryPath = DefaultBinary(opts.Kind)
+	}
+	switch opts.Kind {
+	case KindMihomo, "":
+		return newMihomo(opts)
+	case KindSingBox:
+		return newSingBox(opts)
+	case KindXray:
+		return newXray(opts)
+	default:
+		return nil, fmt.Errorf("unknown core %q (supported: mihomo, sing-box, xray)", opts.Kind)
+	}
+}
+
+// EnsureRunning 检查核心是否仍在响应，失去响应时重启
+// 返回值表示是否进行了重启
+func EnsureRunning(ctx context.Context, c Core) (bool, error) {
+	if c.Healthy(ctx) {
+		return false, nil
+	}
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	c.Stop()
+	return true, c.Start(ctx)
+}
+
+// process 代理核心的子进程
+type process struct {
+	cmd *exec.Cmd
+}
+
+// start 启动子进程并等待 healthy 返回 true
+func (p *process) start(ctx context.Context, name string, healthy func(context.Context) bool, binary string, args ...string) error {
+	p.cmd = exec.Command(binary, args...)
+	// 父进程意外退出时让内核一并结束核心，避免遗留孤儿进程 (仅 Linux 生效)
+	setParentDeathSignal(p.cmd)
+
+	if err := p.cmd.Start(); err != nil {
+		p.cmd = nil
+		return err
+	}
+
+	// 并发启动多个核心时可能较慢，最多等待 10s
+	for i := 0; i < 20; i++ {
+		if healthy(ctx) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			p.stop()
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+
+	p.stop()
+	return fmt.Errorf("%s failed to start within timeout", name)
+}
+
+// stop 结束子进程并回收
+func (p *process) stop() error {
+	if p.cmd == nil || p.cmd.Process == nil {
+		return nil
+	}
+	err := p.cmd.Process.Kill()
+	// Wait 回收子进程，避免留下僵尸进程；被 Kill 后返回的错误无需关心
+	p.cmd.Wait()
+	p.cmd = nil
+	return err
+}
+
+// lookBinary 查找可执行文件，支持路径与 PATH 中的命令名
+func lookBinary(name, binary string) (string, error) {
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return "", fmt.Errorf("%s binary not found at %s", name, binary)
+	}
+	return path, nil
+}
@@ -2,21 +2,24 @@ package proxy
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"time"
+
+	"Clash-tester/internal/config"
 )
 
+// MihomoCore mihomo 核心，通过 external-controller 切换 GLOBAL 组的节点
 type MihomoCore struct {
 	BinaryPath string
 	ConfigPath string
 	Port       int
 	APIPort    int
-	cmd        *exec.Cmd
+	proc       process
 }
 
 func NewMihomoCore(binaryPath, configPath string, port, apiPort int) *MihomoCore {
@@ -28,8 +31,17 @@ func NewMihomoCore(binaryPath, configPath string, port, apiPort int) *MihomoCore
 	}
 }
 
+// newMihomo 生成 mihomo 配置并创建核心
+func newMihomo(opts Options) (*MihomoCore, error) {
+	if err := config.GenerateMihomoConfig(opts.Nodes, opts.ConfigPath, opts.Port, opts.APIPort); err != nil {
+		return nil, err
+	}
+	return NewMihomoCore(opts.BinaryPath, opts.ConfigPath, opts.Port, opts.APIPort), nil
+}
+
 // Start 启动mihomo核心
-func (m *MihomoCore) Start() error {
+// ctx 只控制启动等待过程，进程本身的生命周期由 Stop 管理
+func (m *MihomoCore) Start(ctx context.Context) error {
 	// 确保配置文件路径是绝对路径
 	absConfigPath, err := filepath.Abs(m.ConfigPath)
 	if err != nil {
@@ -52,31 +64,34 @@ func (m *MihomoCore) Start() error {
 	}
 
 	// Windows下通常是mihomo.exe，确保路径正确
-	m.cmd = exec.Command(m.BinaryPath, "-f", absConfigPath, "-d", filepath.Dir(absConfigPath))
+	return m.proc.start(ctx, "mihomo", m.Healthy, m.BinaryPath, "-f", absConfigPath, "-d", filepath.Dir(absConfigPath))
+}
 
-	// 重定向输出以便调试（可选，或者设为nil忽略）
-	// m.cmd.Stdout = os.Stdout
-	// m.cmd.Stderr = os.Stderr
+// Healthy 控制端口是否仍在响应
+func (m *MihomoCore) Healthy(ctx context.Context) bool {
+	return controllerHealthy(ctx, m.APIPort)
+}
 
-	if err := m.cmd.Start(); err != nil {
-		return err
-	}
+// SelectNode 切换代理节点
+func (m *MihomoCore) SelectNode(ctx context.Context, proxyName string) error {
+	return selectClashProxy(ctx, m.APIPort, "GLOBAL", proxyName)
+}
 
-	// 等待核心启动
-	// 这里可以优化为轮询检测API端口是否通
-	for i := 0; i < 20; i++ { // 增加等待时间，因为并发启动可能慢
-		if m.checkHealth() {
-			return nil
-		}
-		time.Sleep(500 * time.Millisecond)
-	}
+// Stop 停止mihomo核心并回收进程
+func (m *MihomoCore) Stop() error {
+	return m.proc.stop()
+}
 
-	return fmt.Errorf("mihomo failed to start within timeout")
+// ProxyURL 获取代理地址
+func (m *MihomoCore) ProxyURL() string {
+	return fmt.Sprintf("http://127.0.0.1:%d", m.Port)
 }
 
-func (m *MihomoCore) checkHealth() bool {
-	url := fmt.Sprintf("http://127.0.0.1:%d", m.APIPort)
-	resp, err := http.Get(url)
+// controllerHealthy 检查 Clash API 控制端口是否响应 (mihomo 与 sing-box 共用)
+func controllerHealthy(ctx context.Context, apiPort int) bool {
+	url := fmt.Sprintf("http://127.0.0.1:%d", apiPort)
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return false
 	}
@@ -84,14 +99,14 @@ func (m *MihomoCore) checkHealth() bool {
 	return true
 }
 
-// SwitchProxy 切换代理节点
-func (m *MihomoCore) SwitchProxy(proxyName string) error {
-	url := fmt.Sprintf("http://127.0.0.1:%d/proxies/GLOBAL", m.APIPort)
+// selectClashProxy 通过 Clash API 切换 selector 组中选中的节点 (mihomo 与 sing-box 共用)
+func selectClashProxy(ctx context.Context, apiPort int, group, proxyName string) error {
+	url := fmt.Sprintf("http://127.0.0.1:%d/proxies/%s", apiPort, group)
 
 	data := map[string]string{"name": proxyName}
 	jsonData, _ := json.Marshal(data)
 
-	req, _ := http.NewRequest("PUT", url, bytes.NewBuffer(jsonData))
+	req, _ := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
 
 	client := &http.Client{Timeout: 5 * time.Second}
@@ -106,17 +121,4 @@ func (m *MihomoCore) SwitchProxy(proxyName string) error {
 	}
 
 	return nil
-}
-
-// Stop 停止mihomo核心
-func (m *MihomoCore) Stop() error {
-	if m.cmd != nil && m.cmd.Process != nil {
-		return m.cmd.Process.Kill()
-	}
-	return nil
-}
-
-// GetProxyURL 获取代理地址
-func (m *MihomoCore) GetProxyURL() string {
-	return fmt.Sprintf("http://127.0.0.1:%d", m.Port)
 }
\ No newline at end of file
@@ -0,0 +1,11 @@
+package proxy
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setParentDeathSignal 父进程退出时向子进程发送 SIGKILL
+func setParentDeathSignal(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+}
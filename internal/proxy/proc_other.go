@@ -0,0 +1,8 @@
+//go:build !linux
+
+package proxy
+
+import "os/exec"
+
+// setParentDeathSignal 非 Linux 平台没有 Pdeathsig，依赖 Stop 清理
+func setParentDeathSignal(cmd *exec.Cmd) {}
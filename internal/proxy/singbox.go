@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"Clash-tester/internal/config"
+)
+
+// SingBoxCore sing-box 核心，所有节点放在一个 selector 出站中，通过 Clash API 切换
+type SingBoxCore struct {
+	BinaryPath string
+	ConfigPath string
+	Port       int
+	APIPort    int
+
+	unsupported map[string]error // 无法转换为 sing-box 配置的节点
+	proc        process
+}
+
+// newSingBox 生成 sing-box 配置并创建核心
+func newSingBox(opts Options) (*SingBoxCore, error) {
+	unsupported, err := config.GenerateSingBoxConfig(opts.Nodes, opts.ConfigPath, opts.Port, opts.APIPort)
+	if err != nil {
+		return nil, err
+	}
+	return &SingBoxCore{
+		BinaryPath:  opts.BinaryPath,
+		ConfigPath:  opts.ConfigPath,
+		Port:        opts.Port,
+		APIPort:     opts.APIPort,
+		unsupported: unsupported,
+	}, nil
+}
+
+// Start 启动 sing-box 核心
+func (s *SingBoxCore) Start(ctx context.Context) error {
+	binary, err := lookBinary("sing-box", s.BinaryPath)
+	if err != nil {
+		return err
+	}
+	absConfigPath, err := filepath.Abs(s.ConfigPath)
+	if err != nil {
+		return err
+	}
+	return s.proc.start(ctx, "sing-box", s.Healthy, binary, "run", "-c", absConfigPath, "-D", filepath.Dir(absConfigPath))
+}
+
+// Stop 停止 sing-box 核心并回收进程
+func (s *SingBoxCore) Stop() error {
+	return s.proc.stop()
+}
+
+// SelectNode 切换 selector 选中的节点
+func (s *SingBoxCore) SelectNode(ctx context.Context, name string) error {
+	if err := s.unsupported[name]; err != nil {
+		return fmt.Errorf("node not supported by sing-box: %w", err)
+	}
+	return selectClashProxy(ctx, s.APIPort, config.SingBoxSelector, name)
+}
+
+// Healthy Clash API 是否仍在响应
+func (s *SingBoxCore) Healthy(ctx context.Context) bool {
+	return controllerHealthy(ctx, s.APIPort)
+}
+
+// ProxyURL 获取代理地址 (mixed 入站同时支持 HTTP 与 SOCKS)
+func (s *SingBoxCore) ProxyURL() string {
+	return fmt.Sprintf("http://127.0.0.1:%d", s.Port)
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+
+	"Clash-tester/internal/config"
+	"Clash-tester/pkg/models"
+)
+
+// XrayCore xray 核心
+// xray 不能在运行时切换出站，每次切换节点都会重新生成只含该节点的配置并重启进程
+type XrayCore struct {
+	BinaryPath string
+	ConfigPath string
+	Port       int
+
+	nodes map[string]models.ProxyNode
+	proc  process
+}
+
+// newXray 创建 xray 核心，启动时使用丢弃全部流量的配置
+func newXray(opts Options) (*XrayCore, error) {
+	if err := config.GenerateXrayConfig(nil, opts.ConfigPath, opts.Port); err != nil {
+		return nil, err
+	}
+	// 重名节点只保留第一个，与 mihomo / sing-box 一致
+	nodes := make(map[string]models.ProxyNode, len(opts.Nodes))
+	for _, node := range opts.Nodes {
+		if _, ok := nodes[node.Name]; !ok {
+			nodes[node.Name] = node
+		}
+	}
+	return &XrayCore{
+		BinaryPath: opts.BinaryPath,
+		ConfigPath: opts.ConfigPath,
+		Port:       opts.Port,
+		nodes:      nodes,
+	}, nil
+}
+
+// Start 以当前配置启动 xray 核心
+func (x *XrayCore) Start(ctx context.Context) error {
+	binary, err := lookBinary("xray", x.BinaryPath)
+	if err != nil {
+		return err
+	}
+	absConfigPath, err := filepath.Abs(x.ConfigPath)
+	if err != nil {
+		return err
+	}
+	return x.proc.start(ctx, "xray", x.Healthy, binary, "run", "-c", absConfigPath)
+}
+
+// Stop 停止 xray 核心并回收进程
+func (x *XrayCore) Stop() error {
+	return x.proc.stop()
+}
+
+// SelectNode 生成只含该节点的配置并重启核心
+func (x *XrayCore) SelectNode(ctx context.Context, name string) error {
+	node, ok := x.nodes[name]
+	if !ok {
+		return fmt.Errorf("node %q not found", name)
+	}
+	if err := config.GenerateXrayConfig(&node, x.ConfigPath, x.Port); err != nil {
+		return fmt.Errorf("node not supported by xray: %w", err)
+	}
+	x.Stop()
+	if err := x.Start(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Healthy 本地代理端口是否仍在监听
+func (x *XrayCore) Healthy(ctx context.Context) bool {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", fmt.Sprintf("127.0.0.1:%d", x.Port))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// ProxyURL 获取代理地址
+func (x *XrayCore) ProxyURL() string {
+	return fmt.Sprintf("http://127.0.0.1:%d", x.Port)
+}
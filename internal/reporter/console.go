@@ -1,8 +1,10 @@
 package reporter
 
 import (
+	"Clash-tester/internal/tester"
 	"Clash-tester/pkg/models"
 	"fmt"
+	"io"
 	"strings"
 )
 
@@ -10,26 +12,73 @@ func PrintConsole(report models.TestReport) {
 	fmt.Println("\n" + strings.Repeat("=", 80))
 	fmt.Printf("Clash AI Service Tester - Test Report\n")
 	fmt.Printf("Test Time: %s\n", report.TestTime.Format("2006-01-02 15:04:05"))
-	fmt.Printf("Source: %s\n", report.Source)
+	fmt.Printf("Source: %s\n", RedactSource(report.Source))
+	if report.Core != "" {
+		fmt.Printf("Core: %s\n", report.Core)
+	}
 	fmt.Println(strings.Repeat("=", 80))
 
 	fmt.Printf("\nTotal Nodes: %d | Tested: %d | At least one service available: %d\n\n",
 		report.TotalNodes, report.TestedNodes, report.SuccessNodes)
 
+	if report.Incomplete {
+		fmt.Printf("⚠️  Incomplete run: %s\n\n", report.IncompleteReason)
+	}
+
+	for _, failure := range report.GateFailures {
+		fmt.Printf("🚫 Quality gate failed: %s\n", failure)
+	}
+	if len(report.GateFailures) > 0 {
+		fmt.Println()
+	}
+
+	// 全局故障：节点失败没有参考价值，不逐个列出
+	nodes := report.Results
+	if report.Canary != nil && report.Canary.GlobalOutage {
+		fmt.Printf("🌐 GLOBAL OUTAGE: %s\n", report.Canary.Reason)
+		fmt.Printf("   %d node results are not listed individually, they reflect the tester's connectivity rather than the nodes\n\n",
+			len(report.Results))
+		nodes = nil
+	}
+
 	// 打印每个节点的结果
-	for i, node := range report.Results {
+	for i, node := range nodes {
 		fmt.Printf("[%d] %s (%s - %s)\n", i+1, node.NodeName, node.NodeType, node.Server)
+		if node.Error != "" {
+			fmt.Printf("  ⚠️  %s\n", node.Error)
+		}
 
 		fmt.Println("  [AI Services]")
-		printServiceResult("OpenAI", node.Tests["openai"])
-		printServiceResult("Gemini", node.Tests["gemini"])
-		printServiceResult("Claude", node.Tests["claude"])
+		for _, svc := range aiOrder {
+			if test, ok := node.Tests[svc.Key]; ok {
+				printServiceResult(svc.Title, test)
+			} else {
+				printSkipped(svc.Title)
+			}
+		}
 		
 		fmt.Println("  [Streaming]")
-		printStreamResult("Netflix", node.StreamTests["netflix"])
-		printStreamResult("Disney+", node.StreamTests["disney"])
-		printStreamResult("Youtube", node.StreamTests["youtube"])
-		printStreamResult("HBO Max", node.StreamTests["max"])
+		for _, svc := range streamOrder {
+			if test, ok := node.StreamTests[svc.Key]; ok {
+				printStreamResult(svc.Title, test)
+			} else {
+				printSkipped(svc.Title)
+			}
+		}
+
+		if node.SpeedTest != nil {
+			fmt.Println("  [Speed]")
+			printSpeedResult(*node.SpeedTest)
+		}
+
+		if len(node.Stability) > 0 {
+			fmt.Println("  [Stability]")
+			for _, name := range tester.Services() {
+				if stat, ok := node.Stability[name]; ok {
+					printStabilityResult(name, stat)
+				}
+			}
+		}
 
 		fmt.Println()
 	}
@@ -39,19 +88,41 @@ func PrintConsole(report models.TestReport) {
 	fmt.Println("Summary:")
 	
 	fmt.Println("  [AI Services]")
-	printSummaryLine("OpenAI", report.Summary.OpenAI)
-	printSummaryLine("Gemini", report.Summary.Gemini)
-	printSummaryLine("Claude", report.Summary.Claude)
+	for _, svc := range aiOrder {
+		s, _ := report.Summary.Service(svc.Key)
+		printSummaryLine(svc.Title, s)
+	}
 	
 	fmt.Println("  [Streaming]")
-	printSummaryLine("Netflix", report.Summary.Streaming["netflix"])
-	printSummaryLine("Disney+", report.Summary.Streaming["disney"])
-	printSummaryLine("Youtube", report.Summary.Streaming["youtube"])
-	printSummaryLine("HBO Max", report.Summary.Streaming["max"])
-	
+	for _, svc := range streamOrder {
+		s, _ := report.Summary.Service(svc.Key)
+		printSummaryLine(svc.Title, s)
+	}
+
+	if len(report.Summary.Stability) > 0 {
+		fmt.Println("  [Stability]")
+		for _, name := range tester.Services() {
+			if s, ok := report.Summary.Stability[name]; ok {
+				fmt.Printf("    %-8s: stable %-3d | flaky %-3d | down %-3d | avg success %.0f%%\n",
+					name, s.Stable, s.Flaky, s.Down, s.AvgSuccessRatio*100)
+			}
+		}
+	}
+
 	fmt.Println(strings.Repeat("=", 80))
 }
 
+// aiOrder / streamOrder 各服务的输出顺序与显示名
+var (
+	aiOrder     = serviceColumns(tester.AIServices)
+	streamOrder = serviceColumns(tester.StreamServices)
+)
+
+// printSkipped 未检测的服务 (检测项未启用或节点未能测试)
+func printSkipped(name string) {
+	fmt.Printf("    - %-8s [Skipped]\n", name)
+}
+
 func printServiceResult(name string, test models.ServiceTest) {
 	status := "✗"
 	if test.Available {
@@ -90,7 +161,45 @@ func printStreamResult(name string, test models.StreamTest) {
 	fmt.Println(info)
 }
 
+func printSpeedResult(test models.SpeedTest) {
+	if !test.Available {
+		fmt.Println("    ✗ Download [Failed]")
+		return
+	}
+	fmt.Printf("    ✓ Download [%.2f Mbps] (TTFB %dms, %.1f MB in %dms)\n",
+		test.DownloadMbps, test.TTFB, float64(test.Bytes)/1024/1024, test.Duration)
+}
+
+func printStabilityResult(name string, stat models.ServiceStability) {
+	info := fmt.Sprintf("    %-8s %d/%d (%.0f%%) %-6s", name, stat.Successes, stat.Rounds, stat.SuccessRatio*100, stat.Class)
+	if stat.Successes > 0 {
+		info += fmt.Sprintf(" latency %d-%dms avg %dms ±%dms", stat.LatencyMin, stat.LatencyMax, stat.LatencyAvg, stat.LatencyStdev)
+	}
+	fmt.Println(strings.TrimRight(info, " "))
+}
+
 func printSummaryLine(name string, summary models.ServiceSummary) {
 	fmt.Printf("    %-8s: ✓ %-3d | ✗ %-3d | Countries: %v\n",
 		name, summary.Available, summary.Unavailable, summary.Countries)
-}
\ No newline at end of file
+}
+
+// PrintCanary 打印一次自检的结果
+func PrintCanary(w io.Writer, stage string, result models.CanaryResult) {
+	fmt.Fprintf(w, "  [Canary %s]\n", stage)
+	printCanaryChecks(w, "direct", result.Direct)
+	printCanaryChecks(w, "reference", result.Reference)
+	if result.Outage {
+		fmt.Fprintf(w, "  🌐 Outage: %s\n", result.Reason)
+	}
+	fmt.Fprintln(w)
+}
+
+func printCanaryChecks(w io.Writer, via string, checks []models.CanaryCheck) {
+	for _, c := range checks {
+		if c.Reachable {
+			fmt.Fprintf(w, "    %-9s %-8s: ✅ %d (%dms)\n", via, c.Name, c.StatusCode, c.ResponseTime)
+		} else {
+			fmt.Fprintf(w, "    %-9s %-8s: ❌ %s\n", via, c.Name, c.Error)
+		}
+	}
+}
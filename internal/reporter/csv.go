@@ -0,0 +1,79 @@
+package reporter
+
+import (
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// RenderCSV 输出每个节点一行的 CSV，每个服务占一组列
+// AI 服务：available, country, latency_ms, error
+// 流媒体：available, region, detail, latency_ms, error
+func RenderCSV(w io.Writer, report models.TestReport) error {
+	// UTF-8 BOM，使 Excel 正确识别中文节点名
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+
+	header := []string{"name", "type", "server", "success", "error", "total_time_ms", "tested_at"}
+	for _, s := range tester.AIServices {
+		header = append(header, s+"_available", s+"_country", s+"_latency_ms", s+"_error")
+	}
+	for _, s := range tester.StreamServices {
+		header = append(header, s+"_available", s+"_region", s+"_detail", s+"_latency_ms", s+"_error")
+	}
+	header = append(header, "download_mbps", "download_ttfb_ms")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, node := range report.Results {
+		row := []string{
+			node.NodeName,
+			node.NodeType,
+			node.Server,
+			strconv.FormatBool(tester.IsNodeSuccess(node)),
+			node.Error,
+			strconv.Itoa(node.TotalTime),
+			node.TestedAt.Format("2006-01-02 15:04:05"),
+		}
+		for _, s := range tester.AIServices {
+			t, ok := node.Tests[s]
+			if !ok {
+				row = append(row, "", "", "", "")
+				continue
+			}
+			row = append(row, strconv.FormatBool(t.Available), t.Country, latency(t.ResponseTime), t.Error)
+		}
+		for _, s := range tester.StreamServices {
+			t, ok := node.StreamTests[s]
+			if !ok {
+				row = append(row, "", "", "", "", "")
+				continue
+			}
+			row = append(row, strconv.FormatBool(t.Available), t.Region, t.Details, latency(t.ResponseTime), t.Error)
+		}
+		if node.SpeedTest != nil && node.SpeedTest.Available {
+			row = append(row, fmt.Sprintf("%.2f", node.SpeedTest.DownloadMbps), strconv.Itoa(node.SpeedTest.TTFB))
+		} else {
+			row = append(row, "", "")
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func latency(ms int) string {
+	if ms <= 0 {
+		return ""
+	}
+	return strconv.Itoa(ms)
+}
@@ -0,0 +1,158 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"Clash-tester/internal/fsutil"
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+)
+
+// SaveDebugBundle 将单个节点的检测记录保存为排查目录，返回目录路径
+// 目录位于 dir/<节点名>_<时间戳>/，包含：
+//
+//	result.json                    节点测试结果
+//	trace.json                     全部检测的请求、响应与判定依据
+//	NN-<service>-<attempt>.txt     每次检测的可读记录
+//	NN-<service>-<attempt>-K.body  每个响应的正文摘录
+//
+// Cookie、Authorization 等敏感头部的取值会被隐去
+func SaveDebugBundle(dir string, result models.NodeTestResult, checks []tester.CheckRecord) (string, error) {
+	bundle := filepath.Join(dir, fmt.Sprintf("%s_%s", fsutil.SafeName(result.NodeName), time.Now().Format("20060102_150405")))
+	if err := os.MkdirAll(bundle, 0755); err != nil {
+		return "", err
+	}
+
+	if err := writeJSONFile(filepath.Join(bundle, "result.json"), result); err != nil {
+		return bundle, err
+	}
+	redacted := make([]tester.CheckRecord, len(checks))
+	for i, check := range checks {
+		redacted[i] = check.Redacted()
+	}
+	checks = redacted
+	if err := writeJSONFile(filepath.Join(bundle, "trace.json"), checks); err != nil {
+		return bundle, err
+	}
+
+	for i, check := range checks {
+		prefix := fmt.Sprintf("%02d-%s-%d", i+1, check.Service, check.Attempt)
+		var b strings.Builder
+		writeCheckText(&b, check, prefix)
+		if err := os.WriteFile(filepath.Join(bundle, prefix+".txt"), []byte(b.String()), 0644); err != nil {
+			return bundle, err
+		}
+		for k, ex := range check.Exchanges {
+			if ex.Response == nil || ex.Response.Body == "" {
+				continue
+			}
+			name := fmt.Sprintf("%s-%d.body", prefix, k+1)
+			if err := os.WriteFile(filepath.Join(bundle, name), []byte(ex.Response.Body), 0644); err != nil {
+				return bundle, err
+			}
+		}
+	}
+	return bundle, nil
+}
+
+// SaveHAR 将节点失败检测的 HAR 写入 dir (<时间>_<节点名>.har)，返回文件路径；没有失败的检测时返回空字符串
+func SaveHAR(dir string, result models.NodeTestResult, failed []tester.CheckRecord) (string, error) {
+	if len(failed) == 0 {
+		return "", nil
+	}
+
+	data, err := json.MarshalIndent(tester.BuildHAR(result.NodeName, failed), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s_%s.har", result.TestedAt.Format("20060102_150405"), fsutil.SafeName(result.NodeName))
+	path := filepath.Join(dir, name)
+	if err := fsutil.WriteFileAtomic(path, data, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// debugExcerpt 可读记录中正文的最大字符数，完整摘录见 .body 文件
+const debugExcerpt = 2000
+
+func writeCheckText(b *strings.Builder, check tester.CheckRecord, prefix string) {
+	status := "FAILED"
+	if check.Available {
+		status = "OK"
+	}
+	fmt.Fprintf(b, "# %s attempt %d: %s (%dms)\n", check.Service, check.Attempt, status, check.Duration)
+	if check.Error != "" {
+		fmt.Fprintf(b, "error: %s\n", check.Error)
+	}
+	if len(check.Notes) > 0 {
+		b.WriteString("\n## Heuristics\n")
+		for _, n := range check.Notes {
+			fmt.Fprintf(b, "- %s\n", n)
+		}
+	}
+
+	for k, ex := range check.Exchanges {
+		fmt.Fprintf(b, "\n## Request %d (%dms)\n", k+1, ex.Duration)
+		fmt.Fprintf(b, "%s %s %s\n", ex.Request.Method, ex.Request.URL, ex.Request.Proto)
+		writeHeaders(b, ex.Request.Headers)
+		if ex.Error != "" {
+			fmt.Fprintf(b, "\nerror: %s\n", ex.Error)
+		}
+		resp := ex.Response
+		if resp == nil {
+			continue
+		}
+		fmt.Fprintf(b, "\n%s %d\n", resp.Proto, resp.Status)
+		writeHeaders(b, resp.Headers)
+		if resp.Location != "" {
+			fmt.Fprintf(b, "-> redirect to %s\n", resp.Location)
+		}
+		if resp.Body == "" {
+			continue
+		}
+		fmt.Fprintf(b, "\n--- body excerpt (%d bytes", len(resp.Body))
+		if resp.Truncated {
+			b.WriteString(", truncated")
+		}
+		fmt.Fprintf(b, ", full excerpt in %s-%d.body) ---\n", prefix, k+1)
+		if utf8.ValidString(resp.Body) {
+			body := resp.Body
+			if len(body) > debugExcerpt {
+				body = strings.ToValidUTF8(body[:debugExcerpt], "") + "\n..."
+			}
+			b.WriteString(body)
+			b.WriteString("\n")
+		} else {
+			b.WriteString("(binary)\n")
+		}
+	}
+}
+
+func writeHeaders(b *strings.Builder, h map[string][]string) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(b, "%s: %s\n", k, v)
+		}
+	}
+}
+
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
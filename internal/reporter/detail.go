@@ -0,0 +1,102 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+)
+
+// PrintNodeDetail 输出单个节点每项检测的完整信息 (状态码、地区、耗时分解、错误)，用于 test-node 排查
+func PrintNodeDetail(w io.Writer, result models.NodeTestResult) {
+	fmt.Fprintf(w, "🔬 %s (%s - %s), %dms\n", result.NodeName, result.NodeType, result.Server, result.TotalTime)
+	if result.Error != "" {
+		fmt.Fprintf(w, "  ⚠️  %s\n", result.Error)
+	}
+
+	fmt.Fprintln(w, "  [AI Services]")
+	for _, svc := range aiOrder {
+		test, ok := result.Tests[svc.Key]
+		if !ok {
+			fmt.Fprintf(w, "    - %-8s skipped\n", svc.Title)
+			continue
+		}
+		fields := []string{fmt.Sprintf("attempts %d", test.Attempts)}
+		if test.StatusCode != 0 {
+			fields = append(fields, fmt.Sprintf("HTTP %d", test.StatusCode))
+		}
+		if test.Country != "" {
+			fields = append(fields, "country "+test.Country)
+		}
+		if test.Region != "" {
+			fields = append(fields, "region "+test.Region)
+		}
+		printDetailLine(w, svc.Title, test.Available, test.ResponseTime, fields, test.Timing, test.Error)
+	}
+
+	fmt.Fprintln(w, "  [Streaming]")
+	for _, svc := range streamOrder {
+		test, ok := result.StreamTests[svc.Key]
+		if !ok {
+			fmt.Fprintf(w, "    - %-8s skipped\n", svc.Title)
+			continue
+		}
+		var fields []string
+		if test.Region != "" {
+			fields = append(fields, "region "+test.Region)
+		}
+		if test.Details != "" {
+			fields = append(fields, test.Details)
+		}
+		printDetailLine(w, svc.Title, test.Available, test.ResponseTime, fields, test.Timing, test.Error)
+	}
+
+	if st := result.SpeedTest; st != nil {
+		fmt.Fprintln(w, "  [Speed]")
+		fields := []string{fmt.Sprintf("%.2f Mbps", st.DownloadMbps), fmt.Sprintf("TTFB %dms", st.TTFB),
+			fmt.Sprintf("%.1f MB", float64(st.Bytes)/1024/1024), st.URL}
+		printDetailLine(w, "Download", st.Available, st.Duration, fields, nil, st.Error)
+	}
+}
+
+func printDetailLine(w io.Writer, name string, ok bool, ms int, fields []string, timing *models.Timing, errMsg string) {
+	status := "✗"
+	if ok {
+		status = "✓"
+	}
+	line := fmt.Sprintf("    %s %-8s %5dms", status, name, ms)
+	if len(fields) > 0 {
+		line += "  " + strings.Join(fields, ", ")
+	}
+	fmt.Fprintln(w, line)
+
+	if timing != nil && (timing.Total > 0 || timing.Reused) {
+		fmt.Fprintf(w, "        timing: %s\n", formatTiming(timing))
+	}
+	if errMsg != "" {
+		fmt.Fprintf(w, "        error [%s]: %s\n", tester.ErrorCategory(errMsg), errMsg)
+	}
+}
+
+// formatTiming 耗时分解，省略为 0 的阶段
+func formatTiming(t *models.Timing) string {
+	if t.Reused {
+		return fmt.Sprintf("reused connection, ttfb %dms, total %dms", t.TTFB, t.Total)
+	}
+	var parts []string
+	for _, p := range []struct {
+		name string
+		ms   int
+	}{
+		{"dns", t.DNS}, {"connect", t.Connect}, {"proxy", t.ProxyConnect},
+		{"tls", t.TLSHandshake}, {"ttfb", t.TTFB},
+	} {
+		if p.ms > 0 {
+			parts = append(parts, fmt.Sprintf("%s %dms", p.name, p.ms))
+		}
+	}
+	parts = append(parts, fmt.Sprintf("total %dms", t.Total))
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,204 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+)
+
+// ReportDiff 两份报告之间的差异，节点按名称对应
+type ReportDiff struct {
+	OldTime time.Time       `json:"old_time"`
+	NewTime time.Time       `json:"new_time"`
+	OldCore string          `json:"old_core,omitempty"` // 两份报告使用不同代理核心时，用于区分节点故障与核心兼容性问题
+	NewCore string          `json:"new_core,omitempty"`
+	Added   []string        `json:"added,omitempty"`   // 只出现在新报告中的节点
+	Removed []string        `json:"removed,omitempty"` // 只出现在旧报告中的节点
+	Changes []ServiceChange `json:"changes,omitempty"`
+	Summary []SummaryChange `json:"summary"`
+}
+
+// ServiceChange 单个节点上某项服务的可用性或地区变化
+// 只比较两份报告中都有检测结果的服务
+type ServiceChange struct {
+	Node         string `json:"node"`
+	Service      string `json:"service"`
+	Before       bool   `json:"before"`
+	After        bool   `json:"after"`
+	BeforeRegion string `json:"before_region,omitempty"`
+	AfterRegion  string `json:"after_region,omitempty"`
+	Error        string `json:"error,omitempty"` // 变为不可用时新报告中的错误
+}
+
+// SummaryChange 单个服务可用节点数的变化
+type SummaryChange struct {
+	Service string `json:"service"`
+	Before  int    `json:"before"`
+	After   int    `json:"after"`
+}
+
+// DiffReports 比较两份报告
+func DiffReports(old, cur *models.TestReport) ReportDiff {
+	d := ReportDiff{OldTime: old.TestTime, NewTime: cur.TestTime}
+	if old.Core != cur.Core {
+		d.OldCore, d.NewCore = coreName(old.Core), coreName(cur.Core)
+	}
+
+	oldNodes := make(map[string]models.NodeTestResult, len(old.Results))
+	for _, r := range old.Results {
+		oldNodes[r.NodeName] = r
+	}
+	curNodes := make(map[string]models.NodeTestResult, len(cur.Results))
+	for _, r := range cur.Results {
+		curNodes[r.NodeName] = r
+	}
+
+	for _, name := range sortedKeys(curNodes) {
+		before, ok := oldNodes[name]
+		if !ok {
+			d.Added = append(d.Added, name)
+			continue
+		}
+		after := curNodes[name]
+		for _, svc := range tester.Services() {
+			b, okB := serviceOutcome(before, svc)
+			a, okA := serviceOutcome(after, svc)
+			if !okB || !okA {
+				continue
+			}
+			if b.available == a.available && (!a.available || b.region == a.region) {
+				continue
+			}
+			change := ServiceChange{
+				Node: name, Service: svc,
+				Before: b.available, After: a.available,
+				BeforeRegion: b.region, AfterRegion: a.region,
+			}
+			if !a.available {
+				change.Error = a.err
+			}
+			d.Changes = append(d.Changes, change)
+		}
+	}
+	for _, name := range sortedKeys(oldNodes) {
+		if _, ok := curNodes[name]; !ok {
+			d.Removed = append(d.Removed, name)
+		}
+	}
+
+	for _, svc := range tester.Services() {
+		b, _ := old.Summary.Service(svc)
+		a, _ := cur.Summary.Service(svc)
+		d.Summary = append(d.Summary, SummaryChange{Service: svc, Before: b.Available, After: a.Available})
+	}
+	return d
+}
+
+type outcome struct {
+	available bool
+	region    string
+	err       string
+}
+
+// serviceOutcome 取出节点某项服务的结果，AI 服务以国家作为地区
+func serviceOutcome(r models.NodeTestResult, svc string) (outcome, bool) {
+	if t, ok := r.Tests[svc]; ok {
+		return outcome{t.Available, t.Country, t.Error}, true
+	}
+	if t, ok := r.StreamTests[svc]; ok {
+		return outcome{t.Available, t.Region, t.Error}, true
+	}
+	return outcome{}, false
+}
+
+func sortedKeys(m map[string]models.NodeTestResult) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Empty 两份报告的节点与检测结果是否完全一致
+func (d ReportDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changes) == 0
+}
+
+// coreName 旧报告没有记录代理核心，当时只支持 mihomo
+func coreName(core string) string {
+	if core == "" {
+		return "mihomo"
+	}
+	return core
+}
+
+// PrintDiff 以文本形式输出差异
+func PrintDiff(w io.Writer, d ReportDiff) {
+	fmt.Fprintf(w, "Comparing %s -> %s\n", d.OldTime.Format("2006-01-02 15:04:05"), d.NewTime.Format("2006-01-02 15:04:05"))
+	if d.OldCore != d.NewCore {
+		fmt.Fprintf(w, "Core: %s -> %s\n", d.OldCore, d.NewCore)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "Summary (available nodes):")
+	for _, s := range d.Summary {
+		delta := ""
+		if s.After != s.Before {
+			delta = fmt.Sprintf(" (%+d)", s.After-s.Before)
+		}
+		fmt.Fprintf(w, "  %-8s %3d -> %3d%s\n", s.Service, s.Before, s.After, delta)
+	}
+
+	if d.Empty() {
+		fmt.Fprintln(w, "\n✅ No node or service changes")
+		return
+	}
+	if len(d.Added) > 0 {
+		fmt.Fprintf(w, "\n➕ Added nodes (%d):\n", len(d.Added))
+		for _, name := range d.Added {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+	}
+	if len(d.Removed) > 0 {
+		fmt.Fprintf(w, "\n➖ Removed nodes (%d):\n", len(d.Removed))
+		for _, name := range d.Removed {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+	}
+	if len(d.Changes) > 0 {
+		fmt.Fprintf(w, "\n🔄 Service changes (%d):\n", len(d.Changes))
+		for _, c := range d.Changes {
+			switch {
+			case c.After && !c.Before:
+				fmt.Fprintf(w, "  ✓ %s  %s now available%s\n", c.Node, c.Service, regionSuffix(c.AfterRegion))
+			case !c.After:
+				fmt.Fprintf(w, "  ✗ %s  %s no longer available", c.Node, c.Service)
+				if c.Error != "" {
+					fmt.Fprintf(w, ": %s", c.Error)
+				}
+				fmt.Fprintln(w)
+			default:
+				fmt.Fprintf(w, "  ~ %s  %s region %s -> %s\n", c.Node, c.Service, orDash(c.BeforeRegion), orDash(c.AfterRegion))
+			}
+		}
+	}
+}
+
+func regionSuffix(region string) string {
+	if region == "" {
+		return ""
+	}
+	return " [" + region + "]"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
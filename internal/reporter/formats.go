@@ -0,0 +1,99 @@
+package reporter
+
+import (
+	"Clash-tester/internal/fsutil"
+	"Clash-tester/pkg/models"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 输出格式
+const (
+	FormatJSON     = "json" // 详细报告 test_result_*.json
+	FormatTags     = "tags" // SubStore 使用的 tags.json (路径由 -map-output 指定)
+	FormatCSV      = "csv"  // 每个节点一行的表格
+	FormatMarkdown = "md"   // 精简的 Markdown 摘要
+	FormatHTML     = "html" // 独立 HTML 报告
+)
+
+// DefaultFormats 默认输出格式
+const DefaultFormats = "json,tags,html"
+
+// ParseFormats 解析逗号分隔的输出格式列表
+func ParseFormats(spec string) ([]string, error) {
+	var formats []string
+	seen := make(map[string]bool)
+	for _, f := range strings.Split(spec, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" || seen[f] {
+			continue
+		}
+		switch f {
+		case FormatJSON, FormatTags, FormatCSV, FormatMarkdown, FormatHTML:
+		case "markdown":
+			f = FormatMarkdown
+		default:
+			return nil, fmt.Errorf("unknown format %q (supported: json, tags, csv, md, html)", f)
+		}
+		seen[f] = true
+		formats = append(formats, f)
+	}
+	return formats, nil
+}
+
+// HasFormat formats 中是否包含 f
+func HasFormat(formats []string, f string) bool {
+	for _, item := range formats {
+		if item == f {
+			return true
+		}
+	}
+	return false
+}
+
+// SaveDetailed 以同一个时间戳在 outputDir 中写入各详细报告格式
+// tags 格式不在此处理，见 SaveTagMapJSON
+func SaveDetailed(report models.TestReport, outputDir string, formats []string) error {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+	stamp := time.Now().Format("20060102_150405")
+
+	var errs []error
+	for _, f := range formats {
+		var buf bytes.Buffer
+		var err error
+		switch f {
+		case FormatJSON:
+			var data []byte
+			if data, err = json.MarshalIndent(report, "", "  "); err == nil {
+				buf.Write(data)
+			}
+		case FormatCSV:
+			err = RenderCSV(&buf, report)
+		case FormatMarkdown:
+			err = RenderMarkdown(&buf, report)
+		case FormatHTML:
+			err = RenderHTML(&buf, report)
+		default:
+			continue
+		}
+		if err == nil {
+			path := filepath.Join(outputDir, fmt.Sprintf("test_result_%s.%s", stamp, f))
+			err = fsutil.WriteFileAtomic(path, buf.Bytes(), 0644)
+		}
+		if err == nil && f == FormatHTML {
+			err = fsutil.WriteFileAtomic(filepath.Join(outputDir, HTMLIndexName), buf.Bytes(), 0644)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,140 @@
+package reporter
+
+import (
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// HTMLIndexName 最新一份 HTML 报告的固定文件名，常驻模式下作为首页
+const HTMLIndexName = "index.html"
+
+//go:embed templates/report.html
+var templateFS embed.FS
+
+var htmlTemplate = template.Must(template.ParseFS(templateFS, "templates/report.html"))
+
+// serviceColumn 报告中的服务及显示名称
+type serviceColumn struct {
+	Key   string
+	Title string
+}
+
+func serviceColumns(services []string) []serviceColumn {
+	columns := make([]serviceColumn, 0, len(services))
+	for _, s := range services {
+		columns = append(columns, serviceColumn{Key: s, Title: tester.ServiceTitle(s)})
+	}
+	return columns
+}
+
+// htmlColumns HTML 与 Markdown 表格中的服务列
+var htmlColumns = serviceColumns(tester.Services())
+
+type htmlPage struct {
+	Report    models.TestReport
+	Generated time.Time
+	Outage    string
+	Columns   []string
+	Summary   []htmlSummary
+	Rows      []htmlRow
+}
+
+type htmlSummary struct {
+	Title     string
+	Available int
+	Total     int
+	Countries string
+}
+
+type htmlRow struct {
+	Name      string
+	Type      string
+	Server    string
+	Success   bool
+	Error     string
+	Cells     []htmlCell
+	Speed     string
+	SpeedSort float64
+	TotalTime int
+}
+
+type htmlCell struct {
+	Key       string
+	Tested    bool
+	Available bool
+	Label     string // 地区或详情
+	Latency   int
+	Error     string
+}
+
+// SaveHTML 在 outputDir 中写入独立的 HTML 报告 (不依赖任何外部资源)
+// 同时更新 index.html 为最新一份
+func SaveHTML(report models.TestReport, outputDir string) error {
+	return SaveDetailed(report, outputDir, []string{FormatHTML})
+}
+
+// RenderHTML 渲染 HTML 报告，订阅地址经 RedactSource 隐去 token
+func RenderHTML(w io.Writer, report models.TestReport) error {
+	report.Source = RedactSource(report.Source)
+	page := htmlPage{
+		Report:    report,
+		Generated: time.Now(),
+	}
+	if report.Canary != nil && report.Canary.GlobalOutage {
+		page.Outage = report.Canary.Reason
+	}
+
+	for _, col := range htmlColumns {
+		page.Columns = append(page.Columns, col.Title)
+		if s, ok := report.Summary.Service(col.Key); ok {
+			page.Summary = append(page.Summary, htmlSummary{
+				Title:     col.Title,
+				Available: s.Available,
+				Total:     s.Available + s.Unavailable,
+				Countries: strings.Join(s.Countries, ", "),
+			})
+		}
+	}
+
+	for _, node := range report.Results {
+		row := htmlRow{
+			Name:      node.NodeName,
+			Type:      node.NodeType,
+			Server:    node.Server,
+			Success:   tester.IsNodeSuccess(node),
+			Error:     node.Error,
+			TotalTime: node.TotalTime,
+		}
+		for _, col := range htmlColumns {
+			cell := htmlCell{Key: col.Key}
+			if t, ok := node.Tests[col.Key]; ok {
+				cell.Tested, cell.Available, cell.Latency, cell.Error = true, t.Available, t.ResponseTime, t.Error
+				cell.Label = t.Country
+			} else if t, ok := node.StreamTests[col.Key]; ok {
+				cell.Tested, cell.Available, cell.Latency, cell.Error = true, t.Available, t.ResponseTime, t.Error
+				cell.Label = t.Region
+				if t.Details != "" && t.Details != t.Region {
+					cell.Label = strings.TrimSpace(t.Region + " " + t.Details)
+				}
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		if node.SpeedTest != nil {
+			if node.SpeedTest.Available {
+				row.Speed = fmt.Sprintf("%.1f Mbps", node.SpeedTest.DownloadMbps)
+				row.SpeedSort = node.SpeedTest.DownloadMbps
+			} else {
+				row.Speed = "✗"
+			}
+		}
+		page.Rows = append(page.Rows, row)
+	}
+
+	return htmlTemplate.Execute(w, page)
+}
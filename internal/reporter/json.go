@@ -1,50 +1,89 @@
 package reporter
 
 import (
+	"Clash-tester/internal/fsutil"
 	"Clash-tester/pkg/models"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 )
 
 // NodeTagData 定义了输出给 SubStore 使用的精简数据结构
 type NodeTagData struct {
-	UpdateTime time.Time             `json:"update_time"`
-	OpenAI     *models.ServiceTest   `json:"openai,omitempty"`
-	Gemini     *models.ServiceTest   `json:"gemini,omitempty"`
-	Claude     *models.ServiceTest   `json:"claude,omitempty"`
-	Netflix    *StreamTagData        `json:"netflix,omitempty"`
-	Disney     *StreamTagData        `json:"disney,omitempty"`
-	Youtube    *StreamTagData        `json:"youtube,omitempty"`
-	Max        *StreamTagData        `json:"max,omitempty"`
+	UpdateTime time.Time         `json:"update_time"`
+	OpenAI     *ServiceTagData   `json:"openai,omitempty"`
+	Gemini     *ServiceTagData   `json:"gemini,omitempty"`
+	Claude     *ServiceTagData   `json:"claude,omitempty"`
+	Netflix    *StreamTagData    `json:"netflix,omitempty"`
+	Disney     *StreamTagData    `json:"disney,omitempty"`
+	Youtube    *StreamTagData    `json:"youtube,omitempty"`
+	Max        *StreamTagData    `json:"max,omitempty"`
+	Speed      *models.SpeedTest `json:"speed,omitempty"`
+	Stale      bool              `json:"stale,omitempty"` // 本次未能测试，沿用上次的结果
+}
+
+// ServiceTagData AI 服务条目，字段与 models.ServiceTest 相同
+type ServiceTagData struct {
+	models.ServiceTest
+	Pending   int        `json:"pending,omitempty"`    // 与当前发布状态相反的连续结果次数 (滞回)
+	PendingAt *time.Time `json:"pending_at,omitempty"` // 最近一次计入 Pending 的结果的测试时间
 }
 
 type StreamTagData struct {
-	Available bool   `json:"available"`
-	Region    string `json:"region,omitempty"`
-	Result    string `json:"result,omitempty"` // For Netflix: Full / Originals
-	Premium   bool   `json:"premium,omitempty"` // For Youtube
-	Error     string `json:"error,omitempty"`
+	Available bool       `json:"available"`
+	Region    string     `json:"region,omitempty"`
+	Result    string     `json:"result,omitempty"`  // For Netflix: Full / Originals
+	Premium   bool       `json:"premium,omitempty"` // For Youtube
+	Error     string     `json:"error,omitempty"`
+	TestedAt  time.Time  `json:"tested_at"`            // 增量测试时可能早于 update_time
+	Pending   int        `json:"pending,omitempty"`    // 与当前发布状态相反的连续结果次数 (滞回)
+	PendingAt *time.Time `json:"pending_at,omitempty"` // 最近一次计入 Pending 的结果的测试时间
 }
 
 // SaveJSON 保存原始详细报告 (保留旧功能)
 func SaveJSON(report models.TestReport, outputDir string) error {
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return err
+	return SaveDetailed(report, outputDir, []string{FormatJSON})
+}
+
+// ListJSONReports 返回 outputDir 中的详细报告文件，按时间从旧到新排列
+func ListJSONReports(outputDir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(outputDir, "test_result_*.json"))
+	if err != nil {
+		return nil, err
 	}
-	filename := fmt.Sprintf("test_result_%s.json", time.Now().Format("20060102_150405"))
-	path := filepath.Join(outputDir, filename)
-	data, err := json.MarshalIndent(report, "", "  ")
+	// 文件名中的时间戳格式固定，按字典序即按时间排序
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// LoadJSON 读取一份详细报告
+func LoadJSON(path string) (*models.TestReport, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	var report models.TestReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("invalid report %s: %w", path, err)
+	}
+	return &report, nil
+}
+
+// LoadLatestJSON 读取 outputDir 中最新的详细报告，没有报告时返回 nil
+func LoadLatestJSON(outputDir string) (*models.TestReport, error) {
+	matches, err := ListJSONReports(outputDir)
+	if err != nil || len(matches) == 0 {
+		return nil, err
 	}
-	return os.WriteFile(path, data, 0644)
+	return LoadJSON(matches[len(matches)-1])
 }
 
 // SaveTagMapJSON 保存为 SubStore 易读的 Map 格式
-func SaveTagMapJSON(report models.TestReport, outputPath string) error {
+// 订阅中仍存在但本次未能测试的节点，会沿用上一次 tags.json 中的结果 (见 TagMapOptions)
+func SaveTagMapJSON(report models.TestReport, outputPath string, opts TagMapOptions) error {
 	// 确保父目录存在
 	dir := filepath.Dir(outputPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -54,62 +93,106 @@ func SaveTagMapJSON(report models.TestReport, outputPath string) error {
 	tagMap := make(map[string]NodeTagData)
 
 	for _, result := range report.Results {
-		data := NodeTagData{
-			UpdateTime: time.Now(),
-		}
+		// Key is Node Name
+		tagMap[result.NodeName] = buildTagData(result)
+	}
 
-		// AI Services
-		if t, ok := result.Tests["openai"]; ok {
-			data.OpenAI = &t
-		}
-		if t, ok := result.Tests["gemini"]; ok {
-			data.Gemini = &t
-		}
-		if t, ok := result.Tests["claude"]; ok {
-			data.Claude = &t
+	previousPath := opts.PreviousPath
+	if previousPath == "" {
+		previousPath = outputPath
+	}
+	previous := loadTagMap(previousPath)
+	applyHysteresis(tagMap, previous, opts)
+	carryForward(tagMap, previous, opts, time.Now())
+
+	// 新结果为空或比现有文件少太多时拒绝覆盖，保留上一次的正常结果
+	existing := previous
+	if previousPath != outputPath {
+		existing = loadTagMap(outputPath)
+	}
+	if err := checkShrink(tagMap, existing, opts); err != nil {
+		return err
+	}
+
+	jsonData, err := json.MarshalIndent(tagMap, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if opts.Backup {
+		if err := fsutil.Backup(outputPath); err != nil {
+			return err
 		}
+	}
+
+	return fsutil.WriteFileAtomic(outputPath, jsonData, 0644)
+}
+
+// buildTagData 将单个节点的测试结果转换为 tags.json 条目
+func buildTagData(result models.NodeTestResult) NodeTagData {
+	data := NodeTagData{
+		UpdateTime: result.TestedAt,
+	}
+	if data.UpdateTime.IsZero() {
+		data.UpdateTime = time.Now()
+	}
+
+	// AI Services (耗时分解只保留在详细报告中)
+	if t, ok := result.Tests["openai"]; ok {
+		t.Timing = nil
+		data.OpenAI = &ServiceTagData{ServiceTest: t}
+	}
+	if t, ok := result.Tests["gemini"]; ok {
+		t.Timing = nil
+		data.Gemini = &ServiceTagData{ServiceTest: t}
+	}
+	if t, ok := result.Tests["claude"]; ok {
+		t.Timing = nil
+		data.Claude = &ServiceTagData{ServiceTest: t}
+	}
 
-		// Stream Services
-		if t, ok := result.StreamTests["netflix"]; ok {
-			data.Netflix = &StreamTagData{
-				Available: t.Available,
-				Region:    t.Region,
-				Result:    t.Details, // "Full" or "Originals Only"
-				Error:     t.Error,
-			}
+	// Stream Services
+	if t, ok := result.StreamTests["netflix"]; ok {
+		data.Netflix = &StreamTagData{
+			Available: t.Available,
+			Region:    t.Region,
+			Result:    t.Details, // "Full" or "Originals Only"
+			Error:     t.Error,
+			TestedAt:  t.TestedAt,
 		}
-		if t, ok := result.StreamTests["disney"]; ok {
-			data.Disney = &StreamTagData{
-				Available: t.Available,
-				Region:    t.Region,
-				Error:     t.Error,
-			}
+	}
+	if t, ok := result.StreamTests["disney"]; ok {
+		data.Disney = &StreamTagData{
+			Available: t.Available,
+			Region:    t.Region,
+			Error:     t.Error,
+			TestedAt:  t.TestedAt,
 		}
-		if t, ok := result.StreamTests["max"]; ok {
-			data.Max = &StreamTagData{
-				Available: t.Available,
-				Region:    t.Region,
-				Error:     t.Error,
-			}
+	}
+	if t, ok := result.StreamTests["max"]; ok {
+		data.Max = &StreamTagData{
+			Available: t.Available,
+			Region:    t.Region,
+			Error:     t.Error,
+			TestedAt:  t.TestedAt,
 		}
-		if t, ok := result.StreamTests["youtube"]; ok {
-			isPremium := t.Details == "Premium Available"
-			data.Youtube = &StreamTagData{
-				Available: t.Available,
-				Region:    t.Region,
-				Premium:   isPremium,
-				Error:     t.Error,
-			}
+	}
+	if t, ok := result.StreamTests["youtube"]; ok {
+		isPremium := t.Details == "Premium Available"
+		data.Youtube = &StreamTagData{
+			Available: t.Available,
+			Region:    t.Region,
+			Premium:   isPremium,
+			Error:     t.Error,
+			TestedAt:  t.TestedAt,
 		}
-
-		// Key is Node Name
-		tagMap[result.NodeName] = data
 	}
 
-	jsonData, err := json.MarshalIndent(tagMap, "", "  ")
-	if err != nil {
-		return err
+	// Speed Test (可选)
+	if result.SpeedTest != nil {
+		speed := *result.SpeedTest
+		data.Speed = &speed
 	}
 
-	return os.WriteFile(outputPath, jsonData, 0644)
-}
\ No newline at end of file
+	return data
+}
@@ -0,0 +1,79 @@
+package reporter
+
+import (
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RenderMarkdown 输出精简的 Markdown 摘要：各服务统计 + 可用节点表
+// 节点表只列出至少一个服务可用的节点，便于粘贴到聊天中
+func RenderMarkdown(w io.Writer, report models.TestReport) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "### Clash-tester %s\n\n", report.TestTime.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "Nodes: %d total · %d tested · %d AI available\n\n",
+		report.TotalNodes, report.TestedNodes, report.SuccessNodes)
+	if report.Canary != nil && report.Canary.GlobalOutage {
+		fmt.Fprintf(&b, "> 🌐 Global outage: %s\n\n", mdEscape(report.Canary.Reason))
+	}
+	if report.Incomplete {
+		fmt.Fprintf(&b, "> ⚠️ Incomplete run: %s\n\n", mdEscape(report.IncompleteReason))
+	}
+
+	b.WriteString("| Service | Available | Unavailable | Regions |\n|---|---:|---:|---|\n")
+	for _, col := range htmlColumns {
+		s, ok := report.Summary.Service(col.Key)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, "| %s | %d | %d | %s |\n", col.Title, s.Available, s.Unavailable, mdEscape(strings.Join(s.Countries, ", ")))
+	}
+
+	var nodes []models.NodeTestResult
+	for _, node := range report.Results {
+		if tester.IsNodeUnlocked(node) {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) > 0 {
+		b.WriteString("\n| Node |")
+		for _, col := range htmlColumns {
+			b.WriteString(" " + col.Title + " |")
+		}
+		b.WriteString("\n|---|" + strings.Repeat(":---:|", len(htmlColumns)) + "\n")
+		for _, node := range nodes {
+			fmt.Fprintf(&b, "| %s |", mdEscape(node.NodeName))
+			for _, col := range htmlColumns {
+				b.WriteString(" " + mdCell(node, col.Key) + " |")
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mdCell 单元格：✓ 加地区，✗ 表示不可用，- 表示未测试
+func mdCell(node models.NodeTestResult, service string) string {
+	if t, ok := node.Tests[service]; ok {
+		if !t.Available {
+			return "✗"
+		}
+		return strings.TrimSpace("✓ " + mdEscape(t.Country))
+	}
+	if t, ok := node.StreamTests[service]; ok {
+		if !t.Available {
+			return "✗"
+		}
+		return strings.TrimSpace("✓ " + mdEscape(t.Region))
+	}
+	return "-"
+}
+
+func mdEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
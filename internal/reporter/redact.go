@@ -0,0 +1,26 @@
+package reporter
+
+import (
+	"net/url"
+	"strings"
+)
+
+// RedactSource 隐去订阅地址中的用户信息、路径与参数 (通常包含 token)，只保留协议与主机
+// 本地文件路径原样返回
+func RedactSource(source string) string {
+	u, err := url.Parse(source)
+	if err != nil {
+		if strings.Contains(source, "://") {
+			return "[REDACTED]"
+		}
+		return source
+	}
+	if u.Host == "" {
+		return source
+	}
+	redacted := u.Scheme + "://" + u.Host
+	if u.User != nil || strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "" {
+		redacted += "/[REDACTED]"
+	}
+	return redacted
+}
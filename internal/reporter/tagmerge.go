@@ -0,0 +1,175 @@
+package reporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrShrinkGuard 新的 tags.json 为空或条目大幅减少，拒绝覆盖现有文件
+var ErrShrinkGuard = errors.New("refusing to overwrite tags.json with a drastically smaller result")
+
+// TagMapOptions 生成 tags.json 时与上一次结果合并的选项
+type TagMapOptions struct {
+	PreviousPath string        // 上一次的 tags.json，为空时读取输出路径本身
+	PresentNodes []string      // 当前订阅中的全部节点名，只有仍在订阅中的节点才会沿用旧结果
+	MaxAge       time.Duration // 旧结果最多沿用多久 (按原始测试时间计算)，0 表示不过期
+
+	// 滞回：服务状态需要连续 N 次一致的结果才会翻转，避免偶发超时导致标签抖动
+	// <= 1 表示每次直接采用最新结果；最新的原始结果始终保留在详细报告中
+	UpThreshold   int // 由不可用变为可用所需的连续可用次数
+	DownThreshold int // 由可用变为不可用所需的连续不可用次数
+
+	Backup       bool    // 覆盖前将现有文件保存为 .bak
+	MinKeepRatio float64 // 新结果条目数低于现有文件中仍在订阅内的条目数的该比例时拒绝覆盖，0 表示只拒绝空结果
+	Force        bool    // 跳过缩水保护，用于确认订阅确实大幅缩减后
+}
+
+// loadTagMap 读取上一次的 tags.json，不存在或无法解析时返回空 Map
+func loadTagMap(path string) map[string]NodeTagData {
+	tagMap := make(map[string]NodeTagData)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return tagMap
+	}
+	// 旧文件损坏时放弃沿用，不影响本次结果的写入
+	if err := json.Unmarshal(data, &tagMap); err != nil {
+		return make(map[string]NodeTagData)
+	}
+	return tagMap
+}
+
+// checkShrink 检查新结果是否为空或相比现有文件大幅缩水
+// 只与现有文件中仍在订阅内的条目比较，订阅本身缩减 (节点被移除) 不会触发保护
+func checkShrink(tagMap, existing map[string]NodeTagData, opts TagMapOptions) error {
+	if opts.Force {
+		return nil
+	}
+	oldCount := len(existing)
+	if opts.PresentNodes != nil {
+		oldCount = 0
+		for _, name := range opts.PresentNodes {
+			if _, ok := existing[name]; ok {
+				oldCount++
+			}
+		}
+	}
+	if oldCount == 0 {
+		return nil
+	}
+	newCount := len(tagMap)
+	if newCount == 0 || float64(newCount) < float64(oldCount)*opts.MinKeepRatio {
+		return fmt.Errorf("%w: %d entries, existing file has %d for nodes still in the subscription (use -map-force to overwrite anyway)",
+			ErrShrinkGuard, newCount, oldCount)
+	}
+	return nil
+}
+
+// carryForward 为本次未能测试的节点沿用上一次的条目
+// 沿用的条目标记为 stale 并保留原始测试时间，超过 MaxAge 后不再沿用
+func carryForward(tagMap, previous map[string]NodeTagData, opts TagMapOptions, now time.Time) {
+	for _, name := range opts.PresentNodes {
+		if _, tested := tagMap[name]; tested {
+			continue
+		}
+		prev, ok := previous[name]
+		if !ok {
+			continue
+		}
+		if opts.MaxAge > 0 && now.Sub(prev.UpdateTime) > opts.MaxAge {
+			continue
+		}
+		prev.Stale = true
+		tagMap[name] = prev
+	}
+}
+
+// applyHysteresis 根据上一次发布的状态对本次结果做滞回处理
+// 最新结果与已发布状态不一致且未达到阈值时，继续发布旧条目并累加 pending 计数
+func applyHysteresis(tagMap, previous map[string]NodeTagData, opts TagMapOptions) {
+	if opts.UpThreshold <= 1 && opts.DownThreshold <= 1 {
+		return
+	}
+
+	for name, data := range tagMap {
+		prev, ok := previous[name]
+		if !ok {
+			continue
+		}
+
+		data.OpenAI = holdService(data.OpenAI, prev.OpenAI, opts)
+		data.Gemini = holdService(data.Gemini, prev.Gemini, opts)
+		data.Claude = holdService(data.Claude, prev.Claude, opts)
+		data.Netflix = holdStream(data.Netflix, prev.Netflix, opts)
+		data.Disney = holdStream(data.Disney, prev.Disney, opts)
+		data.Youtube = holdStream(data.Youtube, prev.Youtube, opts)
+		data.Max = holdStream(data.Max, prev.Max, opts)
+
+		tagMap[name] = data
+	}
+}
+
+func holdService(cur, prev *ServiceTagData, opts TagMapOptions) *ServiceTagData {
+	if cur == nil || prev == nil {
+		return cur
+	}
+	keep, pending, pendingAt := hysteresis(
+		sample{prev.Available, prev.TestedAt}, prev.Pending, prev.PendingAt,
+		sample{cur.Available, cur.TestedAt}, opts)
+	if keep {
+		held := *prev
+		held.Pending, held.PendingAt = pending, pendingAt
+		return &held
+	}
+	return cur
+}
+
+func holdStream(cur, prev *StreamTagData, opts TagMapOptions) *StreamTagData {
+	if cur == nil || prev == nil {
+		return cur
+	}
+	keep, pending, pendingAt := hysteresis(
+		sample{prev.Available, prev.TestedAt}, prev.Pending, prev.PendingAt,
+		sample{cur.Available, cur.TestedAt}, opts)
+	if keep {
+		held := *prev
+		held.Pending, held.PendingAt = pending, pendingAt
+		return &held
+	}
+	return cur
+}
+
+// sample 一次检测结果的可用性与测试时间
+type sample struct {
+	available bool
+	testedAt  time.Time
+}
+
+// hysteresis 判断是否继续保持已发布的状态
+// published 为上一次发布的结果，pending/pendingAt 为累计的相反结果次数及其中最近一次的测试时间，latest 为本次结果
+// 增量测试沿用的缓存结果 (测试时间不晚于已计入的结果) 不是新的检测，不累加计数
+func hysteresis(published sample, pending int, pendingAt *time.Time, latest sample, opts TagMapOptions) (bool, int, *time.Time) {
+	if latest.available == published.available {
+		return false, 0, nil
+	}
+
+	last := published.testedAt
+	if pendingAt != nil && pendingAt.After(last) {
+		last = *pendingAt
+	}
+	if !latest.testedAt.IsZero() && !latest.testedAt.After(last) {
+		return true, pending, pendingAt
+	}
+
+	need := opts.DownThreshold
+	if latest.available {
+		need = opts.UpThreshold
+	}
+	if pending+1 >= need {
+		return false, 0, nil
+	}
+	at := latest.testedAt
+	return true, pending + 1, &at
+}
@@ -0,0 +1,182 @@
+package reporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"Clash-tester/pkg/models"
+)
+
+func TestApplyHysteresis(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return t0.Add(time.Duration(minutes) * time.Minute) }
+
+	type run struct {
+		available bool
+		testedAt  time.Time
+		want      bool // 本次发布的状态
+	}
+	tests := []struct {
+		name     string
+		up, down int
+		initial  bool
+		runs     []run
+	}{
+		{
+			name: "single failure is suppressed", up: 2, down: 2, initial: true,
+			runs: []run{{false, at(1), true}, {true, at(2), true}, {false, at(3), true}, {true, at(4), true}},
+		},
+		{
+			name: "consecutive failures flip", up: 2, down: 2, initial: true,
+			runs: []run{{false, at(1), true}, {false, at(2), false}, {false, at(3), false}},
+		},
+		{
+			name: "recovery needs up threshold", up: 3, down: 2, initial: false,
+			runs: []run{{true, at(1), false}, {true, at(2), false}, {true, at(3), true}},
+		},
+		{
+			name: "cached result is not a new sample", up: 2, down: 2, initial: true,
+			runs: []run{{false, at(1), true}, {false, at(1), true}, {false, at(1), true}, {false, at(2), false}},
+		},
+		{
+			name: "cached result older than published", up: 2, down: 2, initial: true,
+			runs: []run{{false, at(-1), true}, {false, at(-1), true}},
+		},
+		{
+			name: "unknown test time counts", up: 2, down: 2, initial: true,
+			runs: []run{{false, time.Time{}, true}, {false, time.Time{}, false}},
+		},
+		{
+			name: "threshold 1 publishes latest", up: 1, down: 1, initial: true,
+			runs: []run{{false, at(1), false}, {true, at(1), true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := TagMapOptions{UpThreshold: tt.up, DownThreshold: tt.down}
+			previous := map[string]NodeTagData{"node": tagEntry(tt.initial, t0)}
+			for i, r := range tt.runs {
+				tagMap := map[string]NodeTagData{"node": tagEntry(r.available, r.testedAt)}
+				applyHysteresis(tagMap, previous, opts)
+
+				got := tagMap["node"]
+				if got.OpenAI.Available != r.want || got.Netflix.Available != r.want {
+					t.Fatalf("run %d: published openai=%v netflix=%v, want %v (pending %d)",
+						i+1, got.OpenAI.Available, got.Netflix.Available, r.want, got.OpenAI.Pending)
+				}
+				previous = roundTrip(t, tagMap)
+			}
+		})
+	}
+}
+
+// tagEntry 只含 OpenAI 与 Netflix 的条目
+func tagEntry(available bool, testedAt time.Time) NodeTagData {
+	return NodeTagData{
+		UpdateTime: testedAt,
+		OpenAI:     &ServiceTagData{ServiceTest: models.ServiceTest{Service: "openai", Available: available, TestedAt: testedAt}},
+		Netflix:    &StreamTagData{Available: available, TestedAt: testedAt},
+	}
+}
+
+// roundTrip 模拟写入并重新读取 tags.json
+func roundTrip(t *testing.T, tagMap map[string]NodeTagData) map[string]NodeTagData {
+	t.Helper()
+	data, err := json.Marshal(tagMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := make(map[string]NodeTagData)
+	if err := json.Unmarshal(data, &previous); err != nil {
+		t.Fatal(err)
+	}
+	return previous
+}
+
+func TestSaveTagMapJSONShrinkGuard(t *testing.T) {
+	names := func(prefix string, n int) []string {
+		var list []string
+		for i := 1; i <= n; i++ {
+			list = append(list, fmt.Sprintf("%s%02d", prefix, i))
+		}
+		return list
+	}
+	report := func(nodes []string, testedAt time.Time) models.TestReport {
+		var r models.TestReport
+		for _, name := range nodes {
+			r.Results = append(r.Results, models.NodeTestResult{NodeName: name, TestedAt: testedAt})
+		}
+		return r
+	}
+
+	tests := []struct {
+		name    string
+		tested  []string
+		present []string
+		force   bool
+		refused bool
+	}{
+		{name: "most nodes missing", tested: names("hk", 3), present: names("hk", 10), refused: true},
+		{name: "empty result", tested: nil, present: names("hk", 10), refused: true},
+		{name: "within ratio", tested: names("hk", 6), present: names("hk", 10)},
+		{name: "subscription shrank", tested: names("hk", 3), present: names("hk", 3)},
+		{name: "subscription replaced", tested: names("us", 2), present: names("us", 2)},
+		{name: "forced", tested: names("hk", 3), present: names("hk", 10), force: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 旧条目已超过 MaxAge，未测试的节点不会沿用旧结果
+			path := filepath.Join(t.TempDir(), "tags.json")
+			opts := TagMapOptions{MinKeepRatio: 0.5, MaxAge: time.Hour}
+			if err := SaveTagMapJSON(report(names("hk", 10), time.Now().Add(-2*time.Hour)), path, opts); err != nil {
+				t.Fatal(err)
+			}
+
+			opts.PresentNodes = tt.present
+			opts.Force = tt.force
+			err := SaveTagMapJSON(report(tt.tested, time.Now()), path, opts)
+			if refused := errors.Is(err, ErrShrinkGuard); refused != tt.refused {
+				t.Fatalf("err = %v, want refused %v", err, tt.refused)
+			}
+			if err != nil && !tt.refused {
+				t.Fatal(err)
+			}
+
+			want := 10
+			if !tt.refused {
+				want = len(tt.tested)
+			}
+			if got := len(loadTagMap(path)); got != want {
+				t.Errorf("tags.json has %d entries, want %d", got, want)
+			}
+		})
+	}
+}
+
+// TestSaveTagMapJSONAfterShrink 订阅缩减后的下一次运行不再被拒绝
+func TestSaveTagMapJSONAfterShrink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tags.json")
+	all := []string{"a", "b", "c", "d", "e", "f"}
+	opts := TagMapOptions{MinKeepRatio: 0.5, PresentNodes: all}
+	var r models.TestReport
+	for _, name := range all {
+		r.Results = append(r.Results, models.NodeTestResult{NodeName: name, TestedAt: time.Now()})
+	}
+	if err := SaveTagMapJSON(r, path, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.PresentNodes = all[:2]
+	r.Results = r.Results[:2]
+	for run := 1; run <= 2; run++ {
+		if err := SaveTagMapJSON(r, path, opts); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+	}
+}
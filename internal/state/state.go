@@ -0,0 +1,176 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"Clash-tester/internal/fsutil"
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+)
+
+// State 增量测试状态，保存每个节点最近一次的各项检测结果
+type State struct {
+	UpdatedAt time.Time                        `json:"updated_at"`
+	Nodes     map[string]models.NodeTestResult `json:"nodes"` // key: 节点指纹
+}
+
+// TTLConfig 各服务检测结果的有效期
+type TTLConfig struct {
+	Default     time.Duration            // 未单独配置的服务使用的有效期，0 表示每次都重新检测
+	Services    map[string]time.Duration // key: openai/netflix/...
+	Unavailable time.Duration            // 不可用结果的有效期 (不超过该服务的有效期)，0 表示每次都重新检测
+}
+
+// For 返回某项服务的有效期
+func (c TTLConfig) For(service string) time.Duration {
+	if ttl, ok := c.Services[service]; ok {
+		return ttl
+	}
+	return c.Default
+}
+
+// forResult 返回某项服务一次检测结果的有效期，不可用的结果使用较短的 Unavailable
+func (c TTLConfig) forResult(service string, available bool) time.Duration {
+	ttl := c.For(service)
+	if !available && c.Unavailable < ttl {
+		return c.Unavailable
+	}
+	return ttl
+}
+
+// ParseTTLs 解析形如 "netflix=24h,openai=1h" 的有效期配置
+func ParseTTLs(spec string) (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid ttl %q, expected service=duration", item)
+		}
+		ttl, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ttl for %s: %w", name, err)
+		}
+		ttls[strings.TrimSpace(name)] = ttl
+	}
+	return ttls, nil
+}
+
+// Load 读取状态文件，文件不存在时返回空状态
+func Load(path string) (*State, error) {
+	s := &State{Nodes: make(map[string]models.NodeTestResult)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Nodes == nil {
+		s.Nodes = make(map[string]models.NodeTestResult)
+	}
+	return s, nil
+}
+
+// Save 写入状态文件
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, data, 0644)
+}
+
+// Update 用本次运行的结果更新状态，并移除已不在订阅中的节点
+func (s *State) Update(results []models.NodeTestResult, nodes []models.ProxyNode) {
+	present := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		present[node.Fingerprint()] = true
+	}
+
+	for fp := range s.Nodes {
+		if !present[fp] {
+			delete(s.Nodes, fp)
+		}
+	}
+	for _, result := range results {
+		if result.Fingerprint != "" {
+			s.Nodes[result.Fingerprint] = result
+		}
+	}
+	s.UpdatedAt = time.Now()
+}
+
+// Plan 计算节点本次需要重新检测的服务，只考虑 enabled 中启用的检测项 (为空表示全部启用)
+// 返回 nil 表示全部检测；返回空切片表示所有结果都仍在有效期内
+// 以下情况需要全部重新检测：新节点或配置有变化 (指纹不匹配)、上次没有任何检测通过
+// 上次不可用的服务使用单独的 (较短的) 有效期，避免偶发失败一直沿用到过期
+func (s *State) Plan(node models.ProxyNode, enabled []string, ttls TTLConfig, now time.Time) ([]string, *models.NodeTestResult) {
+	prev, ok := s.Nodes[node.Fingerprint()]
+	if !ok || prev.Error != "" || !tester.IsNodeUnlocked(prev) {
+		return nil, nil
+	}
+
+	services := []string{}
+	for _, name := range tester.AIServices {
+		if len(enabled) > 0 && !slices.Contains(enabled, name) {
+			continue
+		}
+		test, ok := prev.Tests[name]
+		if !ok || isStale(test.TestedAt, ttls.forResult(name, test.Available), now) {
+			services = append(services, name)
+		}
+	}
+	for _, name := range tester.StreamServices {
+		if len(enabled) > 0 && !slices.Contains(enabled, name) {
+			continue
+		}
+		test, ok := prev.StreamTests[name]
+		if !ok || isStale(test.TestedAt, ttls.forResult(name, test.Available), now) {
+			services = append(services, name)
+		}
+	}
+
+	return services, &prev
+}
+
+func isStale(testedAt time.Time, ttl time.Duration, now time.Time) bool {
+	return testedAt.IsZero() || now.Sub(testedAt) >= ttl
+}
+
+// Merge 把上次仍在有效期内的检测结果合并进本次 (只检测了部分服务的) 结果
+// 合并进来的结果保留各自原来的检测时间
+func Merge(fresh models.NodeTestResult, prev *models.NodeTestResult) models.NodeTestResult {
+	if prev == nil {
+		return fresh
+	}
+
+	for name, test := range prev.Tests {
+		if _, ok := fresh.Tests[name]; !ok {
+			fresh.Tests[name] = test
+		}
+	}
+	for name, test := range prev.StreamTests {
+		if _, ok := fresh.StreamTests[name]; !ok {
+			fresh.StreamTests[name] = test
+		}
+	}
+	if fresh.SpeedTest == nil {
+		fresh.SpeedTest = prev.SpeedTest
+	}
+
+	return fresh
+}
@@ -0,0 +1,165 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"Clash-tester/pkg/models"
+)
+
+func TestPlan(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	node := models.ProxyNode{Name: "HK 01", Type: "ss", Server: "hk.example", Port: 443}
+	ttls := TTLConfig{Default: time.Hour, Services: map[string]time.Duration{"netflix": 24 * time.Hour}, Unavailable: 15 * time.Minute}
+
+	// fresh 各服务都在 ago 之前检测且可用的结果
+	fresh := func(ago time.Duration) models.NodeTestResult {
+		result := models.NodeTestResult{
+			NodeName:    node.Name,
+			Fingerprint: node.Fingerprint(),
+			Tests:       map[string]models.ServiceTest{},
+			StreamTests: map[string]models.StreamTest{},
+		}
+		for _, name := range []string{"openai", "gemini", "claude"} {
+			result.Tests[name] = models.ServiceTest{Service: name, Available: true, TestedAt: now.Add(-ago)}
+		}
+		for _, name := range []string{"netflix", "disney", "youtube", "max"} {
+			result.StreamTests[name] = models.StreamTest{Service: name, Available: true, TestedAt: now.Add(-ago)}
+		}
+		return result
+	}
+
+	tests := []struct {
+		name    string
+		prev    *models.NodeTestResult
+		enabled []string // 启用的检测项，nil 表示全部
+		want    []string // nil 表示全部检测
+		reuse   bool     // 是否返回上次的结果
+	}{
+		{name: "new node", prev: nil, want: nil},
+		{name: "all fresh", prev: ptr(fresh(10 * time.Minute)), want: []string{}, reuse: true},
+		{
+			name:  "default ttl expired",
+			prev:  ptr(fresh(2 * time.Hour)),
+			want:  []string{"openai", "gemini", "claude", "disney", "youtube", "max"},
+			reuse: true,
+		},
+		{
+			name:    "only enabled services",
+			prev:    ptr(fresh(2 * time.Hour)),
+			enabled: []string{"openai", "netflix"},
+			want:    []string{"openai"},
+			reuse:   true,
+		},
+		{
+			name: "failed service within unavailable ttl",
+			prev: ptr(with(fresh(10*time.Minute), func(r *models.NodeTestResult) {
+				r.Tests["claude"] = models.ServiceTest{Service: "claude", Error: "timeout", TestedAt: now.Add(-10 * time.Minute)}
+				r.StreamTests["netflix"] = models.StreamTest{Service: "netflix", Error: "blocked", TestedAt: now.Add(-10 * time.Minute)}
+			})),
+			want:  []string{},
+			reuse: true,
+		},
+		{
+			name: "failed service past unavailable ttl",
+			prev: ptr(with(fresh(10*time.Minute), func(r *models.NodeTestResult) {
+				r.Tests["claude"] = models.ServiceTest{Service: "claude", Error: "timeout", TestedAt: now.Add(-20 * time.Minute)}
+				r.StreamTests["netflix"] = models.StreamTest{Service: "netflix", Error: "blocked", TestedAt: now.Add(-20 * time.Minute)}
+			})),
+			want:  []string{"claude", "netflix"},
+			reuse: true,
+		},
+		{
+			name: "missing service",
+			prev: ptr(with(fresh(10*time.Minute), func(r *models.NodeTestResult) {
+				delete(r.StreamTests, "max")
+			})),
+			want:  []string{"max"},
+			reuse: true,
+		},
+		{
+			name: "zero test time",
+			prev: ptr(with(fresh(10*time.Minute), func(r *models.NodeTestResult) {
+				r.Tests["openai"] = models.ServiceTest{Service: "openai", Available: true}
+			})),
+			want:  []string{"openai"},
+			reuse: true,
+		},
+		{
+			name: "node error",
+			prev: ptr(with(fresh(10*time.Minute), func(r *models.NodeTestResult) {
+				r.Error = "switch failed"
+			})),
+			want: nil,
+		},
+		{
+			name: "nothing unlocked",
+			prev: ptr(with(fresh(10*time.Minute), func(r *models.NodeTestResult) {
+				for name, test := range r.Tests {
+					test.Available = false
+					r.Tests[name] = test
+				}
+				for name, test := range r.StreamTests {
+					test.Available = false
+					r.StreamTests[name] = test
+				}
+			})),
+			want: nil,
+		},
+		{
+			name: "config changed",
+			prev: ptr(with(fresh(10*time.Minute), func(r *models.NodeTestResult) {
+				r.Fingerprint = "other"
+			})),
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &State{Nodes: map[string]models.NodeTestResult{}}
+			if tt.prev != nil {
+				s.Nodes[tt.prev.Fingerprint] = *tt.prev
+			}
+
+			got, prev := s.Plan(node, tt.enabled, ttls, now)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("services = %#v, want %#v", got, tt.want)
+			}
+			if (prev != nil) != tt.reuse {
+				t.Errorf("previous result returned = %v, want %v", prev != nil, tt.reuse)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	old := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	prev := &models.NodeTestResult{
+		Tests:       map[string]models.ServiceTest{"openai": {Available: true, TestedAt: old}, "claude": {Available: true, TestedAt: old}},
+		StreamTests: map[string]models.StreamTest{"netflix": {Available: true, TestedAt: old}},
+	}
+	fresh := models.NodeTestResult{
+		Tests:       map[string]models.ServiceTest{"claude": {Available: false, TestedAt: old.Add(time.Hour)}},
+		StreamTests: map[string]models.StreamTest{},
+	}
+
+	merged := Merge(fresh, prev)
+	if !merged.Tests["openai"].TestedAt.Equal(old) {
+		t.Errorf("openai not carried over with its test time: %+v", merged.Tests["openai"])
+	}
+	if merged.Tests["claude"].Available {
+		t.Error("fresh claude result replaced by the previous one")
+	}
+	if _, ok := merged.StreamTests["netflix"]; !ok {
+		t.Error("netflix not carried over")
+	}
+}
+
+func ptr(r models.NodeTestResult) *models.NodeTestResult { return &r }
+
+func with(r models.NodeTestResult, change func(*models.NodeTestResult)) models.NodeTestResult {
+	change(&r)
+	return r
+}
@@ -0,0 +1,137 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"Clash-tester/pkg/models"
+)
+
+// CanaryConfig 自检配置：在测试前后检查本机网络与参考代理，区分本机故障与节点故障
+type CanaryConfig struct {
+	Enabled        bool
+	ExtraURL       string     // 额外的直连探测地址 (如国内可直连的网站)
+	ReferenceProxy string     // 已知可用的参考代理 (http:// 或 socks5://)，为空表示不检查
+	Endpoints      *Endpoints // 探测 Endpoints.Canary 中的地址，nil 表示 DefaultEndpoints
+}
+
+// RunCanary 直连 (以及通过参考代理) 探测各目标服务
+// 所有直连探测都失败，或配置了参考代理而参考代理的探测全部失败时，判定为全局故障
+func RunCanary(ctx context.Context, cfg CanaryConfig) models.CanaryResult {
+	result := models.CanaryResult{Time: time.Now()}
+	targets := DefaultEndpoints.Canary
+	if cfg.Endpoints != nil {
+		targets = cfg.Endpoints.Canary
+	}
+
+	direct := createDirectClient()
+	if cfg.ExtraURL != "" {
+		result.Direct = append(result.Direct, probe(ctx, direct, "canary", cfg.ExtraURL))
+	}
+	result.Direct = append(result.Direct, probeTargets(ctx, direct, targets)...)
+
+	if cfg.ReferenceProxy != "" {
+		result.Reference = probeTargets(ctx, createProxyClient(cfg.ReferenceProxy, TestTimeout, nil), targets)
+	}
+
+	switch {
+	case !anyReachable(result.Direct):
+		result.Outage = true
+		result.Reason = "no target reachable via direct connection, the tester host appears to be offline"
+	case cfg.ReferenceProxy != "" && !anyReachable(result.Reference):
+		result.Outage = true
+		result.Reason = "no target reachable via the reference proxy"
+	}
+
+	return result
+}
+
+// probeTargets 并发探测全部目标服务
+func probeTargets(ctx context.Context, client *http.Client, targets []CanaryTarget) []models.CanaryCheck {
+	checks := make([]models.CanaryCheck, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, name, targetURL string) {
+			defer wg.Done()
+			checks[i] = probe(ctx, client, name, targetURL)
+		}(i, target.Name, target.URL)
+	}
+	wg.Wait()
+	return checks
+}
+
+// probe 访问一次目标地址，收到任意 HTTP 响应 (包括 403 等) 即视为可达
+func probe(ctx context.Context, client *http.Client, name, targetURL string) models.CanaryCheck {
+	check := models.CanaryCheck{Name: name, URL: targetURL}
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	check.ResponseTime = int(time.Since(start).Milliseconds())
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	resp.Body.Close()
+
+	check.StatusCode = resp.StatusCode
+	check.Reachable = true
+	return check
+}
+
+func anyReachable(checks []models.CanaryCheck) bool {
+	for _, c := range checks {
+		if c.Reachable {
+			return true
+		}
+	}
+	return false
+}
+
+// createDirectClient 创建不经过任何代理的客户端 (忽略环境变量中的代理设置)
+func createDirectClient() *http.Client {
+	return &http.Client{
+		Timeout: TestTimeout,
+		Transport: &tracingTransport{
+			base: &http.Transport{
+				Proxy:           func(*http.Request) (*url.URL, error) { return nil, nil },
+				IdleConnTimeout: 30 * time.Second,
+			},
+		},
+		// 不跟随跳转，只关心目标是否可达
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// DetectGlobalOutage 结合测试前后的自检结果判断本次失败是否由全局故障导致
+// 只有在没有任何节点可用时才会判定，避免把个别节点的问题归咎于本机
+func DetectGlobalOutage(report models.TestReport) (bool, string) {
+	if report.Canary == nil || IsAnyNodeUnlocked(report.Results) {
+		return false, ""
+	}
+	for _, c := range []*models.CanaryResult{report.Canary.Before, report.Canary.After} {
+		if c != nil && c.Outage {
+			return true, fmt.Sprintf("%s (canary at %s)", c.Reason, c.Time.Format("15:04:05"))
+		}
+	}
+	return false, ""
+}
+
+// IsAnyNodeUnlocked 是否至少有一个节点通过了任意解锁检测
+func IsAnyNodeUnlocked(results []models.NodeTestResult) bool {
+	for _, r := range results {
+		if IsNodeUnlocked(r) {
+			return true
+		}
+	}
+	return false
+}
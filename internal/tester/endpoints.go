@@ -0,0 +1,64 @@
+package tester
+
+import "context"
+
+// Endpoints 各项检测访问的地址
+// 测试时可替换为本地的替身服务，见 Options.Endpoints
+type Endpoints struct {
+	OpenAI           string // cdn-cgi/trace，返回 loc=XX
+	Gemini           string
+	Claude           string
+	IPInfo           string // 出口 IP 所在国家，返回 {"countryCode":"XX"}
+	NetflixFull      string // 非自制剧 (Breaking Bad)
+	NetflixOriginals string // 自制剧 (Squid Game)
+	Disney           string
+	YouTube          string
+	Max              string
+	Canary           []CanaryTarget // 自检的直连探测地址，见 RunCanary
+}
+
+// CanaryTarget 自检探测的一个目标服务，只判断能否建立连接并收到响应
+type CanaryTarget struct {
+	Name string
+	URL  string
+}
+
+// DefaultEndpoints 真实服务的地址
+var DefaultEndpoints = Endpoints{
+	OpenAI:           "https://chatgpt.com/cdn-cgi/trace",
+	Gemini:           "https://gemini.google.com/app",
+	Claude:           "https://claude.ai/login",
+	IPInfo:           "http://ip-api.com/json/?fields=countryCode",
+	NetflixFull:      "https://www.netflix.com/title/70143836",
+	NetflixOriginals: "https://www.netflix.com/title/81243996",
+	Disney:           "https://www.disneyplus.com/",
+	YouTube:          "https://www.youtube.com/",
+	Max:              "https://www.max.com/",
+	Canary: []CanaryTarget{
+		{"openai", "https://chatgpt.com/cdn-cgi/trace"},
+		{"gemini", "https://gemini.google.com/"},
+		{"claude", "https://claude.ai/"},
+		{"netflix", "https://www.netflix.com/"},
+		{"disney", "https://www.disneyplus.com/"},
+		{"youtube", "https://www.youtube.com/"},
+		{"max", "https://www.max.com/"},
+	},
+}
+
+type endpointsKey struct{}
+
+// withEndpoints 将检测地址挂到 ctx 上，nil 表示使用默认地址
+func withEndpoints(ctx context.Context, e *Endpoints) context.Context {
+	if e == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, endpointsKey{}, e)
+}
+
+// endpoints 取出本次检测使用的地址
+func endpoints(ctx context.Context) *Endpoints {
+	if e, ok := ctx.Value(endpointsKey{}).(*Endpoints); ok {
+		return e
+	}
+	return &DefaultEndpoints
+}
@@ -0,0 +1,48 @@
+package tester
+
+import "strings"
+
+// 检测错误分类，用于统计与监控
+const (
+	ErrorBlocked    = "blocked"     // 服务明确拒绝 (地区不支持、403 等)
+	ErrorTimeout    = "timeout"     // 连接或读取超时
+	ErrorDNS        = "dns"         // 域名解析失败
+	ErrorRefused    = "refused"     // 连接被拒绝
+	ErrorReset      = "reset"       // 连接被重置或提前关闭
+	ErrorTLS        = "tls"         // TLS 握手或证书错误
+	ErrorProxy      = "proxy"       // 本地代理 (核心) 连接失败或无法连接节点
+	ErrorHTTPStatus = "http_status" // 非预期的 HTTP 状态码
+	ErrorCanceled   = "canceled"    // 运行被中止
+	ErrorOther      = "other"
+)
+
+// ErrorCategory 根据错误信息判断错误类别，空字符串表示没有错误
+func ErrorCategory(msg string) string {
+	if msg == "" {
+		return ""
+	}
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "context canceled"):
+		return ErrorCanceled
+	case strings.Contains(lower, "timeout"), strings.Contains(lower, "deadline exceeded"):
+		return ErrorTimeout
+	case strings.Contains(lower, "no such host"), strings.Contains(lower, "server misbehaving"):
+		return ErrorDNS
+	case strings.Contains(lower, "proxyconnect"), strings.Contains(lower, "bad gateway"):
+		return ErrorProxy
+	case strings.Contains(lower, "connection refused"):
+		return ErrorRefused
+	case strings.Contains(lower, "connection reset"), strings.Contains(lower, "eof"):
+		return ErrorReset
+	case strings.Contains(lower, "tls"), strings.Contains(lower, "x509"), strings.Contains(lower, "certificate"):
+		return ErrorTLS
+	case strings.Contains(lower, "blocked"), strings.Contains(lower, "not supported"),
+		strings.Contains(lower, "unsupported"), strings.Contains(lower, "forbidden"),
+		strings.Contains(lower, "preview/unavailable"):
+		return ErrorBlocked
+	case strings.Contains(lower, "status"):
+		return ErrorHTTPStatus
+	}
+	return ErrorOther
+}
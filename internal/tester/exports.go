@@ -2,38 +2,27 @@ package tester
 
 import (
 	"Clash-tester/pkg/models"
+	"context"
 	"net/http"
-	"net/url"
-	"time"
 )
 
 // Export helper functions for server package
 func CreateProxyClient(proxyURL string) *http.Client {
-	proxyURLParsed, _ := url.Parse(proxyURL)
-
-	return &http.Client{
-		Timeout: TestTimeout,
-		Transport: &http.Transport{
-			Proxy:              http.ProxyURL(proxyURLParsed),
-			MaxIdleConns:       10,
-			IdleConnTimeout:    30 * time.Second,
-			DisableCompression: false,
-		},
-	}
+	return createProxyClient(proxyURL, TestTimeout, nil)
 }
 
-func TestServiceWithRetry(client *http.Client, serviceName string, fn testFunc) models.ServiceTest {
-	return testServiceWithRetry(client, serviceName, fn)
+func TestServiceWithRetry(ctx context.Context, client *http.Client, serviceName string, fn testFunc) models.ServiceTest {
+	return testServiceWithRetry(ctx, client, serviceName, fn, MaxRetries)
 }
 
-func TestOpenAI(client *http.Client, result *models.ServiceTest) error {
-	return testOpenAI(client, result)
+func TestOpenAI(ctx context.Context, client *http.Client, result *models.ServiceTest) error {
+	return testOpenAI(ctx, client, result)
 }
 
-func TestGemini(client *http.Client, result *models.ServiceTest) error {
-	return testGemini(client, result)
+func TestGemini(ctx context.Context, client *http.Client, result *models.ServiceTest) error {
+	return testGemini(ctx, client, result)
 }
 
-func TestClaude(client *http.Client, result *models.ServiceTest) error {
-	return testClaude(client, result)
+func TestClaude(ctx context.Context, client *http.Client, result *models.ServiceTest) error {
+	return testClaude(ctx, client, result)
 }
@@ -0,0 +1,267 @@
+package tester
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fixture 的来源
+const (
+	FixtureCaptured  = "captured"  // 由 fixture 命令从真实检测的记录转换
+	FixtureSynthetic = "synthetic" // 手写的样例，只覆盖检测规则本身，不能反映站点改版
+)
+
+// Fixture 一次检测的响应，回放给检测逻辑，用于发现站点改版导致的误判
+// 以 JSON 保存，语料库见 testdata/fixtures/<服务>/
+type Fixture struct {
+	Service     string            `json:"service"`
+	Source      string            `json:"source"` // FixtureCaptured 或 FixtureSynthetic
+	Description string            `json:"description,omitempty"`
+	Captured    string            `json:"captured,omitempty"` // 录制时间 (RFC 3339)，录制的 fixture 必须填写
+	Expect      FixtureResult     `json:"expect"`
+	Exchanges   []FixtureExchange `json:"exchanges"` // 按请求回放；同一 URL 多次出现时依次返回
+
+	dir string // fixture 文件所在目录，用于读取 BodyFile
+}
+
+// FixtureResult 检测结果中与判定相关的部分
+// 作为期望值时，Details / Region / Error 为空表示不检查
+type FixtureResult struct {
+	Available bool   `json:"available"`
+	Details   string `json:"details,omitempty"`
+	Region    string `json:"region,omitempty"` // 流媒体的 Region 或 AI 服务的 Country
+	Error     string `json:"error,omitempty"`
+}
+
+// FixtureExchange 一次请求的录制响应，重定向链中的每一跳各一条
+type FixtureExchange struct {
+	Method   string            `json:"method,omitempty"` // 默认 GET
+	URL      string            `json:"url"`
+	Status   int               `json:"status,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     string            `json:"body,omitempty"`
+	BodyFile string            `json:"body_file,omitempty"` // 正文较大时保存在同目录下的文件中
+	Error    string            `json:"error,omitempty"`     // 请求失败 (如连接被重置)，此时没有响应
+}
+
+// LoadFixture 读取 fixture 文件
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if !IsService(f.Service) {
+		return nil, fmt.Errorf("%s: unknown service %q", path, f.Service)
+	}
+	switch f.Source {
+	case FixtureCaptured:
+		if _, err := time.Parse(time.RFC3339, f.Captured); err != nil {
+			return nil, fmt.Errorf("%s: captured fixture needs an RFC 3339 capture time: %w", path, err)
+		}
+	case FixtureSynthetic:
+	default:
+		return nil, fmt.Errorf("%s: source must be %q or %q", path, FixtureCaptured, FixtureSynthetic)
+	}
+	f.dir = filepath.Dir(path)
+	return &f, nil
+}
+
+// Replay 用录制的响应执行一次检测 (不重试、不访问网络)，返回判定结果
+// 检测访问了未录制的地址时，该请求失败
+func (f *Fixture) Replay(ctx context.Context) FixtureResult {
+	client := &http.Client{Transport: &replayTransport{fixture: f}, Timeout: TestTimeout}
+
+	if fn, ok := aiTestFuncs[f.Service]; ok {
+		test := testServiceWithRetry(ctx, client, f.Service, fn, 0)
+		return FixtureResult{Available: test.Available, Region: test.Country, Error: test.Error}
+	}
+	test := TestStreamingService(ctx, client, f.Service)
+	return FixtureResult{Available: test.Available, Details: test.Details, Region: test.Region, Error: test.Error}
+}
+
+// Mismatch 比较实际结果与期望，一致时返回空字符串
+func (want FixtureResult) Mismatch(got FixtureResult) string {
+	var diffs []string
+	if got.Available != want.Available {
+		diffs = append(diffs, fmt.Sprintf("available = %v, want %v", got.Available, want.Available))
+	}
+	if want.Details != "" && got.Details != want.Details {
+		diffs = append(diffs, fmt.Sprintf("details = %q, want %q", got.Details, want.Details))
+	}
+	if want.Region != "" && got.Region != want.Region {
+		diffs = append(diffs, fmt.Sprintf("region = %q, want %q", got.Region, want.Region))
+	}
+	if want.Error != "" && got.Error != want.Error {
+		diffs = append(diffs, fmt.Sprintf("error = %q, want %q", got.Error, want.Error))
+	}
+	return strings.Join(diffs, "; ")
+}
+
+// replayTransport 按方法与 URL 返回录制的响应
+type replayTransport struct {
+	fixture *Fixture
+
+	mu   sync.Mutex
+	used map[int]bool
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	ex, err := t.next(req.Method, req.URL.String())
+	if err != nil {
+		return nil, err
+	}
+	if ex.Error != "" {
+		return nil, fmt.Errorf("%s", ex.Error)
+	}
+
+	body := []byte(ex.Body)
+	if ex.BodyFile != "" {
+		if body, err = os.ReadFile(filepath.Join(t.fixture.dir, ex.BodyFile)); err != nil {
+			return nil, err
+		}
+	}
+
+	status := ex.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+	for k, v := range ex.Headers {
+		resp.Header.Set(k, v)
+	}
+	return resp, nil
+}
+
+// next 取出下一条匹配的录制响应
+func (t *replayTransport) next(method, rawURL string) (*FixtureExchange, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.used == nil {
+		t.used = make(map[int]bool)
+	}
+	var last *FixtureExchange
+	for i := range t.fixture.Exchanges {
+		ex := &t.fixture.Exchanges[i]
+		exMethod := ex.Method
+		if exMethod == "" {
+			exMethod = http.MethodGet
+		}
+		if exMethod != method || ex.URL != rawURL {
+			continue
+		}
+		last = ex
+		if !t.used[i] {
+			t.used[i] = true
+			return ex, nil
+		}
+	}
+	// 录制次数不足时重复最后一条
+	if last != nil {
+		return last, nil
+	}
+	return nil, fmt.Errorf("no recorded response for %s %s", method, rawURL)
+}
+
+// FixturesFromChecks 将检测记录 (test-node -debug 的 trace.json) 转换为 fixture，每次检测一个
+// 期望值取自回放结果，录制时判定有误的情况需要手动修正
+func FixturesFromChecks(checks []CheckRecord) []Fixture {
+	fixtures := make([]Fixture, 0, len(checks))
+	for _, check := range checks {
+		f := Fixture{
+			Service:     check.Service,
+			Source:      FixtureCaptured,
+			Description: checkTitle(check),
+			Captured:    check.Started.Format(time.RFC3339),
+		}
+		for _, ex := range check.Exchanges {
+			fe := FixtureExchange{Method: ex.Request.Method, URL: ex.Request.URL, Error: ex.Error}
+			if resp := ex.Response; resp != nil {
+				fe.Error = ""
+				fe.Status = resp.Status
+				fe.Body = resp.Body
+				fe.Headers = fixtureHeaders(resp.Headers)
+			}
+			f.Exchanges = append(f.Exchanges, fe)
+		}
+		f.Expect = f.Replay(context.Background())
+		fixtures = append(fixtures, f)
+	}
+	return fixtures
+}
+
+// FixturesFromHAR 将 HAR (只包含失败的检测) 转换为 fixture，每个 page 一个
+// 期望值同样取自回放结果
+func FixturesFromHAR(har HAR) ([]Fixture, error) {
+	fixtures := make([]Fixture, 0, len(har.Log.Pages))
+	for _, page := range har.Log.Pages {
+		service, _, _ := strings.Cut(page.ID, "-")
+		f := Fixture{Service: service, Source: FixtureCaptured, Captured: page.StartedDateTime, Description: page.Title}
+		for _, entry := range har.Log.Entries {
+			if entry.PageRef != page.ID {
+				continue
+			}
+			fe := FixtureExchange{Method: entry.Request.Method, URL: entry.Request.URL, Error: entry.Error}
+			if entry.Response.Status != 0 {
+				fe.Error = ""
+				fe.Status = entry.Response.Status
+				fe.Headers = make(map[string]string)
+				for _, h := range entry.Response.Headers {
+					if !IsSensitiveHeader(h.Name) {
+						fe.Headers[h.Name] = h.Value
+					}
+				}
+				fe.Body = entry.Response.Content.Text
+				if entry.Response.Content.Encoding == "base64" {
+					body, err := base64.StdEncoding.DecodeString(fe.Body)
+					if err != nil {
+						return nil, fmt.Errorf("page %s: %w", page.ID, err)
+					}
+					fe.Body = string(body)
+				}
+			}
+			f.Exchanges = append(f.Exchanges, fe)
+		}
+		f.Expect = f.Replay(context.Background())
+		fixtures = append(fixtures, f)
+	}
+	return fixtures, nil
+}
+
+// fixtureHeaders 只保留影响判定或便于阅读的响应头，不保存 Cookie
+func fixtureHeaders(h http.Header) map[string]string {
+	headers := make(map[string]string)
+	for name := range h {
+		if IsSensitiveHeader(name) {
+			continue
+		}
+		headers[name] = h.Get(name)
+	}
+	return headers
+}
@@ -0,0 +1,135 @@
+package tester_test
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"Clash-tester/internal/tester"
+)
+
+// loadCorpus 读取 testdata/fixtures 下的全部 fixture，键为相对路径
+func loadCorpus(t *testing.T) map[string]*tester.Fixture {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", "fixtures", "*", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no fixtures found")
+	}
+	corpus := make(map[string]*tester.Fixture)
+	for _, path := range paths {
+		f, err := tester.LoadFixture(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, _ := filepath.Rel(filepath.Join("testdata", "fixtures"), path)
+		corpus[filepath.ToSlash(rel)] = f
+	}
+	return corpus
+}
+
+func TestFixtures(t *testing.T) {
+	corpus := loadCorpus(t)
+	captured := 0
+	for name, f := range corpus {
+		if f.Source == tester.FixtureCaptured {
+			captured++
+		}
+		t.Run(name, func(t *testing.T) {
+			got := f.Replay(context.Background())
+			if diff := f.Expect.Mismatch(got); diff != "" {
+				t.Errorf("%s: %s", f.Description, diff)
+			}
+		})
+	}
+	// 手写样例无法发现站点改版，录制的 fixture 尚未补充时在 -v 输出中提示
+	t.Logf("%d of %d fixtures are captured from real checks, the rest are synthetic", captured, len(corpus))
+}
+
+// TestFixtureConversion 检测记录与 HAR 转换得到的 fixture 应与原 fixture 一致
+func TestFixtureConversion(t *testing.T) {
+	for name, f := range loadCorpus(t) {
+		check := checkRecord(f)
+		if check == nil {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			fromChecks := tester.FixturesFromChecks([]tester.CheckRecord{*check})
+			fromHAR, err := tester.FixturesFromHAR(tester.BuildHAR("node", []tester.CheckRecord{*check}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for via, converted := range map[string][]tester.Fixture{"checks": fromChecks, "har": fromHAR} {
+				if len(converted) != 1 {
+					t.Fatalf("%s: got %d fixtures, want 1", via, len(converted))
+				}
+				got := converted[0]
+				if got.Service != f.Service {
+					t.Errorf("%s: service = %q, want %q", via, got.Service, f.Service)
+				}
+				if got.Source != tester.FixtureCaptured {
+					t.Errorf("%s: source = %q, want %q", via, got.Source, tester.FixtureCaptured)
+				}
+				if _, err := time.Parse(time.RFC3339, got.Captured); err != nil {
+					t.Errorf("%s: captured = %q: %v", via, got.Captured, err)
+				}
+				if diff := f.Expect.Mismatch(got.Expect); diff != "" {
+					t.Errorf("%s: %s", via, diff)
+				}
+				if !reflect.DeepEqual(exchangeKeys(got.Exchanges), exchangeKeys(f.Exchanges)) {
+					t.Errorf("%s: exchanges = %v, want %v", via, exchangeKeys(got.Exchanges), exchangeKeys(f.Exchanges))
+				}
+			}
+		})
+	}
+}
+
+// checkRecord 将 fixture 还原为检测记录，正文保存在单独文件中的 fixture 返回 nil
+func checkRecord(f *tester.Fixture) *tester.CheckRecord {
+	check := &tester.CheckRecord{Service: f.Service, Attempt: 1, Started: time.Now(), Error: f.Expect.Error}
+	for _, ex := range f.Exchanges {
+		if ex.BodyFile != "" {
+			return nil
+		}
+		method := ex.Method
+		if method == "" {
+			method = http.MethodGet
+		}
+		recorded := tester.Exchange{
+			Started: check.Started,
+			Request: tester.RecordedRequest{Method: method, URL: ex.URL, Proto: "HTTP/1.1"},
+			Error:   ex.Error,
+		}
+		if ex.Error == "" {
+			status := ex.Status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			headers := make(http.Header)
+			for k, v := range ex.Headers {
+				headers.Set(k, v)
+			}
+			recorded.Response = &tester.RecordedResponse{Status: status, Proto: "HTTP/1.1", Headers: headers, Location: headers.Get("Location"), Body: ex.Body}
+		}
+		check.Exchanges = append(check.Exchanges, recorded)
+	}
+	return check
+}
+
+// exchangeKeys 用于比较的请求与响应摘要
+func exchangeKeys(exchanges []tester.FixtureExchange) []string {
+	var keys []string
+	for _, ex := range exchanges {
+		status := ex.Status
+		if status == 0 && ex.Error == "" {
+			status = http.StatusOK
+		}
+		keys = append(keys, ex.URL+" "+http.StatusText(status)+" "+ex.Headers["Location"]+" "+ex.Error+" "+ex.Body)
+	}
+	return keys
+}
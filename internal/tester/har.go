@@ -0,0 +1,308 @@
+package tester
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"Clash-tester/internal/version"
+	"Clash-tester/pkg/models"
+)
+
+// Redacted 替换敏感头部取值的占位符
+const Redacted = "[REDACTED]"
+
+// sensitiveHeaders 含凭据的头部 (小写)
+var sensitiveHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"x-api-key":           true,
+}
+
+// IsSensitiveHeader 判断头部是否含凭据，HAR、排查目录与 fixture 中都不保存其取值
+func IsSensitiveHeader(name string) bool {
+	return sensitiveHeaders[strings.ToLower(name)]
+}
+
+// RedactHeaders 返回敏感头部取值替换为 Redacted 的副本
+func RedactHeaders(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	redacted := make(http.Header, len(h))
+	for name, values := range h {
+		if IsSensitiveHeader(name) {
+			values = []string{Redacted}
+		}
+		redacted[name] = append([]string(nil), values...)
+	}
+	return redacted
+}
+
+// HAR 1.2 (http://www.softwareishard.com/blog/har-12-spec/) 中用到的部分
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Comment string     `json:"comment,omitempty"`
+	Pages   []HARPage  `json:"pages"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HARPage 一次失败的检测 (某服务的某次尝试)
+type HARPage struct {
+	StartedDateTime string         `json:"startedDateTime"`
+	ID              string         `json:"id"`
+	Title           string         `json:"title"`
+	PageTimings     HARPageTimings `json:"pageTimings"`
+	Comment         string         `json:"comment,omitempty"` // 判定依据
+}
+
+type HARPageTimings struct {
+	OnContentLoad int `json:"onContentLoad"`
+	OnLoad        int `json:"onLoad"`
+}
+
+type HAREntry struct {
+	PageRef         string      `json:"pageref"`
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            int         `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Error           string      `json:"_error,omitempty"` // 自定义字段：请求失败的原因
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARTimings struct {
+	Blocked int `json:"blocked"`
+	DNS     int `json:"dns"`
+	Connect int `json:"connect"`
+	Send    int `json:"send"`
+	Wait    int `json:"wait"`
+	Receive int `json:"receive"`
+	SSL     int `json:"ssl"`
+}
+
+// BuildHAR 将检测记录转换为 HAR，每次检测为一个 page
+// Cookie 与认证相关的头部取值会被隐去
+func BuildHAR(nodeName string, checks []CheckRecord) HAR {
+	har := HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "Clash-tester", Version: version.Version},
+		Comment: "Failed checks of node " + nodeName,
+		Pages:   []HARPage{},
+		Entries: []HAREntry{},
+	}}
+
+	for _, check := range checks {
+		pageID := fmt.Sprintf("%s-%d", check.Service, check.Attempt)
+		har.Log.Pages = append(har.Log.Pages, HARPage{
+			StartedDateTime: harTime(check.Started),
+			ID:              pageID,
+			Title:           checkTitle(check),
+			PageTimings:     HARPageTimings{OnContentLoad: -1, OnLoad: check.Duration},
+			Comment:         strings.Join(check.Notes, "\n"),
+		})
+		for _, ex := range check.Exchanges {
+			har.Log.Entries = append(har.Log.Entries, harEntry(pageID, ex))
+		}
+	}
+	return har
+}
+
+// checkTitle 检测的简短描述，如 "netflix attempt 1: blocked"
+func checkTitle(check CheckRecord) string {
+	title := fmt.Sprintf("%s attempt %d", check.Service, check.Attempt)
+	if check.Error != "" {
+		title += ": " + check.Error
+	}
+	return title
+}
+
+func harEntry(pageID string, ex Exchange) HAREntry {
+	entry := HAREntry{
+		PageRef:         pageID,
+		StartedDateTime: harTime(ex.Started),
+		Time:            ex.Duration,
+		Request: HARRequest{
+			Method:      ex.Request.Method,
+			URL:         ex.Request.URL,
+			HTTPVersion: ex.Request.Proto,
+			Cookies:     []HARNameValue{},
+			Headers:     harHeaders(ex.Request.Headers),
+			QueryString: harQuery(ex.Request.URL),
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Response: HARResponse{
+			Cookies:     []HARNameValue{},
+			Headers:     []HARNameValue{},
+			Content:     HARContent{MimeType: "x-unknown"},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Timings: harTimings(ex),
+		Error:   ex.Error,
+	}
+
+	if resp := ex.Response; resp != nil {
+		entry.Response.Status = resp.Status
+		entry.Response.StatusText = http.StatusText(resp.Status)
+		entry.Response.HTTPVersion = resp.Proto
+		entry.Response.Headers = harHeaders(resp.Headers)
+		entry.Response.RedirectURL = resp.Location
+		entry.Response.Content = harContent(resp)
+	}
+	return entry
+}
+
+// harHeaders 按名称排序输出头部，隐去敏感取值
+func harHeaders(h http.Header) []HARNameValue {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := []HARNameValue{}
+	for _, name := range names {
+		for _, value := range h[name] {
+			if IsSensitiveHeader(name) {
+				value = Redacted
+			}
+			headers = append(headers, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+func harQuery(rawURL string) []HARNameValue {
+	query := []HARNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return query
+	}
+	values := u.Query()
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range values[k] {
+			query = append(query, HARNameValue{Name: k, Value: v})
+		}
+	}
+	return query
+}
+
+func harContent(resp *RecordedResponse) HARContent {
+	content := HARContent{Size: len(resp.Body), MimeType: resp.Headers.Get("Content-Type")}
+	if content.MimeType == "" {
+		content.MimeType = "x-unknown"
+	}
+	if resp.Body == "" {
+		return content
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(content.MimeType)
+	if utf8.ValidString(resp.Body) && !strings.HasPrefix(mediaType, "image/") {
+		content.Text = resp.Body
+	} else {
+		content.Text = base64.StdEncoding.EncodeToString([]byte(resp.Body))
+		content.Encoding = "base64"
+	}
+	if resp.Truncated {
+		content.Comment = fmt.Sprintf("body truncated to the first %d bytes", len(resp.Body))
+	}
+	return content
+}
+
+// harTimings 连接阶段取自 httptrace，HAR 中 connect 包含 ssl
+func harTimings(ex Exchange) HARTimings {
+	t := ex.Timing
+	timings := HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Wait: t.TTFB}
+	if t.DNS > 0 {
+		timings.DNS = t.DNS
+	}
+	if !t.Reused && (t.Connect > 0 || t.ProxyConnect > 0 || t.TLSHandshake > 0) {
+		timings.Connect = t.Connect + t.ProxyConnect + t.TLSHandshake
+		if t.TLSHandshake > 0 {
+			timings.SSL = t.TLSHandshake
+		}
+	}
+	if receive := ex.Duration - t.Total; t.Total > 0 && receive > 0 {
+		timings.Receive = receive
+	}
+	return timings
+}
+
+func harTime(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05.000Z07:00")
+}
+
+// failedChecks 挑出最终结果为失败的服务的全部检测记录 (含每次重试)
+func failedChecks(result models.NodeTestResult, checks []CheckRecord) []CheckRecord {
+	var failed []CheckRecord
+	for _, check := range checks {
+		if test, ok := result.Tests[check.Service]; ok && !test.Available {
+			failed = append(failed, check)
+		} else if test, ok := result.StreamTests[check.Service]; ok && !test.Available {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
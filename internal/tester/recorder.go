@@ -0,0 +1,221 @@
+package tester
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"Clash-tester/pkg/models"
+)
+
+// DefaultBodyLimit 每个响应记录的正文字节数上限
+const DefaultBodyLimit = 64 * 1024
+
+// Recorder 记录检测过程中的全部 HTTP 交互与判定依据，用于单节点排查
+// 通过 Options.Recorder 启用，未启用时没有任何额外开销
+type Recorder struct {
+	BodyLimit int // 每个响应记录的正文字节数，0 表示 DefaultBodyLimit
+
+	mu     sync.Mutex
+	checks []*CheckRecord
+}
+
+// CheckRecord 一次检测 (某服务的某次尝试) 的完整记录
+type CheckRecord struct {
+	Service   string     `json:"service"`
+	Attempt   int        `json:"attempt"`
+	Started   time.Time  `json:"started"`
+	Duration  int        `json:"duration_ms"`
+	Available bool       `json:"available"`
+	Error     string     `json:"error,omitempty"`
+	Exchanges []Exchange `json:"exchanges"` // 按发起顺序，跟随重定向时每一跳各一条
+	Notes     []string   `json:"notes,omitempty"`
+
+	rec *Recorder // 所属的 Recorder，修改记录时持有其锁
+}
+
+// Exchange 一次 HTTP 请求与响应
+type Exchange struct {
+	Started  time.Time         `json:"started"`
+	Duration int               `json:"duration_ms"` // 发起请求到收到响应头 (含读取正文摘录)
+	Request  RecordedRequest   `json:"request"`
+	Response *RecordedResponse `json:"response,omitempty"`
+	Timing   models.Timing     `json:"timing"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// RecordedRequest 请求行与请求头
+type RecordedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Proto   string      `json:"proto"`
+	Headers http.Header `json:"headers"`
+}
+
+// RecordedResponse 状态行、响应头与正文摘录
+type RecordedResponse struct {
+	Status    int         `json:"status"`
+	Proto     string      `json:"proto"`
+	Headers   http.Header `json:"headers"`
+	Location  string      `json:"location,omitempty"` // 重定向目标
+	Body      string      `json:"body,omitempty"`     // 正文摘录 (已解压)
+	Truncated bool        `json:"truncated,omitempty"`
+}
+
+// Redacted 返回敏感头部取值已替换的副本，用于保存到文件
+func (c CheckRecord) Redacted() CheckRecord {
+	exchanges := make([]Exchange, len(c.Exchanges))
+	for i, ex := range c.Exchanges {
+		ex.Request.Headers = RedactHeaders(ex.Request.Headers)
+		if ex.Response != nil {
+			resp := *ex.Response
+			resp.Headers = RedactHeaders(resp.Headers)
+			ex.Response = &resp
+		}
+		exchanges[i] = ex
+	}
+	c.Exchanges = exchanges
+	return c
+}
+
+// Checks 返回已记录的全部检测
+func (r *Recorder) Checks() []CheckRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	checks := make([]CheckRecord, len(r.checks))
+	for i, c := range r.checks {
+		checks[i] = *c
+	}
+	return checks
+}
+
+// since 返回第 n 条之后记录的检测，r 为 nil 时返回 nil
+func (r *Recorder) since(n int) []CheckRecord {
+	if r == nil {
+		return nil
+	}
+	return r.Checks()[n:]
+}
+
+// count 已记录的检测数
+func (r *Recorder) count() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.checks)
+}
+
+func (r *Recorder) bodyLimit() int {
+	if r.BodyLimit <= 0 {
+		return DefaultBodyLimit
+	}
+	return r.BodyLimit
+}
+
+type recorderKey struct{}
+type checkKey struct{}
+
+// withRecorder 将 Recorder 挂到 ctx 上，rec 为 nil 时原样返回
+func withRecorder(ctx context.Context, rec *Recorder) context.Context {
+	if rec == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, recorderKey{}, rec)
+}
+
+// beginCheck 开始记录一次检测，未启用记录时返回原 ctx 与 nil
+func beginCheck(ctx context.Context, service string, attempt int) (context.Context, *CheckRecord) {
+	rec, _ := ctx.Value(recorderKey{}).(*Recorder)
+	if rec == nil {
+		return ctx, nil
+	}
+	check := &CheckRecord{Service: service, Attempt: attempt, Started: time.Now(), Exchanges: []Exchange{}, rec: rec}
+	rec.mu.Lock()
+	rec.checks = append(rec.checks, check)
+	rec.mu.Unlock()
+	return context.WithValue(ctx, checkKey{}, check), check
+}
+
+// finish 记录检测结果
+func (c *CheckRecord) finish(err error) {
+	if c == nil {
+		return
+	}
+	c.rec.mu.Lock()
+	defer c.rec.mu.Unlock()
+	c.Duration = int(time.Since(c.Started).Milliseconds())
+	c.Available = err == nil
+	if err != nil {
+		c.Error = err.Error()
+	}
+}
+
+// note 记录检测中的判定依据，如命中的关键词、重定向目标
+// ctx 中没有正在记录的检测时不做任何事
+func note(ctx context.Context, format string, args ...any) {
+	check, _ := ctx.Value(checkKey{}).(*CheckRecord)
+	if check == nil {
+		return
+	}
+	check.rec.mu.Lock()
+	check.Notes = append(check.Notes, fmt.Sprintf(format, args...))
+	check.rec.mu.Unlock()
+}
+
+// recordRoundTrip 执行请求并记录到 ctx 中正在记录的检测
+// 正文摘录在这里预读，随后与剩余部分拼接交还给调用方，不影响检测逻辑
+func recordRoundTrip(base http.RoundTripper, req *http.Request, rt *requestTrace) (*http.Response, error) {
+	check, _ := req.Context().Value(checkKey{}).(*CheckRecord)
+	if check == nil {
+		return base.RoundTrip(req)
+	}
+
+	ex := Exchange{
+		Started: time.Now(),
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Proto:   req.Proto,
+			Headers: req.Header.Clone(),
+		},
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		ex.Error = err.Error()
+	} else {
+		recorded := &RecordedResponse{
+			Status:   resp.StatusCode,
+			Proto:    resp.Proto,
+			Headers:  resp.Header.Clone(),
+			Location: resp.Header.Get("Location"),
+		}
+		limit := check.rec.bodyLimit()
+		excerpt, readErr := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+		if len(excerpt) > limit {
+			recorded.Truncated = true
+		}
+		recorded.Body = string(excerpt[:min(len(excerpt), limit)])
+		if readErr != nil {
+			ex.Error = "reading body: " + readErr.Error()
+		}
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(excerpt), resp.Body), resp.Body}
+		ex.Response = recorded
+	}
+	ex.Duration = int(time.Since(ex.Started).Milliseconds())
+	ex.Timing = rt.timing()
+
+	check.rec.mu.Lock()
+	check.Exchanges = append(check.Exchanges, ex)
+	check.rec.mu.Unlock()
+	return resp, err
+}
@@ -2,6 +2,9 @@ package tester
 
 import (
 	"Clash-tester/pkg/models"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,56 +14,173 @@ import (
 	"time"
 )
 
+// 默认值，可通过 Options.Retries / Options.Timeout 覆盖
 const (
 	MaxRetries  = 2
 	TestTimeout = 10 * time.Second
 )
 
+// AIServices AI 服务检测项
+var AIServices = []string{"openai", "gemini", "claude"}
+
+// StreamServices 流媒体检测项
+var StreamServices = []string{"netflix", "disney", "youtube", "max"}
+
+// serviceTitles 各检测项的显示名称
+var serviceTitles = map[string]string{
+	"openai": "OpenAI", "gemini": "Gemini", "claude": "Claude",
+	"netflix": "Netflix", "disney": "Disney+", "youtube": "YouTube", "max": "HBO Max",
+}
+
+// Services 返回全部检测项，AI 服务在前
+func Services() []string {
+	return append(append([]string{}, AIServices...), StreamServices...)
+}
+
+// IsService 判断 name 是否为已知的检测项
+func IsService(name string) bool {
+	return contains(AIServices, name) || contains(StreamServices, name)
+}
+
+// ServiceTitle 返回检测项的显示名称
+func ServiceTitle(name string) string {
+	if title, ok := serviceTitles[name]; ok {
+		return title
+	}
+	return name
+}
+
+var aiTestFuncs = map[string]testFunc{
+	"openai": testOpenAI,
+	"gemini": testGemini,
+	"claude": testClaude,
+}
+
+// Options 单个节点测试的可选项
+type Options struct {
+	SpeedTest SpeedTestConfig
+	Services  []string       // 启用的检测项，为空表示全部启用
+	Only      []string       // 本次只检测这些服务 (增量测试)，为空表示全部检测
+	Retries   *int           // 失败后的重试次数，nil 表示使用 MaxRetries
+	Timeout   time.Duration  // 单次请求超时，0 表示使用 TestTimeout
+	Recorder  *Recorder      // 记录全部 HTTP 交互与判定依据 (单节点排查)，nil 表示不记录
+	Endpoints *Endpoints     // 检测访问的地址，nil 表示 DefaultEndpoints
+	RootCAs   *x509.CertPool // 校验目标站点证书的根证书，nil 表示系统根证书 (测试时信任本地替身服务)
+}
+
+// shouldTest 判断某项服务是否需要检测
+func (o Options) shouldTest(service string) bool {
+	return (len(o.Services) == 0 || contains(o.Services, service)) &&
+		(len(o.Only) == 0 || contains(o.Only, service))
+}
+
+func (o Options) retries() int {
+	if o.Retries == nil || *o.Retries < 0 {
+		return MaxRetries
+	}
+	return *o.Retries
+}
+
+func (o Options) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return TestTimeout
+	}
+	return o.Timeout
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
 // TestNode 测试单个节点的所有服务
-func TestNode(node models.ProxyNode, proxyURL string) models.NodeTestResult {
+// ctx 取消或超时后，尚未完成的检测会尽快失败返回
+func TestNode(ctx context.Context, node models.ProxyNode, proxyURL string, opts Options) models.NodeTestResult {
+	result, _ := testNode(ctx, node, proxyURL, opts, false)
+	return result
+}
+
+// TestNodeFailures 同 TestNode，另外返回最终失败的服务的全部检测记录 (含每次重试)，由调用方保存为 HAR
+func TestNodeFailures(ctx context.Context, node models.ProxyNode, proxyURL string, opts Options) (models.NodeTestResult, []CheckRecord) {
+	return testNode(ctx, node, proxyURL, opts, true)
+}
+
+func testNode(ctx context.Context, node models.ProxyNode, proxyURL string, opts Options, keepFailed bool) (models.NodeTestResult, []CheckRecord) {
 	result := models.NodeTestResult{
 		NodeName:    node.Name,
 		NodeType:    node.Type,
 		Server:      node.Server,
+		Fingerprint: node.Fingerprint(),
 		Tests:       make(map[string]models.ServiceTest),
 		StreamTests: make(map[string]models.StreamTest),
 	}
 
 	start := time.Now()
+	result.TestedAt = start
+
+	// 返回失败的检测需要记录交互；与 Recorder 共用时只取本节点的记录
+	rec := opts.Recorder
+	if keepFailed && rec == nil {
+		rec = &Recorder{}
+	}
+	recorded := rec.count()
+	ctx = withRecorder(ctx, rec)
+	ctx = withEndpoints(ctx, opts.Endpoints)
 
 	// 创建HTTP客户端
-	client := createProxyClient(proxyURL)
+	client := createProxyClient(proxyURL, opts.timeout(), opts.RootCAs)
 
 	// 测试 AI 服务
-	result.Tests["openai"] = testServiceWithRetry(client, "openai", testOpenAI)
-	result.Tests["gemini"] = testServiceWithRetry(client, "gemini", testGemini)
-	result.Tests["claude"] = testServiceWithRetry(client, "claude", testClaude)
+	for _, name := range AIServices {
+		if opts.shouldTest(name) {
+			result.Tests[name] = testServiceWithRetry(ctx, client, name, aiTestFuncs[name], opts.retries())
+		}
+	}
 
 	// 测试流媒体服务
-	result.StreamTests["netflix"] = TestStreamingService(client, "netflix")
-	result.StreamTests["disney"] = TestStreamingService(client, "disney")
-	result.StreamTests["youtube"] = TestStreamingService(client, "youtube")
-	result.StreamTests["max"] = TestStreamingService(client, "max")
+	for _, name := range StreamServices {
+		if opts.shouldTest(name) {
+			result.StreamTests[name] = TestStreamingService(ctx, client, name)
+		}
+	}
+
+	// 下载测速 (可选)
+	if opts.SpeedTest.Enabled() && (!opts.SpeedTest.OnlyUnlocked || IsNodeUnlocked(result)) {
+		speed := TestSpeed(ctx, proxyURL, opts.SpeedTest)
+		result.SpeedTest = &speed
+	}
 
 	result.TotalTime = int(time.Since(start).Milliseconds())
 
-	return result
+	if keepFailed {
+		return result, failedChecks(result, rec.since(recorded))
+	}
+	return result, nil
 }
 
-type testFunc func(*http.Client, *models.ServiceTest) error
+type testFunc func(context.Context, *http.Client, *models.ServiceTest) error
 
-func testServiceWithRetry(client *http.Client, serviceName string, fn testFunc) models.ServiceTest {
+func testServiceWithRetry(ctx context.Context, client *http.Client, serviceName string, fn testFunc, retries int) models.ServiceTest {
 	result := models.ServiceTest{
 		Service:  serviceName,
 		Attempts: 0,
+		TestedAt: time.Now(),
 	}
 
-	for attempt := 0; attempt <= MaxRetries; attempt++ {
+	for attempt := 0; attempt <= retries; attempt++ {
 		result.Attempts++
 
+		resetTrace(client)
+		checkCtx, check := beginCheck(ctx, serviceName, result.Attempts)
 		start := time.Now()
-		err := fn(client, &result)
+		err := fn(checkCtx, client, &result)
+		check.finish(err)
 		result.ResponseTime = int(time.Since(start).Milliseconds())
+		result.Timing = takeTrace(client)
 
 		if err == nil {
 			result.Available = true
@@ -69,34 +189,42 @@ func testServiceWithRetry(client *http.Client, serviceName string, fn testFunc)
 
 		result.Error = err.Error()
 
-		// 如果是最后一次尝试
-		if attempt == MaxRetries {
+		// 如果是最后一次尝试，或者已被取消，不再重试
+		if attempt == retries || ctx.Err() != nil {
 			result.Available = false
-		} else {
-			// 重试前等待
-			time.Sleep(500 * time.Millisecond)
+			break
+		}
+
+		// 重试前等待
+		select {
+		case <-ctx.Done():
+		case <-time.After(500 * time.Millisecond):
 		}
 	}
 
 	return result
 }
 
-func createProxyClient(proxyURL string) *http.Client {
+func createProxyClient(proxyURL string, timeout time.Duration, rootCAs *x509.CertPool) *http.Client {
 	proxyURLParsed, _ := url.Parse(proxyURL)
 
 	return &http.Client{
-		Timeout: TestTimeout,
-		Transport: &http.Transport{
-			Proxy:              http.ProxyURL(proxyURLParsed),
-			MaxIdleConns:       10,
-			IdleConnTimeout:    30 * time.Second,
-			DisableCompression: false,
+		Timeout: timeout,
+		// 包一层 httptrace，记录每个检测的建连/握手/首字节耗时
+		Transport: &tracingTransport{
+			base: &http.Transport{
+				Proxy:              http.ProxyURL(proxyURLParsed),
+				MaxIdleConns:       10,
+				IdleConnTimeout:    30 * time.Second,
+				DisableCompression: false,
+				TLSClientConfig:    &tls.Config{RootCAs: rootCAs},
+			},
 		},
 	}
 }
 
-func testOpenAI(client *http.Client, result *models.ServiceTest) error {
-	req, _ := http.NewRequest("GET", "https://chatgpt.com/cdn-cgi/trace", nil)
+func testOpenAI(ctx context.Context, client *http.Client, result *models.ServiceTest) error {
+	req, _ := http.NewRequestWithContext(ctx, "GET", endpoints(ctx).OpenAI, nil)
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
 
 	resp, err := client.Do(req)
@@ -108,6 +236,7 @@ func testOpenAI(client *http.Client, result *models.ServiceTest) error {
 	result.StatusCode = resp.StatusCode
 
 	if resp.StatusCode == 403 {
+		note(ctx, "status 403 from chatgpt.com is treated as a Cloudflare block")
 		return fmt.Errorf("Cloudflare blocked (403)")
 	}
 
@@ -123,22 +252,24 @@ func testOpenAI(client *http.Client, result *models.ServiceTest) error {
 		for _, line := range lines {
 			if strings.HasPrefix(line, "loc=") {
 				result.Country = strings.TrimPrefix(line, "loc=")
+				note(ctx, "trace line %q gives the country", line)
 				return nil
 			}
 		}
 	}
 
+	note(ctx, "no loc= line in the cdn-cgi/trace body")
 	return fmt.Errorf("trace info not found")
 }
 
-func testGemini(client *http.Client, result *models.ServiceTest) error {
+func testGemini(ctx context.Context, client *http.Client, result *models.ServiceTest) error {
 	originalCheckRedirect := client.CheckRedirect
 	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 		return http.ErrUseLastResponse
 	}
 	defer func() { client.CheckRedirect = originalCheckRedirect }()
 
-	req, _ := http.NewRequest("GET", "https://gemini.google.com/app", nil)
+	req, _ := http.NewRequestWithContext(ctx, "GET", endpoints(ctx).Gemini, nil)
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
 
 	resp, err := client.Do(req)
@@ -150,14 +281,16 @@ func testGemini(client *http.Client, result *models.ServiceTest) error {
 	result.StatusCode = resp.StatusCode
 
 	if resp.StatusCode == 200 {
-		result.Country, _ = getCountryByIP(client)
+		result.Country, _ = getCountryByIP(ctx, client)
 		return nil
 	} else if resp.StatusCode == 302 || resp.StatusCode == 301 {
 		loc := resp.Header.Get("Location")
 		if strings.Contains(loc, "accounts.google.com") {
-			result.Country, _ = getCountryByIP(client)
+			note(ctx, "redirect to %s (accounts.google.com) means the app is available", loc)
+			result.Country, _ = getCountryByIP(ctx, client)
 			return nil
 		}
+		note(ctx, "redirect to %s does not contain accounts.google.com", loc)
 		return fmt.Errorf("redirected to unsupported page")
 	} else if resp.StatusCode == 403 || resp.StatusCode == 451 {
 		return fmt.Errorf("region blocked (%d)", resp.StatusCode)
@@ -166,8 +299,8 @@ func testGemini(client *http.Client, result *models.ServiceTest) error {
 	return fmt.Errorf("unknown status: %d", resp.StatusCode)
 }
 
-func testClaude(client *http.Client, result *models.ServiceTest) error {
-	req, _ := http.NewRequest("GET", "https://claude.ai/login", nil)
+func testClaude(ctx context.Context, client *http.Client, result *models.ServiceTest) error {
+	req, _ := http.NewRequestWithContext(ctx, "GET", endpoints(ctx).Claude, nil)
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
 
 	resp, err := client.Do(req)
@@ -197,16 +330,19 @@ func testClaude(client *http.Client, result *models.ServiceTest) error {
 
 	for _, kw := range failKeywords {
 		if strings.Contains(bodyStr, kw) {
+			note(ctx, "failKeyword %q found in the login page", kw)
 			return fmt.Errorf("region not supported")
 		}
 	}
+	note(ctx, "none of the failKeywords %q found in the login page", failKeywords)
 
-	result.Country, _ = getCountryByIP(client)
+	result.Country, _ = getCountryByIP(ctx, client)
 	return nil
 }
 
-func getCountryByIP(client *http.Client) (string, error) {
-	resp, err := client.Get("http://ip-api.com/json/?fields=countryCode")
+func getCountryByIP(ctx context.Context, client *http.Client) (string, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", endpoints(ctx).IPInfo, nil)
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -218,8 +354,10 @@ func getCountryByIP(client *http.Client) (string, error) {
 
 	body, _ := io.ReadAll(resp.Body)
 	if err := json.Unmarshal(body, &result); err != nil {
+		note(ctx, "ip-api.com returned an invalid body: %v", err)
 		return "", err
 	}
+	note(ctx, "ip-api.com reports country %q", result.CountryCode)
 
 	return result.CountryCode, nil
 }
@@ -240,6 +378,19 @@ func IsNodeSuccess(result models.NodeTestResult) bool {
 	return false
 }
 
+// IsNodeUnlocked 判断节点是否通过了任意一项解锁检测 (AI 或流媒体)
+func IsNodeUnlocked(result models.NodeTestResult) bool {
+	if IsNodeSuccess(result) {
+		return true
+	}
+	for _, test := range result.StreamTests {
+		if test.Available {
+			return true
+		}
+	}
+	return false
+}
+
 // GenerateSummary 生成测试摘要
 func GenerateSummary(results []models.NodeTestResult) models.TestSummary {
 	summary := models.TestSummary{
@@ -250,7 +401,7 @@ func GenerateSummary(results []models.NodeTestResult) models.TestSummary {
 	}
 
 	// Initialize streaming summary map
-	streamServices := []string{"netflix", "disney", "youtube", "max"}
+	streamServices := StreamServices
 	for _, s := range streamServices {
 		summary.Streaming[s] = models.ServiceSummary{Countries: []string{}}
 	}
@@ -267,9 +418,16 @@ func GenerateSummary(results []models.NodeTestResult) models.TestSummary {
 	}
 
 	for _, result := range results {
-		updateServiceSummary(&summary.OpenAI, result.Tests["openai"], countrySet["openai"])
-		updateServiceSummary(&summary.Gemini, result.Tests["gemini"], countrySet["gemini"])
-		updateServiceSummary(&summary.Claude, result.Tests["claude"], countrySet["claude"])
+		// 未启用的检测项没有结果，不计入不可用
+		if test, ok := result.Tests["openai"]; ok {
+			updateServiceSummary(&summary.OpenAI, test, countrySet["openai"])
+		}
+		if test, ok := result.Tests["gemini"]; ok {
+			updateServiceSummary(&summary.Gemini, test, countrySet["gemini"])
+		}
+		if test, ok := result.Tests["claude"]; ok {
+			updateServiceSummary(&summary.Claude, test, countrySet["claude"])
+		}
 		
 		for _, s := range streamServices {
 			if test, ok := result.StreamTests[s]; ok {
@@ -299,6 +457,8 @@ func GenerateSummary(results []models.NodeTestResult) models.TestSummary {
 		summary.Streaming[s] = sSummary
 	}
 
+	summary.Stability = summarizeStability(results)
+
 	return summary
 }
 
@@ -0,0 +1,159 @@
+package tester_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"Clash-tester/internal/fakenet"
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+)
+
+func TestTestNode(t *testing.T) {
+	e := tester.DefaultEndpoints
+	us := fakenet.NewTestInternet(t, e, fakenet.Unlocked("US"))
+	hk := fakenet.NewTestInternet(t, e, fakenet.Profile{Country: "HK", OpenAI: true, Netflix: fakenet.NetflixOriginals, YouTube: true})
+	cn := fakenet.NewTestInternet(t, e, fakenet.Blocked("CN"))
+
+	core := fakenet.StartTestCore(t, map[string]fakenet.Node{
+		"us":   {Internet: us},
+		"hk":   {Internet: hk},
+		"cn":   {Internet: cn},
+		"down": {},
+	})
+
+	tests := []struct {
+		node      string
+		available map[string]bool
+		country   string // OpenAI 检测得到的国家
+		netflix   string
+	}{
+		{
+			node: "us",
+			available: map[string]bool{
+				"openai": true, "gemini": true, "claude": true,
+				"netflix": true, "disney": true, "youtube": true, "max": true,
+			},
+			country: "US",
+			netflix: fakenet.NetflixFull,
+		},
+		{
+			node: "hk",
+			available: map[string]bool{
+				"openai": true, "gemini": false, "claude": false,
+				"netflix": true, "disney": false, "youtube": true, "max": false,
+			},
+			country: "HK",
+			netflix: fakenet.NetflixOriginals,
+		},
+		{node: "cn", available: map[string]bool{}},
+		{node: "down", available: map[string]bool{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.node, func(t *testing.T) {
+			if err := core.SelectNode(context.Background(), tt.node); err != nil {
+				t.Fatal(err)
+			}
+			retries := 0
+			result := tester.TestNode(context.Background(), models.ProxyNode{Name: tt.node, Type: "ss"}, core.ProxyURL(), tester.Options{
+				Retries: &retries,
+				Timeout: 5 * time.Second,
+				RootCAs: us.RootCAs,
+			})
+
+			for _, name := range tester.AIServices {
+				if got := result.Tests[name].Available; got != tt.available[name] {
+					t.Errorf("%s available = %v, want %v (error %q)", name, got, tt.available[name], result.Tests[name].Error)
+				}
+			}
+			for _, name := range tester.StreamServices {
+				if got := result.StreamTests[name].Available; got != tt.available[name] {
+					t.Errorf("%s available = %v, want %v (error %q)", name, got, tt.available[name], result.StreamTests[name].Error)
+				}
+			}
+			if got := result.Tests["openai"].Country; got != tt.country {
+				t.Errorf("openai country = %q, want %q", got, tt.country)
+			}
+			if got := result.StreamTests["netflix"].Details; got != tt.netflix {
+				t.Errorf("netflix details = %q, want %q", got, tt.netflix)
+			}
+		})
+	}
+}
+
+func TestTestNodeEndpoints(t *testing.T) {
+	// 检测地址可以替换，替身服务不必使用真实域名
+	e := tester.Endpoints{
+		OpenAI:           "https://openai.test/cdn-cgi/trace",
+		Gemini:           "https://gemini.test/app",
+		Claude:           "https://claude.test/login",
+		IPInfo:           "http://ipinfo.test/json",
+		NetflixFull:      "https://netflix.test/title/1",
+		NetflixOriginals: "https://netflix.test/title/2",
+		Disney:           "https://disney.test/",
+		YouTube:          "https://youtube.test/",
+		Max:              "https://max.test/",
+	}
+	jp := fakenet.NewTestInternet(t, e, fakenet.Unlocked("JP"))
+	core := fakenet.StartTestCore(t, map[string]fakenet.Node{"jp": {Internet: jp}})
+	if err := core.SelectNode(context.Background(), "jp"); err != nil {
+		t.Fatal(err)
+	}
+
+	retries := 0
+	result := tester.TestNode(context.Background(), models.ProxyNode{Name: "jp", Type: "ss"}, core.ProxyURL(), tester.Options{
+		Services:  []string{"claude", "disney"},
+		Retries:   &retries,
+		Timeout:   5 * time.Second,
+		Endpoints: &e,
+		RootCAs:   jp.RootCAs,
+	})
+
+	if claude := result.Tests["claude"]; !claude.Available || claude.Country != "JP" {
+		t.Errorf("claude = %+v, want available in JP", claude)
+	}
+	if disney := result.StreamTests["disney"]; !disney.Available || disney.Region != "JP" {
+		t.Errorf("disney = %+v, want available in JP", disney)
+	}
+	if _, ok := result.Tests["openai"]; ok {
+		t.Errorf("openai was tested although not in Services")
+	}
+	if hits := jp.Hits(e.Claude); hits != 1 {
+		t.Errorf("claude endpoint hit %d times, want 1", hits)
+	}
+	if hits := jp.Hits(e.OpenAI); hits != 0 {
+		t.Errorf("openai endpoint hit %d times, want 0", hits)
+	}
+}
+
+// TestGenerateSummaryEnabledServices 未启用的 AI 服务不计入不可用
+func TestGenerateSummaryEnabledServices(t *testing.T) {
+	us := fakenet.NewTestInternet(t, tester.DefaultEndpoints, fakenet.Unlocked("US"))
+	core := fakenet.StartTestCore(t, map[string]fakenet.Node{"us": {Internet: us}})
+	if err := core.SelectNode(context.Background(), "us"); err != nil {
+		t.Fatal(err)
+	}
+
+	retries := 0
+	result := tester.TestNode(context.Background(), models.ProxyNode{Name: "us", Type: "ss"}, core.ProxyURL(), tester.Options{
+		Services: []string{"openai", "netflix"},
+		Retries:  &retries,
+		Timeout:  5 * time.Second,
+		RootCAs:  us.RootCAs,
+	})
+	summary := tester.GenerateSummary([]models.NodeTestResult{result})
+
+	if got := summary.OpenAI; got.Available != 1 || got.Unavailable != 0 {
+		t.Errorf("openai = %d/%d available/unavailable, want 1/0", got.Available, got.Unavailable)
+	}
+	for name, got := range map[string]models.ServiceSummary{"gemini": summary.Gemini, "claude": summary.Claude, "disney": summary.Streaming["disney"]} {
+		if got.Available != 0 || got.Unavailable != 0 {
+			t.Errorf("%s = %d/%d available/unavailable, want 0/0 when not enabled", name, got.Available, got.Unavailable)
+		}
+	}
+	if got := summary.Streaming["netflix"]; got.Available != 1 {
+		t.Errorf("netflix available = %d, want 1", got.Available)
+	}
+}
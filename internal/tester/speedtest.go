@@ -0,0 +1,95 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"Clash-tester/pkg/models"
+)
+
+// SpeedTestConfig 下载测速配置
+type SpeedTestConfig struct {
+	URL          string        // 测速文件地址，为空表示不测速
+	MaxBytes     int64         // 最多下载的字节数
+	MaxDuration  time.Duration // 最长下载时间
+	OnlyUnlocked bool          // 只对通过解锁检测的节点测速，节省流量
+}
+
+// Enabled 是否启用测速
+func (c SpeedTestConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+// TestSpeed 通过代理下载测速文件，统计首字节时间与下载速率
+func TestSpeed(ctx context.Context, proxyURL string, cfg SpeedTestConfig) models.SpeedTest {
+	result := models.SpeedTest{URL: cfg.URL}
+
+	// 测速需要长时间读取 Body，不能使用带整体超时的共享客户端
+	client := createProxyClient(proxyURL, TestTimeout, nil)
+	client.Timeout = 0
+
+	// 连接阶段沿用普通检测的超时，下载阶段由 MaxDuration 控制
+	ctx, cancel := context.WithTimeout(ctx, TestTimeout+cfg.MaxDuration)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", cfg.URL, nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		result.Error = fmt.Sprintf("unexpected status: %d", resp.StatusCode)
+		return result
+	}
+
+	var reader io.Reader = resp.Body
+	if cfg.MaxBytes > 0 {
+		reader = io.LimitReader(resp.Body, cfg.MaxBytes)
+	}
+
+	// 读第一个分片记录首字节时间，之后再开始计时下载速率
+	buf := make([]byte, 32*1024)
+	n, err := reader.Read(buf)
+	result.TTFB = int(time.Since(start).Milliseconds())
+	result.Bytes = int64(n)
+	firstChunk := int64(n)
+
+	transferStart := time.Now()
+	deadline := transferStart.Add(cfg.MaxDuration)
+	for err == nil && (cfg.MaxDuration <= 0 || time.Now().Before(deadline)) {
+		n, err = reader.Read(buf)
+		result.Bytes += int64(n)
+	}
+	elapsed := time.Since(transferStart)
+	result.Duration = int(time.Since(start).Milliseconds())
+
+	// 达到大小上限 (EOF) 或时间上限都属于正常结束，但外部取消不算
+	if err != nil && err != io.EOF && ctx.Err() != context.DeadlineExceeded {
+		result.Error = err.Error()
+		return result
+	}
+
+	if result.Bytes == 0 {
+		result.Error = "no data received"
+		return result
+	}
+
+	// 速率只统计首个分片之后的数据，避免把握手延迟算进带宽
+	if result.Bytes > firstChunk && elapsed > 0 {
+		result.DownloadMbps = float64((result.Bytes-firstChunk)*8) / elapsed.Seconds() / 1e6
+	} else if total := time.Since(start); total > 0 {
+		result.DownloadMbps = float64(result.Bytes*8) / total.Seconds() / 1e6
+	}
+	result.Available = true
+
+	return result
+}
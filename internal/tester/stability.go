@@ -0,0 +1,130 @@
+package tester
+
+import (
+	"math"
+
+	"Clash-tester/pkg/models"
+)
+
+// 稳定性分类
+const (
+	StabilityStable = "stable"
+	StabilityFlaky  = "flaky"
+	StabilityDown   = "down"
+)
+
+// AggregateRounds 合并同一节点多轮测试的结果
+// 各服务保留最后一轮的结果，另外统计每个服务的成功率与延迟波动
+func AggregateRounds(rounds []models.NodeTestResult) models.NodeTestResult {
+	result := rounds[len(rounds)-1]
+	if len(rounds) == 1 {
+		return result
+	}
+
+	samples := make(map[string][]sample)
+	totalTime := 0
+	for _, r := range rounds {
+		totalTime += r.TotalTime
+		for name, test := range r.Tests {
+			samples[name] = append(samples[name], sample{test.Available, test.ResponseTime})
+		}
+		for name, test := range r.StreamTests {
+			samples[name] = append(samples[name], sample{test.Available, test.ResponseTime})
+		}
+	}
+
+	result.TotalTime = totalTime
+	result.Stability = make(map[string]models.ServiceStability, len(samples))
+	for name, list := range samples {
+		result.Stability[name] = computeStability(list)
+	}
+
+	return result
+}
+
+type sample struct {
+	available bool
+	latency   int
+}
+
+func computeStability(list []sample) models.ServiceStability {
+	stat := models.ServiceStability{Rounds: len(list)}
+
+	var latencies []float64
+	for _, s := range list {
+		if !s.available {
+			continue
+		}
+		stat.Successes++
+		latencies = append(latencies, float64(s.latency))
+	}
+	stat.SuccessRatio = float64(stat.Successes) / float64(stat.Rounds)
+
+	switch {
+	case stat.Successes == stat.Rounds:
+		stat.Class = StabilityStable
+	case stat.Successes == 0:
+		stat.Class = StabilityDown
+	default:
+		stat.Class = StabilityFlaky
+	}
+
+	if len(latencies) == 0 {
+		return stat
+	}
+
+	lo, hi, sum := latencies[0], latencies[0], 0.0
+	for _, l := range latencies {
+		lo = math.Min(lo, l)
+		hi = math.Max(hi, l)
+		sum += l
+	}
+	avg := sum / float64(len(latencies))
+
+	variance := 0.0
+	for _, l := range latencies {
+		variance += (l - avg) * (l - avg)
+	}
+	variance /= float64(len(latencies))
+
+	stat.LatencyMin = int(lo)
+	stat.LatencyMax = int(hi)
+	stat.LatencyAvg = int(math.Round(avg))
+	stat.LatencyStdev = int(math.Round(math.Sqrt(variance)))
+
+	return stat
+}
+
+// summarizeStability 统计每个服务在各节点上的稳定性分布，没有多轮数据时返回 nil
+func summarizeStability(results []models.NodeTestResult) map[string]models.StabilitySummary {
+	var summary map[string]models.StabilitySummary
+	ratioSum := make(map[string]float64)
+
+	for _, result := range results {
+		for name, stat := range result.Stability {
+			if summary == nil {
+				summary = make(map[string]models.StabilitySummary)
+			}
+			s := summary[name]
+			switch stat.Class {
+			case StabilityStable:
+				s.Stable++
+			case StabilityFlaky:
+				s.Flaky++
+			default:
+				s.Down++
+			}
+			summary[name] = s
+			ratioSum[name] += stat.SuccessRatio
+		}
+	}
+
+	for name, s := range summary {
+		if count := s.Stable + s.Flaky + s.Down; count > 0 {
+			s.AvgSuccessRatio = ratioSum[name] / float64(count)
+		}
+		summary[name] = s
+	}
+
+	return summary
+}
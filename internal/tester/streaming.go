@@ -2,6 +2,7 @@ package tester
 
 import (
 	"Clash-tester/pkg/models"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,11 +12,14 @@ import (
 )
 
 // TestStreamingService 测试流媒体服务
-func TestStreamingService(client *http.Client, serviceName string) models.StreamTest {
+func TestStreamingService(ctx context.Context, client *http.Client, serviceName string) models.StreamTest {
 	result := models.StreamTest{
-		Service: serviceName,
+		Service:  serviceName,
+		TestedAt: time.Now(),
 	}
 
+	resetTrace(client)
+	ctx, check := beginCheck(ctx, serviceName, 1)
 	start := time.Now()
 	var err error
 
@@ -24,18 +28,20 @@ func TestStreamingService(client *http.Client, serviceName string) models.Stream
 
 	switch serviceName {
 	case "netflix":
-		err = testNetflix(client, &result)
+		err = testNetflix(ctx, client, &result)
 	case "disney":
-		err = testDisney(client, &result)
+		err = testDisney(ctx, client, &result)
 	case "youtube":
-		err = testYoutube(client, &result)
+		err = testYoutube(ctx, client, &result)
 	case "max":
-		err = testMax(client, &result)
+		err = testMax(ctx, client, &result)
 	default:
 		err = fmt.Errorf("unknown service: %s", serviceName)
 	}
 
+	check.finish(err)
 	result.ResponseTime = int(time.Since(start).Milliseconds())
+	result.Timing = takeTrace(client)
 
 	if err == nil {
 		result.Available = true
@@ -48,24 +54,24 @@ func TestStreamingService(client *http.Client, serviceName string) models.Stream
 }
 
 // testNetflix Netflix 双 ID 检测法
-func testNetflix(client *http.Client, result *models.StreamTest) error {
+func testNetflix(ctx context.Context, client *http.Client, result *models.StreamTest) error {
 	// 1. Check Full Unlock (Breaking Bad - 非自制剧)
 	// 如果能看非自制剧，说明是完整解锁
-	if checkNetflixURL(client, "https://www.netflix.com/title/70143836", "Breaking Bad", result) {
+	if checkNetflixURL(ctx, client, endpoints(ctx).NetflixFull, "Breaking Bad", result) {
 		result.Details = "Full"
 		// 尝试提取地区
 		if result.Region == "" {
-			result.Region, _ = getCountryByIP(client) // Fallback
+			result.Region, _ = getCountryByIP(ctx, client) // Fallback
 		}
 		return nil
 	}
 
 	// 2. Check Originals (Squid Game - 自制剧)
 	// 如果只能看自制剧，说明是部分解锁
-	if checkNetflixURL(client, "https://www.netflix.com/title/81243996", "Squid Game", result) {
+	if checkNetflixURL(ctx, client, endpoints(ctx).NetflixOriginals, "Squid Game", result) {
 		result.Details = "Originals Only"
 		if result.Region == "" {
-			result.Region, _ = getCountryByIP(client) // Fallback
+			result.Region, _ = getCountryByIP(ctx, client) // Fallback
 		}
 		return nil
 	}
@@ -73,8 +79,8 @@ func testNetflix(client *http.Client, result *models.StreamTest) error {
 	return fmt.Errorf("blocked")
 }
 
-func checkNetflixURL(client *http.Client, url, keyword string, result *models.StreamTest) bool {
-	req, _ := http.NewRequest("GET", url, nil)
+func checkNetflixURL(ctx context.Context, client *http.Client, url, keyword string, result *models.StreamTest) bool {
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
 
 	resp, err := client.Do(req)
@@ -84,12 +90,14 @@ func checkNetflixURL(client *http.Client, url, keyword string, result *models.St
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
+		note(ctx, "%s: status %d", keyword, resp.StatusCode)
 		return false
 	}
 
 	// 检查重定向 (Response URL)
 	finalURL := resp.Request.URL.String()
 	if strings.Contains(finalURL, "/browse/genre/") || strings.Contains(finalURL, "NotAvailable") {
+		note(ctx, "%s: redirected to %s (genre page or NotAvailable)", keyword, finalURL)
 		return false
 	}
 
@@ -107,11 +115,13 @@ func checkNetflixURL(client *http.Client, url, keyword string, result *models.St
 		result.Region = matches[1]
 	}
 
-	return strings.Contains(bodyStr, keyword) || strings.Contains(bodyStr, "watch-video")
+	found := strings.Contains(bodyStr, keyword) || strings.Contains(bodyStr, "watch-video")
+	note(ctx, "%s: title page at %s, keyword %q or \"watch-video\" found: %v, current_country %q", keyword, finalURL, keyword, found, result.Region)
+	return found
 }
 
 
-func testDisney(client *http.Client, result *models.StreamTest) error {
+func testDisney(ctx context.Context, client *http.Client, result *models.StreamTest) error {
 	// 临时修改 Client 以拦截重定向
 	originalCheck := client.CheckRedirect
 	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
@@ -119,7 +129,7 @@ func testDisney(client *http.Client, result *models.StreamTest) error {
 	}
 	defer func() { client.CheckRedirect = originalCheck }()
 
-	req, _ := http.NewRequest("GET", "https://www.disneyplus.com/", nil)
+	req, _ := http.NewRequestWithContext(ctx, "GET", endpoints(ctx).Disney, nil)
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
 
 	resp, err := client.Do(req)
@@ -132,16 +142,18 @@ func testDisney(client *http.Client, result *models.StreamTest) error {
 	if resp.StatusCode == 302 || resp.StatusCode == 301 {
 		loc := resp.Header.Get("Location")
 		if strings.Contains(loc, "/preview") || strings.Contains(loc, "/unavailable") {
+			note(ctx, "redirect to %s contains /preview or /unavailable", loc)
 			return fmt.Errorf("redirected to preview/unavailable")
 		}
+		note(ctx, "redirect to %s is treated as available", loc)
 		// 跳转到 login 或 home 视为成功
-		result.Region, _ = getCountryByIP(client) // Disney+ 很难从 URL 直接看地区，用 IP 辅助
+		result.Region, _ = getCountryByIP(ctx, client) // Disney+ 很难从 URL 直接看地区，用 IP 辅助
 		return nil
 	}
 
 	// 如果直接 200 (极少见，通常都会重定向到本地化路径)
 	if resp.StatusCode == 200 {
-		result.Region, _ = getCountryByIP(client)
+		result.Region, _ = getCountryByIP(ctx, client)
 		return nil
 	}
 	
@@ -152,8 +164,8 @@ func testDisney(client *http.Client, result *models.StreamTest) error {
 	return fmt.Errorf("unexpected status: %d", resp.StatusCode)
 }
 
-func testYoutube(client *http.Client, result *models.StreamTest) error {
-	req, _ := http.NewRequest("GET", "https://www.youtube.com/", nil)
+func testYoutube(ctx context.Context, client *http.Client, result *models.StreamTest) error {
+	req, _ := http.NewRequestWithContext(ctx, "GET", endpoints(ctx).YouTube, nil)
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
 	// 设置 Cookie 可能会更准确，但这里先不需要
@@ -177,6 +189,7 @@ func testYoutube(client *http.Client, result *models.StreamTest) error {
 	matches := re.FindStringSubmatch(bodyStr)
 	if len(matches) > 1 {
 		result.Region = matches[1]
+		note(ctx, "region %q from \"countryCode\" in the page", result.Region)
 	} else {
 		// 备用匹配 "ISO_COUNTRY_CODE":"US"
 		re2 := regexp.MustCompile(`"ISO_COUNTRY_CODE":"(.*?)"`)
@@ -187,7 +200,7 @@ func testYoutube(client *http.Client, result *models.StreamTest) error {
 	}
 	
 	if result.Region == "" {
-		result.Region, _ = getCountryByIP(client)
+		result.Region, _ = getCountryByIP(ctx, client)
 	}
 
 	// Premium 检测 (简单版)
@@ -198,8 +211,8 @@ func testYoutube(client *http.Client, result *models.StreamTest) error {
 	return nil
 }
 
-func testMax(client *http.Client, result *models.StreamTest) error {
-	req, _ := http.NewRequest("GET", "https://www.max.com/", nil)
+func testMax(ctx context.Context, client *http.Client, result *models.StreamTest) error {
+	req, _ := http.NewRequestWithContext(ctx, "GET", endpoints(ctx).Max, nil)
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
 
 	resp, err := client.Do(req)
@@ -217,10 +230,11 @@ func testMax(client *http.Client, result *models.StreamTest) error {
 		bodyStr := string(body)
 		
 		if strings.Contains(bodyStr, "Not Available in your region") || strings.Contains(bodyStr, "GeoBlock") {
+			note(ctx, "page contains \"Not Available in your region\" or \"GeoBlock\"")
 			return fmt.Errorf("geo blocked")
 		}
 		
-		result.Region, _ = getCountryByIP(client)
+		result.Region, _ = getCountryByIP(ctx, client)
 		return nil
 	}
 	
@@ -0,0 +1,134 @@
+package tester
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"Clash-tester/pkg/models"
+)
+
+// tracingTransport 为每个请求挂载 httptrace，记录连接各阶段耗时
+type tracingTransport struct {
+	base http.RoundTripper
+
+	mu      sync.Mutex
+	timings []models.Timing // 自上次 reset 以来的请求耗时，按发起顺序
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt := &requestTrace{}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), rt.clientTrace()))
+
+	resp, err := recordRoundTrip(t.base, req, rt)
+
+	t.mu.Lock()
+	t.timings = append(t.timings, rt.timing())
+	t.mu.Unlock()
+
+	return resp, err
+}
+
+func (t *tracingTransport) reset() {
+	t.mu.Lock()
+	t.timings = nil
+	t.mu.Unlock()
+}
+
+// first 返回自上次 reset 以来的第一个请求 (即检测的主请求) 的耗时
+func (t *tracingTransport) first() *models.Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.timings) == 0 {
+		return nil
+	}
+	timing := t.timings[0]
+	return &timing
+}
+
+// resetTrace 在一次检测开始前清空客户端的耗时记录
+func resetTrace(client *http.Client) {
+	if t, ok := client.Transport.(*tracingTransport); ok {
+		t.reset()
+	}
+}
+
+// takeTrace 取出一次检测中主请求的耗时分解
+func takeTrace(client *http.Client) *models.Timing {
+	if t, ok := client.Transport.(*tracingTransport); ok {
+		return t.first()
+	}
+	return nil
+}
+
+// requestTrace 单个请求的 httptrace 时间点
+type requestTrace struct {
+	mu sync.Mutex
+
+	getConn, gotConn          time.Time
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	wroteRequest, firstByte   time.Time
+	reused                    bool
+}
+
+func (r *requestTrace) clientTrace() *httptrace.ClientTrace {
+	now := func(t *time.Time) {
+		r.mu.Lock()
+		*t = time.Now()
+		r.mu.Unlock()
+	}
+
+	return &httptrace.ClientTrace{
+		GetConn:  func(string) { now(&r.getConn) },
+		DNSStart: func(httptrace.DNSStartInfo) { now(&r.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { now(&r.dnsDone) },
+		ConnectStart: func(string, string) {
+			// 可能并发拨号多个地址，只记录第一次
+			r.mu.Lock()
+			if r.connectStart.IsZero() {
+				r.connectStart = time.Now()
+			}
+			r.mu.Unlock()
+		},
+		ConnectDone:       func(string, string, error) { now(&r.connectDone) },
+		TLSHandshakeStart: func() { now(&r.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { now(&r.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			r.mu.Lock()
+			r.gotConn = time.Now()
+			r.reused = info.Reused
+			r.mu.Unlock()
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { now(&r.wroteRequest) },
+		GotFirstResponseByte: func() { now(&r.firstByte) },
+	}
+}
+
+// timing 将时间点换算为各阶段耗时
+// 通过 HTTP 代理访问 HTTPS 时，TCP 连接的是本地代理，
+// 从 TCP 建连完成到 TLS 握手开始之间的时间即为代理 CONNECT 隧道的建立耗时
+func (r *requestTrace) timing() models.Timing {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return models.Timing{
+		DNS:          durationMs(r.dnsStart, r.dnsDone),
+		Connect:      durationMs(r.connectStart, r.connectDone),
+		ProxyConnect: durationMs(r.connectDone, r.tlsStart),
+		TLSHandshake: durationMs(r.tlsStart, r.tlsDone),
+		TTFB:         durationMs(r.wroteRequest, r.firstByte),
+		Total:        durationMs(r.getConn, r.firstByte),
+		Reused:       r.reused,
+	}
+}
+
+func durationMs(start, end time.Time) int {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return int(end.Sub(start).Milliseconds())
+}
@@ -0,0 +1,286 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+)
+
+const (
+	refreshInterval = 250 * time.Millisecond
+	recentResults   = 10 // 滚动结果表显示的行数
+	recentMessages  = 3  // 显示的最近日志条数
+)
+
+type workerStatus struct {
+	label string
+	since time.Time
+}
+
+// Dashboard 终端实时面板：进度条、ETA、各服务通过数、各 Worker 当前节点与滚动结果表
+// 运行期间 log 输出会被收集到面板的消息区，避免打乱画面
+type Dashboard struct {
+	mu     sync.Mutex
+	out    io.Writer
+	width  int
+	rows   int       // 终端行数，每帧最多占用 rows-1 行，避免末尾换行导致滚屏
+	logOut io.Writer // Start 之前的 log 输出，Stop 时恢复
+
+	total, done, initial int
+	started              time.Time
+	workers              []workerStatus
+	passed               map[string]int
+	success, failed      int
+	recent               []string
+	messages             []string // 面板中显示的最近日志
+	logs                 []string // 运行期间的全部日志，结束后输出
+
+	height  int // 上一帧的行数
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewDashboard rows 为 0 时不限制面板行数
+func NewDashboard(out io.Writer, width, rows int) *Dashboard {
+	return &Dashboard{
+		out:    out,
+		width:  width,
+		rows:   rows,
+		passed: make(map[string]int),
+	}
+}
+
+func (d *Dashboard) Start(total, workers int, done []models.NodeTestResult) {
+	d.mu.Lock()
+	d.total = total
+	d.done = len(done)
+	d.initial = len(done)
+	d.started = time.Now()
+	d.workers = make([]workerStatus, workers)
+	for _, r := range done {
+		d.count(r)
+	}
+	d.stop = make(chan struct{})
+	d.stopped = make(chan struct{})
+	d.mu.Unlock()
+
+	d.logOut = log.Writer()
+	log.SetOutput(logWriter{d})
+	fmt.Fprint(d.out, "\x1b[?25l") // 隐藏光标
+
+	go func() {
+		defer close(d.stopped)
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			d.draw()
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (d *Dashboard) NodeStarted(worker int, label string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if i := worker - 1; i >= 0 && i < len(d.workers) {
+		d.workers[i] = workerStatus{label: label, since: time.Now()}
+	}
+}
+
+func (d *Dashboard) NodeIdle(worker int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if i := worker - 1; i >= 0 && i < len(d.workers) {
+		d.workers[i] = workerStatus{}
+	}
+}
+
+func (d *Dashboard) NodeFinished(current, total int, result models.NodeTestResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.done = current
+	d.total = total
+	d.count(result)
+	d.recent = append(d.recent, resultLine(result, 24))
+	if len(d.recent) > recentResults {
+		d.recent = d.recent[len(d.recent)-recentResults:]
+	}
+}
+
+// Stop 绘制最后一帧，恢复光标与日志输出，并补发运行期间收集的日志
+func (d *Dashboard) Stop() {
+	if d.stop == nil {
+		return
+	}
+	close(d.stop)
+	<-d.stopped
+	d.draw()
+	fmt.Fprint(d.out, "\x1b[?25h")
+	log.SetOutput(d.logOut)
+	for _, line := range d.logs {
+		fmt.Fprintln(d.logOut, line)
+	}
+	d.logs = nil
+	d.stop = nil
+}
+
+func (d *Dashboard) count(r models.NodeTestResult) {
+	if tester.IsNodeSuccess(r) {
+		d.success++
+	} else {
+		d.failed++
+	}
+	for _, s := range tester.Services() {
+		if r.Tests[s].Available || r.StreamTests[s].Available {
+			d.passed[s]++
+		}
+	}
+}
+
+// draw 回到上一帧的起始位置并重绘
+// 每行都截断到终端宽度以内、总行数不超过终端高度，保证不会折行或滚屏，光标回退的行数才准确
+func (d *Dashboard) draw() {
+	d.mu.Lock()
+	lines := d.frame(time.Now(), d.rows-1)
+	d.mu.Unlock()
+
+	for len(lines) < d.height {
+		lines = append(lines, "")
+	}
+
+	var b bytes.Buffer
+	if d.height > 0 {
+		fmt.Fprintf(&b, "\x1b[%dF", d.height)
+	}
+	for _, line := range lines {
+		b.WriteString("\x1b[2K")
+		b.WriteString(Truncate(line, d.width-1))
+		b.WriteByte('\n')
+	}
+	d.height = len(lines)
+	d.out.Write(b.Bytes())
+}
+
+// frame 生成一帧，maxLines > 0 时限制行数
+// 超出时依次减少结果表、Worker 列表与消息区的行数，仍然超出则直接截掉末尾
+func (d *Dashboard) frame(now time.Time, maxLines int) []string {
+	var lines []string
+
+	// 进度条与 ETA
+	ratio := 0.0
+	if d.total > 0 {
+		ratio = float64(d.done) / float64(d.total)
+	}
+	barWidth := 30
+	filled := int(ratio * float64(barWidth))
+	elapsed := now.Sub(d.started).Round(time.Second)
+	eta := "--"
+	if tested := d.done - d.initial; tested > 0 && d.done < d.total {
+		remaining := time.Duration(float64(now.Sub(d.started)) / float64(tested) * float64(d.total-d.done))
+		eta = remaining.Round(time.Second).String()
+	}
+	lines = append(lines, fmt.Sprintf("🚀 [%s%s] %d/%d %5.1f%%  elapsed %s  ETA %s",
+		strings.Repeat("█", filled), strings.Repeat("░", barWidth-filled),
+		d.done, d.total, ratio*100, elapsed, eta))
+
+	// 各服务通过数
+	var passed []string
+	for _, s := range tester.Services() {
+		passed = append(passed, fmt.Sprintf("%s %d", tester.ServiceTitle(s), d.passed[s]))
+	}
+	lines = append(lines, fmt.Sprintf("   ✅ %d  ❌ %d  │ %s", d.success, d.failed, strings.Join(passed, "  ")))
+
+	// 各 Worker 当前节点
+	var workers []string
+	for i, w := range d.workers {
+		if w.label == "" {
+			workers = append(workers, fmt.Sprintf("  #%-2d idle", i+1))
+			continue
+		}
+		workers = append(workers, fmt.Sprintf("  #%-2d %s %s", i+1,
+			PadRight(sanitize(w.label), 36), now.Sub(w.since).Round(time.Second)))
+	}
+
+	// 滚动结果表，固定行数，避免画面跳动
+	recentRows := recentResults
+	messages := d.messages
+
+	if maxLines > 0 {
+		overhead := len(lines) + 4 // 两个空行与两个标题
+		if len(messages) > 0 {
+			overhead += 2
+		}
+		over := overhead + len(workers) + recentRows + len(messages) - maxLines
+		if over > 0 {
+			cut := min(over, recentRows)
+			recentRows -= cut
+			over -= cut
+		}
+		// Worker 过多时保留前面的行，其余合并为一行
+		if over > 0 && len(workers) > 1 {
+			shown := max(len(workers)-over-1, 0)
+			hidden := len(workers) - shown
+			over -= hidden - 1
+			workers = append(workers[:shown:shown], fmt.Sprintf("  … %d more", hidden))
+		}
+		if over > 0 {
+			messages = messages[min(over, len(messages)):]
+		}
+	}
+
+	lines = append(lines, "", "Workers")
+	lines = append(lines, workers...)
+
+	lines = append(lines, "", "Recent results")
+	recent := d.recent
+	if len(recent) > recentRows {
+		recent = recent[len(recent)-recentRows:]
+	}
+	for i := 0; i < recentRows; i++ {
+		if i < len(recent) {
+			lines = append(lines, "  "+recent[i])
+		} else {
+			lines = append(lines, "")
+		}
+	}
+
+	if len(messages) > 0 {
+		lines = append(lines, "", "Messages")
+		for _, m := range messages {
+			lines = append(lines, "  "+m)
+		}
+	}
+	if maxLines > 0 && len(lines) > maxLines {
+		lines = lines[:maxLines]
+	}
+	return lines
+}
+
+// logWriter 把 log 输出收集到面板的消息区
+type logWriter struct {
+	d *Dashboard
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.d.mu.Lock()
+	defer w.d.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.d.logs = append(w.d.logs, line)
+		w.d.messages = append(w.d.messages, sanitize(line))
+	}
+	if len(w.d.messages) > recentMessages {
+		w.d.messages = w.d.messages[len(w.d.messages)-recentMessages:]
+	}
+	return len(p), nil
+}
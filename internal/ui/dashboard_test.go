@@ -0,0 +1,85 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"Clash-tester/pkg/models"
+)
+
+func TestDashboardFrameFitsTerminal(t *testing.T) {
+	now := time.Now()
+	d := NewDashboard(&bytes.Buffer{}, 80, 0)
+	d.started = now
+	d.total = 100
+	d.workers = make([]workerStatus, 16)
+	for i := range d.workers {
+		d.workers[i] = workerStatus{label: fmt.Sprintf("🇭🇰 香港 %02d", i+1), since: now}
+	}
+	for i := 0; i < recentResults; i++ {
+		d.recent = append(d.recent, fmt.Sprintf("result %d", i))
+	}
+	d.messages = []string{"a", "b", "c"}
+
+	full := d.frame(now, 0)
+	if want := 2 + 2 + 16 + 2 + recentResults + 2 + 3; len(full) != want {
+		t.Fatalf("unlimited frame has %d lines, want %d", len(full), want)
+	}
+
+	for _, maxLines := range []int{40, 30, 20, 10, 3, 1} {
+		lines := d.frame(now, maxLines)
+		if len(lines) > maxLines {
+			t.Errorf("maxLines %d: frame has %d lines", maxLines, len(lines))
+		}
+		if !strings.HasPrefix(lines[0], "🚀") {
+			t.Errorf("maxLines %d: progress line dropped: %q", maxLines, lines[0])
+		}
+	}
+
+	// 结果表先被压缩，保留最新的结果
+	lines := strings.Join(d.frame(now, 30), "\n")
+	if !strings.Contains(lines, "result 9") || strings.Contains(lines, "result 0") {
+		t.Errorf("recent results not trimmed from the oldest:\n%s", lines)
+	}
+	// 之后 Worker 合并为一行
+	lines = strings.Join(d.frame(now, 20), "\n")
+	if !strings.Contains(lines, "more") || strings.Contains(lines, "Recent results\n  result") {
+		t.Errorf("workers not collapsed after recent results:\n%s", lines)
+	}
+}
+
+func TestDashboardStopRestoresLogOutput(t *testing.T) {
+	prevOut, prevFlags := log.Writer(), log.Flags()
+	t.Cleanup(func() {
+		log.SetOutput(prevOut)
+		log.SetFlags(prevFlags)
+	})
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	log.SetFlags(0)
+
+	var screen bytes.Buffer
+	d := NewDashboard(&screen, 80, 24)
+	d.Start(1, 1, nil)
+	log.Print("during run")
+	if logs.Len() != 0 {
+		t.Fatalf("log written through while the dashboard is running: %q", logs.String())
+	}
+	d.NodeFinished(1, 1, models.NodeTestResult{NodeName: "🇯🇵 東京 01"})
+	d.Stop()
+
+	if log.Writer() != &logs {
+		t.Fatal("log output not restored to the previous writer")
+	}
+	log.Print("after run")
+	if got := logs.String(); got != "during run\nafter run\n" {
+		t.Errorf("logs = %q", got)
+	}
+	if !strings.Contains(screen.String(), "during run") {
+		t.Error("log line missing from the dashboard messages")
+	}
+}
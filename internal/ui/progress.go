@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+)
+
+// 显示模式
+const (
+	ModeAuto  = "auto"  // 终端中使用实时面板，否则逐行输出
+	ModeTUI   = "tui"   // 实时面板
+	ModePlain = "plain" // 每个节点完成时输出一行
+)
+
+// Progress 测试过程中的进度显示
+// NodeStarted / NodeIdle 由各 Worker 的 goroutine 调用，需要并发安全
+type Progress interface {
+	// Start 开始显示，done 为续测或增量模式下已有的结果
+	Start(total, workers int, done []models.NodeTestResult)
+	NodeStarted(worker int, label string)
+	NodeIdle(worker int)
+	NodeFinished(current, total int, result models.NodeTestResult)
+	Stop()
+}
+
+// New 按模式创建进度显示，输出到 stdout
+func New(mode string) (Progress, error) {
+	switch mode {
+	case ModeAuto, "":
+		if supportsANSI(os.Stdout) {
+			return NewDashboard(os.Stdout, terminalWidth(os.Stdout), terminalHeight(os.Stdout)), nil
+		}
+		return NewPlain(os.Stdout), nil
+	case ModeTUI:
+		return NewDashboard(os.Stdout, terminalWidth(os.Stdout), terminalHeight(os.Stdout)), nil
+	case ModePlain:
+		return NewPlain(os.Stdout), nil
+	}
+	return nil, fmt.Errorf("unknown ui mode %q (supported: auto, tui, plain)", mode)
+}
+
+// Plain 每个节点完成时输出一行，适合日志与非终端环境
+type Plain struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func NewPlain(out io.Writer) *Plain {
+	return &Plain{out: out}
+}
+
+func (p *Plain) Start(total, workers int, done []models.NodeTestResult) {}
+func (p *Plain) NodeStarted(worker int, label string)                   {}
+func (p *Plain) NodeIdle(worker int)                                    {}
+func (p *Plain) Stop()                                                  {}
+
+func (p *Plain) NodeFinished(current, total int, result models.NodeTestResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.out, "[%3d/%d] %s\n", current, total, resultLine(result, 20))
+}
+
+// resultLine 单个节点结果的简短描述，节点名按显示宽度对齐
+func resultLine(result models.NodeTestResult, nameWidth int) string {
+	status := "❌"
+	if tester.IsNodeSuccess(result) {
+		status = "✅"
+	}
+
+	openai := serviceShort(result.Tests["openai"])
+	netflix := streamShort(result.StreamTests["netflix"])
+	disney := streamShort(result.StreamTests["disney"])
+
+	speed := ""
+	if result.SpeedTest != nil {
+		speed = " " + speedShort(*result.SpeedTest)
+	}
+
+	return fmt.Sprintf("%s %s (Chat:%s NF:%s D+:%s)%s",
+		status, PadRight(sanitize(result.NodeName), nameWidth), openai, netflix, disney, speed)
+}
+
+func speedShort(test models.SpeedTest) string {
+	if !test.Available {
+		return "DL:✗"
+	}
+	return fmt.Sprintf("DL:%.1fMbps", test.DownloadMbps)
+}
+
+func serviceShort(test models.ServiceTest) string {
+	if !test.Available {
+		return "✗"
+	}
+	if test.Country != "" {
+		return test.Country
+	}
+	return "✓"
+}
+
+func streamShort(test models.StreamTest) string {
+	if !test.Available {
+		return "✗"
+	}
+	if test.Region != "" {
+		return test.Region
+	}
+	return "✓"
+}
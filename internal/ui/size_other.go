@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd
+
+package ui
+
+import "os"
+
+// termSize 其他平台不查询终端尺寸，由 terminalWidth / terminalHeight 回退到默认值
+func termSize(f *os.File) (cols, rows int) {
+	return 0, 0
+}
@@ -0,0 +1,21 @@
+//go:build linux || darwin || freebsd
+
+package ui
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// termSize 通过 TIOCGWINSZ 获取终端列数与行数
+func termSize(f *os.File) (cols, rows int) {
+	var ws struct {
+		Row, Col, X, Y uint16
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 {
+		return 0, 0
+	}
+	return int(ws.Col), int(ws.Row)
+}
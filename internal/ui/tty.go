@@ -0,0 +1,47 @@
+package ui
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+)
+
+// IsTerminal 判断 f 是否连接到终端
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// supportsANSI 终端是否支持光标移动等 ANSI 控制序列
+func supportsANSI(f *os.File) bool {
+	if !IsTerminal(f) || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	// Windows 控制台需要额外开启虚拟终端支持，这里保守地退回普通输出
+	return runtime.GOOS != "windows"
+}
+
+// terminalWidth 返回终端列数，无法获取时使用 COLUMNS 环境变量或 100
+func terminalWidth(f *os.File) int {
+	if w, _ := termSize(f); w > 0 {
+		return w
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return w
+	}
+	return 100
+}
+
+// terminalHeight 返回终端行数，无法获取时使用 LINES 环境变量或 24
+func terminalHeight(f *os.File) int {
+	if _, h := termSize(f); h > 0 {
+		return h
+	}
+	if h, err := strconv.Atoi(os.Getenv("LINES")); err == nil && h > 0 {
+		return h
+	}
+	return 24
+}
@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestStringWidth(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"HK 01", 5},
+		{"香港 01", 7},
+		{"🇭🇰 香港 01", 10},
+		{"✅ ❌", 5},
+		{"🚀", 2},
+		{"é", 1},
+		{"日本\tTokyo", 9},
+	}
+	for _, tt := range tests {
+		if got := StringWidth(tt.s); got != tt.want {
+			t.Errorf("StringWidth(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"HK 01", 5, "HK 01"},
+		{"HK 01", 4, "HK …"},
+		{"香港节点", 5, "香港…"},
+		{"香港节点", 4, "香…"},
+		{"香港", 2, "…"},
+		{"🇭🇰 香港 01", 4, "🇭🇰 …"},
+		{"🚀🚀🚀", 5, "🚀🚀…"},
+		{"香港", 0, ""},
+	}
+	for _, tt := range tests {
+		got := Truncate(tt.s, tt.width)
+		if got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+		if !utf8.ValidString(got) || StringWidth(got) > tt.width {
+			t.Errorf("Truncate(%q, %d) = %q: width %d", tt.s, tt.width, got, StringWidth(got))
+		}
+	}
+}
+
+func TestPadRight(t *testing.T) {
+	for _, s := range []string{"HK", "香港节点", "🇯🇵 東京 01 超长的节点名称"} {
+		if got := PadRight(s, 10); StringWidth(got) != 10 {
+			t.Errorf("PadRight(%q, 10) = %q: width %d", s, got, StringWidth(got))
+		}
+	}
+}
@@ -0,0 +1,7 @@
+// Package version 构建版本，发布构建时通过 ldflags 注入：
+//
+//	go build -ldflags "-X Clash-tester/internal/version.Version=v1.4.0" -o clash-tester ./cmd
+package version
+
+// Version 未注入时为 dev
+var Version = "dev"
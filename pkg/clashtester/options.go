@@ -0,0 +1,198 @@
+// Package clashtester 对外提供的测试库：加载订阅、管理代理核心、并发检测各项服务并汇总报告
+// 命令行工具 clash-tester 也构建在这个包之上
+//
+//	runner, err := clashtester.New(clashtester.Options{
+//		Source:     "https://example.com/sub",
+//		MihomoPath: "/usr/local/bin/mihomo",
+//		OnResult: func(r models.NodeTestResult) {
+//			fmt.Println(r.NodeName, r.Tests["openai"].Available)
+//		},
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	report, err := runner.Run(ctx)
+package clashtester
+
+import (
+	"crypto/x509"
+	"io"
+	"log"
+	"time"
+
+	"Clash-tester/internal/proxy"
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+)
+
+// Options 一次测试的全部参数，零值字段使用默认值
+type Options struct {
+	// Source 订阅 URL 或本地 YAML 文件路径；Nodes 非空时只用作报告中的来源标识
+	Source        string
+	SourceTimeout time.Duration // 下载订阅的超时，默认 30s
+	// Nodes 直接指定要测试的节点，非空时不再加载 Source
+	Nodes  []models.ProxyNode
+	Filter Filter
+
+	Core       string // 代理核心：mihomo (默认) / sing-box / xray，用于区分节点故障与核心兼容性问题
+	CorePath   string // 代理核心可执行文件路径，为空时 mihomo 使用 MihomoPath，其他核心在 PATH 中查找
+	MihomoPath string // mihomo 可执行文件路径，默认 mihomo.exe
+	// NewCore 自定义代理核心，非 nil 时忽略 Core、CorePath 与 MihomoPath (测试时使用假核心)
+	NewCore     func(CoreConfig) (Core, error)
+	Workers     int // 并发 Worker (代理核心) 数，默认 5
+	PortBase    int // 第 i 个 Worker 的代理端口为 PortBase + i*PortStep，0 表示自动选择空闲端口
+	PortStep    int // 默认 10
+	APIPortBase int // 第 i 个 Worker 的控制端口为 APIPortBase + i，0 表示自动选择空闲端口
+
+	RunTimeout    time.Duration // 整次运行的时间上限，0 表示不限
+	NodeTimeout   time.Duration // 单个节点的时间预算，0 表示不限
+	ShutdownGrace time.Duration // Run 的 ctx 取消后，进行中节点的宽限时间，默认 15s
+	Rounds        int           // 每个节点重复测试的轮数，默认 1
+	RoundWindow   time.Duration // 多轮测试分布的时间窗口
+
+	Checks CheckOptions
+	Canary CanaryOptions
+	Gate   GateOptions
+
+	Checkpoint string // 检查点文件，为空表示不写检查点
+	Resume     bool   // 从检查点续测
+
+	StatePath  string                   // 增量测试状态文件，为空表示每次全量测试
+	TTL        map[string]time.Duration // 各服务结果的有效期
+	DefaultTTL time.Duration            // TTL 中未列出的服务的有效期
+	// UnavailableTTL 不可用结果的有效期 (不超过该服务的有效期)，0 表示每次都重新检测
+	UnavailableTTL time.Duration
+
+	// OnResult 每个节点得到最终结果时调用 (含续测恢复与增量沿用的结果)，在同一个 goroutine 中按完成顺序调用
+	OnResult func(models.NodeTestResult)
+	// Progress 进度显示，nil 表示不显示
+	Progress Progress
+	// Output 运行过程的提示信息，nil 表示不输出
+	Output io.Writer
+	// Logger 警告信息，nil 表示不输出
+	Logger *log.Logger
+}
+
+// Core 代理核心，见 Options.NewCore
+type Core = proxy.Core
+
+// CoreConfig 创建代理核心的参数：端口、生成配置的路径与全部待测节点
+type CoreConfig = proxy.Options
+
+// Endpoints 各项检测访问的地址
+type Endpoints = tester.Endpoints
+
+// DefaultEndpoints 真实服务的地址
+var DefaultEndpoints = tester.DefaultEndpoints
+
+// Recorder 记录检测过程中的全部 HTTP 交互与判定依据，零值即可使用，见 CheckOptions.Recorder
+type Recorder = tester.Recorder
+
+// CheckRecord 一次检测 (某服务的某次尝试) 的完整记录，由 Recorder.Checks 返回
+type CheckRecord = tester.CheckRecord
+
+// Filter 节点过滤，先按 Names 与 Include 保留，再按 Exclude 排除
+type Filter struct {
+	Names   []string // 只保留这些名称的节点 (精确匹配)
+	Include string   // 节点名称正则
+	Exclude string   // 节点名称正则
+	Types   []string // 只保留这些协议类型
+}
+
+// CheckOptions 检测项与请求参数
+type CheckOptions struct {
+	Services  []string      // 启用的检测项 (openai/gemini/claude/netflix/disney/youtube/max)，为空表示全部
+	Timeout   time.Duration // 单次请求超时，默认 10s
+	Retries   *int          // AI 服务失败后的重试次数，nil 表示默认 2 次
+	SpeedTest SpeedTestOptions
+	HARDir    string // 将失败检测的 HTTP 交互保存为 HAR 的目录，为空表示不保存
+
+	// Endpoints 检测与自检访问的地址，nil 表示真实服务；RootCAs 校验这些站点证书的根证书，nil 表示系统根证书
+	Endpoints *Endpoints
+	RootCAs   *x509.CertPool
+
+	// Recorder 记录全部 HTTP 交互与判定依据 (如单节点排查)，nil 表示不记录
+	Recorder *Recorder
+}
+
+// SpeedTestOptions 下载测速，URL 为空表示不测速
+type SpeedTestOptions struct {
+	URL          string
+	MaxBytes     int64
+	MaxDuration  time.Duration
+	OnlyUnlocked bool // 只对通过至少一项解锁检测的节点测速
+}
+
+// CanaryOptions 测试前后的本机连通性自检
+type CanaryOptions struct {
+	Enabled        bool
+	ExtraURL       string // 额外的直连探测地址
+	ReferenceProxy string // 已知可用的参考代理
+}
+
+// GateOptions 质量检查，未通过时 TestReport.GateFailures 非空
+type GateOptions struct {
+	MinTestedRatio  float64
+	MinSuccessNodes int
+}
+
+// Progress 测试过程中的进度显示
+// NodeStarted / NodeIdle 由各 Worker 的 goroutine 调用，需要并发安全
+type Progress interface {
+	// Start 开始显示，done 为续测或增量模式下已有的结果
+	Start(total, workers int, done []models.NodeTestResult)
+	NodeStarted(worker int, label string)
+	NodeIdle(worker int)
+	NodeFinished(current, total int, result models.NodeTestResult)
+	Stop()
+}
+
+// withDefaults 补全零值字段
+func (o Options) withDefaults() Options {
+	if o.SourceTimeout <= 0 {
+		o.SourceTimeout = 30 * time.Second
+	}
+	if o.Core == "" {
+		o.Core = proxy.KindMihomo
+	}
+	if o.MihomoPath == "" {
+		o.MihomoPath = "mihomo.exe"
+	}
+	if o.Workers <= 0 {
+		o.Workers = 5
+	}
+	if o.PortStep <= 0 {
+		o.PortStep = 10
+	}
+	if o.ShutdownGrace <= 0 {
+		o.ShutdownGrace = 15 * time.Second
+	}
+	if o.Rounds < 1 {
+		o.Rounds = 1
+	}
+	if o.Output == nil {
+		o.Output = io.Discard
+	}
+	if o.Logger == nil {
+		o.Logger = log.New(io.Discard, "", 0)
+	}
+	return o
+}
+
+// testerOptions 转换为单节点检测的参数
+func (c CheckOptions) testerOptions() tester.Options {
+	return tester.Options{
+		SpeedTest: tester.SpeedTestConfig{
+			URL:          c.SpeedTest.URL,
+			MaxBytes:     c.SpeedTest.MaxBytes,
+			MaxDuration:  c.SpeedTest.MaxDuration,
+			OnlyUnlocked: c.SpeedTest.OnlyUnlocked,
+		},
+		Services:  c.Services,
+		Retries:   c.Retries,
+		Timeout:   c.Timeout,
+		Recorder:  c.Recorder,
+		Endpoints: c.Endpoints,
+		RootCAs:   c.RootCAs,
+	}
+}
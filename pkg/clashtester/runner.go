@@ -0,0 +1,549 @@
+package clashtester
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"Clash-tester/internal/checkpoint"
+	"Clash-tester/internal/config"
+	"Clash-tester/internal/gate"
+	"Clash-tester/internal/parser"
+	"Clash-tester/internal/proxy"
+	"Clash-tester/internal/reporter"
+	"Clash-tester/internal/state"
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+)
+
+// 可用 errors.Is 判断的错误类型
+var (
+	ErrSourceUnreachable = errors.New("subscription source unreachable") // 订阅无法获取、解析或没有受支持的节点
+	ErrCoreStartup       = errors.New("proxy core failed to start")      // 代理核心启动失败
+	ErrGlobalOutage      = errors.New("global outage")                   // 测试前自检发现本机网络故障，未进行测试
+)
+
+// kindError 保留原始错误信息，同时可用 errors.Is 判断错误类型
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// Runner 执行一次完整的测试
+// 同一个 Runner 可以多次调用 Run，但不能并发调用
+type Runner struct {
+	opts Options
+
+	mu    sync.Mutex
+	abort context.CancelFunc
+	nodes []models.ProxyNode
+}
+
+// New 创建 Runner，检查参数并补全默认值
+func New(opts Options) (*Runner, error) {
+	opts = opts.withDefaults()
+	if opts.Source == "" && len(opts.Nodes) == 0 {
+		return nil, errors.New("either Source or Nodes must be set")
+	}
+	if !containsString(proxy.Kinds, opts.Core) {
+		return nil, fmt.Errorf("unknown core %q (supported: %s)", opts.Core, strings.Join(proxy.Kinds, ", "))
+	}
+	if _, err := opts.Filter.settings().Filter(nil); err != nil {
+		return nil, err
+	}
+	return &Runner{opts: opts}, nil
+}
+
+// Abort 立即取消进行中的节点，不再等待 ShutdownGrace
+func (r *Runner) Abort() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.abort != nil {
+		r.abort()
+	}
+}
+
+// Nodes 返回上一次 Run 中经过筛选、参与测试的全部节点
+func (r *Runner) Nodes() []models.ProxyNode {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.nodes
+}
+
+// logf 输出运行过程的提示信息
+func (r *Runner) logf(format string, args ...any) {
+	fmt.Fprintf(r.opts.Output, format, args...)
+}
+
+// Run 执行测试并返回报告
+//
+// ctx 取消后不再派发新节点，进行中的节点在 ShutdownGrace 内继续完成，之后被取消；
+// 此时报告标记为不完整，IncompleteReason 为 context.Cause(ctx)。
+// 未通过质量检查不视为错误，见 TestReport.GateFailures。
+// 测试前自检发现本机故障时同时返回报告与 ErrGlobalOutage。
+func (r *Runner) Run(ctx context.Context) (*models.TestReport, error) {
+	opts := r.opts
+
+	// 运行上下文：超过 RunTimeout 或被中止时取消，进行中的检测随之中止
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	if opts.RunTimeout > 0 {
+		runCtx, cancelRun = context.WithTimeout(context.Background(), opts.RunTimeout)
+	}
+	defer cancelRun()
+	r.mu.Lock()
+	r.abort = cancelRun
+	r.mu.Unlock()
+
+	// 派发上下文：ctx 取消后不再派发新节点，宽限期结束后取消进行中的节点
+	dispatchCtx, stopDispatch := context.WithCancel(runCtx)
+	defer stopDispatch()
+	if ctx.Err() != nil {
+		stopDispatch()
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			stopDispatch()
+		case <-runCtx.Done():
+			return
+		}
+		select {
+		case <-time.After(opts.ShutdownGrace):
+		case <-runCtx.Done():
+		}
+		cancelRun()
+	}()
+
+	// 0. 自检：本机网络异常时所有节点都会失败，没有必要继续测试
+	canaryConfig := tester.CanaryConfig{
+		Enabled:        opts.Canary.Enabled,
+		ExtraURL:       opts.Canary.ExtraURL,
+		ReferenceProxy: opts.Canary.ReferenceProxy,
+		Endpoints:      opts.Checks.Endpoints,
+	}
+	var canaryReport *models.CanaryReport
+	if canaryConfig.Enabled {
+		r.logf("🐤 Checking tester connectivity...\n")
+		before := tester.RunCanary(dispatchCtx, canaryConfig)
+		reporter.PrintCanary(opts.Output, "before", before)
+		canaryReport = &models.CanaryReport{Before: &before}
+
+		if before.Outage {
+			canaryReport.GlobalOutage = true
+			canaryReport.Reason = before.Reason
+			report := models.TestReport{
+				TestTime:     time.Now(),
+				Source:       opts.Source,
+				Canary:       canaryReport,
+				GateFailures: []string{"global outage: " + before.Reason},
+			}
+			return &report, &kindError{ErrGlobalOutage, fmt.Errorf("global outage: %s", before.Reason)}
+		}
+	}
+
+	// 1. 加载并解析订阅
+	nodes := opts.Nodes
+	if len(nodes) == 0 {
+		r.logf("📥 Loading configuration from: %s\n", opts.Source)
+		var err error
+		nodes, err = LoadNodes(dispatchCtx, opts.Source, opts.SourceTimeout)
+		if err != nil {
+			return nil, err
+		}
+		r.logf("✅ Found %d supported nodes\n\n", len(nodes))
+	}
+
+	if len(nodes) == 0 {
+		return nil, &kindError{ErrSourceUnreachable, errors.New("no supported nodes found")}
+	}
+
+	// 按 Filter 筛选节点
+	found := len(nodes)
+	nodes, err := opts.Filter.Apply(nodes)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) != found {
+		r.logf("🔎 Filter kept %d of %d nodes\n\n", len(nodes), found)
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("no nodes left after applying the filter")
+	}
+
+	// 核心按名称切换节点，重名节点只能测到第一个，其余的不参与测试
+	nodes, duplicates := dropDuplicateNames(nodes)
+	for _, name := range duplicates {
+		opts.Logger.Printf("⚠️  Node name %q is used more than once, only the first node with this name is tested", name)
+	}
+
+	r.mu.Lock()
+	r.nodes = nodes
+	r.mu.Unlock()
+
+	report := models.TestReport{
+		TestTime:   time.Now(),
+		Source:     opts.Source,
+		Core:       opts.Core,
+		TotalNodes: len(nodes),
+		Results:    make([]models.NodeTestResult, 0, len(nodes)),
+		Canary:     canaryReport,
+	}
+
+	// 续测：恢复检查点中的结果，只测试剩余节点
+	// 订阅源或筛选条件不同的检查点直接丢弃，避免混入另一组节点的结果
+	pending := nodes
+	resume := opts.Resume
+	if resume && opts.Checkpoint != "" {
+		restored, remaining, testTime, err := restoreCheckpoint(opts.Checkpoint, opts.Source, opts.Filter.key(), nodes)
+		switch {
+		case errors.Is(err, errCheckpointMismatch):
+			opts.Logger.Printf("⚠️  Discarding checkpoint %s: %v", opts.Checkpoint, err)
+			resume = false
+		case err != nil:
+			return nil, fmt.Errorf("failed to load checkpoint: %w", err)
+		case len(restored) > 0:
+			r.logf("♻️  Resuming from %s: %d nodes already tested, %d remaining\n\n",
+				opts.Checkpoint, len(restored), len(remaining))
+			report.TestTime = testTime
+			report.Results = append(report.Results, restored...)
+			pending = remaining
+		}
+	}
+
+	var ckpt *checkpoint.Writer
+	if opts.Checkpoint != "" {
+		ckpt, err = checkpoint.Create(opts.Checkpoint, checkpoint.Header{
+			TestTime: report.TestTime,
+			Source:   opts.Source,
+			Filter:   opts.Filter.key(),
+		}, resume)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create checkpoint: %w", err)
+		}
+		defer ckpt.Close()
+	}
+	appendCheckpoint := func(result models.NodeTestResult) {
+		if ckpt == nil {
+			return
+		}
+		if err := ckpt.Append(result); err != nil {
+			opts.Logger.Printf("⚠️  Failed to write checkpoint: %v", err)
+		}
+	}
+
+	// 增量测试：结果仍在有效期内的服务不再重新检测
+	var st *state.State
+	jobList := make([]nodeJob, 0, len(pending))
+	if opts.StatePath != "" {
+		st, err = state.Load(opts.StatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load state: %w", err)
+		}
+
+		ttls := state.TTLConfig{Default: opts.DefaultTTL, Services: opts.TTL, Unavailable: opts.UnavailableTTL}
+		now := time.Now()
+		for _, node := range pending {
+			services, prev := st.Plan(node, opts.Checks.Services, ttls, now)
+			if prev != nil && len(services) == 0 {
+				// 全部结果仍新鲜，直接沿用
+				report.Results = append(report.Results, *prev)
+				report.CachedNodes++
+				appendCheckpoint(*prev)
+				continue
+			}
+			jobList = append(jobList, nodeJob{Node: node, Services: services, Prev: prev})
+		}
+		r.logf("🗂️  Incremental mode: %d nodes still fresh, %d to test\n\n", report.CachedNodes, len(jobList))
+	} else {
+		for _, node := range pending {
+			jobList = append(jobList, nodeJob{Node: node})
+		}
+	}
+
+	if opts.OnResult != nil {
+		for _, result := range report.Results {
+			opts.OnResult(result)
+		}
+	}
+
+	// 2. 初始化 Workers (没有需要测试的节点时无需启动)
+	workersCount := opts.Workers
+	if len(jobList) == 0 {
+		workersCount = 0
+	}
+	r.logf("🚀 Starting %d %s workers...\n", workersCount, opts.Core)
+	workers := make([]*worker, 0, workersCount)
+
+	// 临时配置放在每次运行独立的目录中，同一台机器上的多个 Runner 互不覆盖
+	workDir, err := os.MkdirTemp("", "clash-tester-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create work directory: %w", err)
+	}
+	ports, err := r.allocatePorts(workersCount)
+	if err != nil {
+		os.RemoveAll(workDir)
+		return nil, fmt.Errorf("failed to allocate worker ports: %w", err)
+	}
+
+	// 确保所有核心和临时文件最终都被清理
+	defer func() {
+		r.logf("\n🧹 Cleaning up resources...\n")
+		for _, w := range workers {
+			w.close()
+		}
+		os.RemoveAll(workDir)
+	}()
+
+	for i := 0; i < workersCount; i++ {
+		w, err := r.startWorker(dispatchCtx, i, nodes, workDir, ports)
+		if w != nil {
+			workers = append(workers, w)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r.logf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+
+	// 3. 并发测试
+	// 多轮模式下，同一节点的相邻两轮之间间隔 roundSpacing，期间 Worker 去测试其他节点
+	rounds := opts.Rounds
+	var roundSpacing time.Duration
+	if rounds > 1 {
+		roundSpacing = opts.RoundWindow / time.Duration(rounds-1)
+	}
+
+	// 通道定义：每个节点同一时间最多只有一个待执行的任务
+	jobs := make(chan nodeJob, len(jobList))
+	results := make(chan jobResult, len(jobList))
+	var wg sync.WaitGroup
+
+	progress := opts.Progress
+	if progress == nil {
+		progress = noProgress{}
+	}
+	progress.Start(len(nodes), len(workers), report.Results)
+	defer progress.Stop()
+
+	// 启动 Worker Goroutines
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			for job := range jobs {
+				// 已停止派发，剩余任务直接交回，不再测试
+				if dispatchCtx.Err() != nil {
+					results <- jobResult{Job: job}
+					continue
+				}
+				label := job.Node.Name
+				if rounds > 1 {
+					label = fmt.Sprintf("%s (round %d/%d)", label, job.Round, rounds)
+				}
+				progress.NodeStarted(w.id, label)
+				result, ok := r.testWithWorker(runCtx, w, job)
+				progress.NodeIdle(w.id)
+				if ok {
+					w.tested++
+				}
+				results <- jobResult{Job: job, Result: result, OK: ok}
+			}
+		}(w)
+	}
+
+	// 投递第一轮任务
+	for i := range jobList {
+		jobList[i].Round = 1
+		jobList[i].Index = i
+		jobs <- jobList[i]
+	}
+
+	// 4. 收集结果
+	processedCount := len(report.Results)
+	nodeRounds := make([][]models.NodeTestResult, len(jobList))
+	for remaining := len(jobList); remaining > 0; {
+		jr := <-results
+		job := jr.Job
+		if jr.OK {
+			nodeRounds[job.Index] = append(nodeRounds[job.Index], jr.Result)
+		}
+
+		// 还有后续轮次：间隔一段时间后重新投递 (停止派发时立即投递，由 Worker 直接交回)
+		if job.Round < rounds && dispatchCtx.Err() == nil {
+			next := job
+			next.Round++
+			go func() {
+				select {
+				case <-time.After(roundSpacing):
+				case <-dispatchCtx.Done():
+				}
+				jobs <- next
+			}()
+			continue
+		}
+
+		// 该节点的全部轮次已结束
+		remaining--
+		if len(nodeRounds[job.Index]) == 0 {
+			continue
+		}
+		result := state.Merge(tester.AggregateRounds(nodeRounds[job.Index]), job.Prev)
+
+		processedCount++
+		report.Results = append(report.Results, result)
+
+		// 写入检查点，崩溃后可用 Resume 继续
+		appendCheckpoint(result)
+		if opts.OnResult != nil {
+			opts.OnResult(result)
+		}
+
+		progress.NodeFinished(processedCount, len(nodes), result)
+	}
+
+	// 全部任务结束，Worker 退出
+	close(jobs)
+	wg.Wait()
+	progress.Stop()
+
+	for _, w := range workers {
+		report.Workers = append(report.Workers, w.stats())
+	}
+
+	for _, result := range report.Results {
+		report.TestedNodes++
+		if tester.IsNodeSuccess(result) {
+			report.SuccessNodes++
+		}
+	}
+
+	r.logf("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+
+	// 被中断或超时：仍然输出已完成部分，并标记为不完整
+	if report.TestedNodes < report.TotalNodes {
+		if ctx.Err() != nil {
+			report.Incomplete = true
+			report.IncompleteReason = context.Cause(ctx).Error()
+		} else if runCtx.Err() == context.DeadlineExceeded {
+			report.Incomplete = true
+			report.IncompleteReason = fmt.Sprintf("run timeout (%s) exceeded", opts.RunTimeout)
+		}
+	}
+
+	// 测试结束后再次自检，区分测试期间发生的本机故障与节点故障
+	if report.Canary != nil {
+		if ctx.Err() == nil && runCtx.Err() == nil {
+			after := tester.RunCanary(runCtx, canaryConfig)
+			reporter.PrintCanary(opts.Output, "after", after)
+			report.Canary.After = &after
+		}
+		report.Canary.GlobalOutage, report.Canary.Reason = tester.DetectGlobalOutage(report)
+	}
+
+	// 5. 生成摘要
+	report.Summary = tester.GenerateSummary(report.Results)
+	report.GateFailures = gate.Evaluate(report, gate.Config{
+		MinTestedRatio:  opts.Gate.MinTestedRatio,
+		MinSuccessNodes: opts.Gate.MinSuccessNodes,
+	})
+
+	// 更新增量测试状态 (中断时也保存已完成的部分)
+	// 全局故障时的失败结果不可信，不写入状态，下次全部重新测试
+	if st != nil && (report.Canary == nil || !report.Canary.GlobalOutage) {
+		st.Update(report.Results, nodes)
+		if err := st.Save(opts.StatePath); err != nil {
+			opts.Logger.Printf("⚠️  Failed to save state: %v", err)
+		}
+	}
+
+	// 运行完整结束且通过质量检查，检查点不再需要；否则保留以便续测
+	if ckpt != nil && !report.Incomplete && len(report.GateFailures) == 0 {
+		ckpt.Close()
+		os.Remove(opts.Checkpoint)
+	}
+
+	return &report, nil
+}
+
+// LoadNodes 下载并解析订阅，返回受支持的全部节点
+// 失败时返回的错误满足 errors.Is(err, ErrSourceUnreachable)
+func LoadNodes(ctx context.Context, source string, timeout time.Duration) ([]models.ProxyNode, error) {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	data, err := config.Load(ctx, config.LoaderConfig{
+		Source:  source,
+		Timeout: int(timeout / time.Second),
+	})
+	if err != nil {
+		return nil, &kindError{ErrSourceUnreachable, fmt.Errorf("failed to load config: %w", err)}
+	}
+
+	nodes, err := parser.Parse(data)
+	if err != nil {
+		return nil, &kindError{ErrSourceUnreachable, fmt.Errorf("failed to parse config: %w", err)}
+	}
+	return nodes, nil
+}
+
+// settings 转换为内部的过滤设置
+func (f Filter) settings() config.FilterSettings {
+	return config.FilterSettings{Names: f.Names, Include: f.Include, Exclude: f.Exclude, Types: f.Types}
+}
+
+// dropDuplicateNames 去掉重名节点，只保留第一个，返回被去掉的节点名 (每个名称一次)
+func dropDuplicateNames(nodes []models.ProxyNode) ([]models.ProxyNode, []string) {
+	seen := make(map[string]bool, len(nodes))
+	unique := make([]models.ProxyNode, 0, len(nodes))
+	var duplicates []string
+	for _, node := range nodes {
+		if !seen[node.Name] {
+			seen[node.Name] = true
+			unique = append(unique, node)
+		} else if !containsString(duplicates, node.Name) {
+			duplicates = append(duplicates, node.Name)
+		}
+	}
+	return unique, duplicates
+}
+
+// key 筛选条件的规范表示，写入检查点供续测时比较；未设置筛选时为空
+func (f Filter) key() string {
+	if len(f.Names) == 0 && f.Include == "" && f.Exclude == "" && len(f.Types) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(f)
+	return string(data)
+}
+
+// Apply 按设置过滤节点
+func (f Filter) Apply(nodes []models.ProxyNode) ([]models.ProxyNode, error) {
+	return f.settings().Filter(nodes)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// noProgress 未设置 Progress 时使用
+type noProgress struct{}
+
+func (noProgress) Start(int, int, []models.NodeTestResult)      {}
+func (noProgress) NodeStarted(int, string)                      {}
+func (noProgress) NodeIdle(int)                                 {}
+func (noProgress) NodeFinished(int, int, models.NodeTestResult) {}
+func (noProgress) Stop()                                        {}
@@ -0,0 +1,430 @@
+package clashtester_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"Clash-tester/internal/checkpoint"
+	"Clash-tester/internal/fakenet"
+	"Clash-tester/internal/tester"
+	"Clash-tester/internal/version"
+	"Clash-tester/pkg/clashtester"
+	"Clash-tester/pkg/models"
+)
+
+// baseOptions 使用假核心的 Runner 参数，节点的表现为 behavior
+func baseOptions(t *testing.T, behavior map[string]fakenet.Node) clashtester.Options {
+	roots, err := fakenet.RootCAs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	retries := 0
+	return clashtester.Options{
+		Workers: 2,
+		NewCore: fakenet.Factory(behavior),
+		Checks: clashtester.CheckOptions{
+			Retries: &retries,
+			Timeout: 5 * time.Second,
+			RootCAs: roots,
+		},
+		Checkpoint: filepath.Join(t.TempDir(), "checkpoint.jsonl"),
+	}
+}
+
+func TestRunnerNodes(t *testing.T) {
+	behavior, nodes := fakenet.SubscriptionBehavior(t), fakenet.SubscriptionNodes()
+	opts := baseOptions(t, behavior)
+	opts.Nodes = nodes
+
+	var mu sync.Mutex
+	var seen []string
+	opts.OnResult = func(r models.NodeTestResult) {
+		mu.Lock()
+		seen = append(seen, r.NodeName)
+		mu.Unlock()
+	}
+
+	runner, err := clashtester.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := runner.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.TotalNodes != 3 || report.TestedNodes != 3 || report.SuccessNodes != 1 {
+		t.Errorf("total/tested/success = %d/%d/%d, want 3/3/1", report.TotalNodes, report.TestedNodes, report.SuccessNodes)
+	}
+	if report.Incomplete {
+		t.Errorf("report is incomplete: %s", report.IncompleteReason)
+	}
+	if len(seen) != 3 {
+		t.Errorf("OnResult called for %v, want 3 nodes", seen)
+	}
+	if len(report.Workers) != 2 {
+		t.Errorf("got %d worker stats, want 2", len(report.Workers))
+	}
+	if report.Summary.OpenAI.Available != 1 || report.Summary.OpenAI.Unavailable != 2 {
+		t.Errorf("openai summary = %+v, want 1 available / 2 unavailable", report.Summary.OpenAI)
+	}
+	if _, err := os.Stat(opts.Checkpoint); !os.IsNotExist(err) {
+		t.Errorf("checkpoint still exists after a complete run (err %v)", err)
+	}
+
+	for _, r := range report.Results {
+		if r.NodeName == fakenet.NodeUS && r.Tests["openai"].Country != "US" {
+			t.Errorf("US openai country = %q", r.Tests["openai"].Country)
+		}
+	}
+}
+
+func TestRunnerSource(t *testing.T) {
+	opts := baseOptions(t, fakenet.SubscriptionBehavior(t))
+	opts.Source = fakenet.WriteSubscription(t, "  - {name: \"Unsupported\", type: snell, server: 1.1.1.4, port: 4}\n")
+	opts.Filter = clashtester.Filter{Exclude: "Down"}
+	runner, err := clashtester.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := runner.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TotalNodes != 2 || report.TestedNodes != 2 {
+		t.Errorf("total/tested = %d/%d, want 2/2", report.TotalNodes, report.TestedNodes)
+	}
+	if got := len(runner.Nodes()); got != 2 {
+		t.Errorf("runner.Nodes() has %d nodes, want 2", got)
+	}
+}
+
+func TestRunnerSwitchFailure(t *testing.T) {
+	behavior, nodes := fakenet.SubscriptionBehavior(t), fakenet.SubscriptionNodes()
+	behavior[fakenet.NodeDown] = fakenet.Node{SelectError: fakenet.ErrSelect}
+	opts := baseOptions(t, behavior)
+	opts.Nodes = nodes
+
+	runner, err := clashtester.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := runner.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 切换失败的节点不计入已测试，但运行本身是完整的
+	if report.TestedNodes != 2 || report.Incomplete {
+		t.Errorf("tested = %d, incomplete = %v, want 2 tested and complete", report.TestedNodes, report.Incomplete)
+	}
+}
+
+func TestRunnerCancelled(t *testing.T) {
+	behavior, nodes := fakenet.SubscriptionBehavior(t), fakenet.SubscriptionNodes()
+	opts := baseOptions(t, behavior)
+	opts.Nodes = nodes
+
+	runner, err := clashtester.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errors.New("interrupted by test"))
+
+	report, err := runner.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Incomplete || report.IncompleteReason != "interrupted by test" {
+		t.Errorf("incomplete = %v (%q), want interrupted by test", report.Incomplete, report.IncompleteReason)
+	}
+	if report.TestedNodes != 0 {
+		t.Errorf("tested %d nodes after cancellation", report.TestedNodes)
+	}
+	if _, err := os.Stat(opts.Checkpoint); err != nil {
+		t.Errorf("checkpoint should be kept for resuming: %v", err)
+	}
+}
+
+func TestRunnerSourceUnreachable(t *testing.T) {
+	runner, err := clashtester.New(clashtester.Options{Source: filepath.Join(t.TempDir(), "missing.yaml")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Run(context.Background()); !errors.Is(err, clashtester.ErrSourceUnreachable) {
+		t.Errorf("err = %v, want ErrSourceUnreachable", err)
+	}
+}
+
+// TestRunnerIsolation 同时运行的两个 Runner 使用各自的临时目录与端口，结束后清理临时目录
+func TestRunnerIsolation(t *testing.T) {
+	behavior, nodes := fakenet.SubscriptionBehavior(t), fakenet.SubscriptionNodes()
+
+	var mu sync.Mutex
+	var configs []clashtester.CoreConfig
+	factory := fakenet.Factory(behavior)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		opts := baseOptions(t, behavior)
+		opts.Nodes = nodes
+		opts.NewCore = func(cfg clashtester.CoreConfig) (clashtester.Core, error) {
+			mu.Lock()
+			configs = append(configs, cfg)
+			mu.Unlock()
+			return factory(cfg)
+		}
+		runner, err := clashtester.New(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := runner.Run(context.Background())
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(configs) != 4 {
+		t.Fatalf("%d cores created, want 4", len(configs))
+	}
+	paths := make(map[string]bool)
+	dirs := make(map[string]bool)
+	ports := make(map[int]bool)
+	for _, cfg := range configs {
+		if paths[cfg.ConfigPath] {
+			t.Errorf("config path %s used twice", cfg.ConfigPath)
+		}
+		paths[cfg.ConfigPath] = true
+		dirs[filepath.Dir(cfg.ConfigPath)] = true
+		for _, port := range []int{cfg.Port, cfg.Port + 1, cfg.APIPort} {
+			if port == 0 || ports[port] {
+				t.Errorf("port %d is zero or used twice", port)
+			}
+			ports[port] = true
+		}
+	}
+	if len(dirs) != 2 {
+		t.Errorf("configs written to %d directories, want one per runner", len(dirs))
+	}
+	for dir := range dirs {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("work directory %s not removed (err %v)", dir, err)
+		}
+	}
+}
+
+func TestRunnerRecorder(t *testing.T) {
+	behavior, nodes := fakenet.SubscriptionBehavior(t), fakenet.SubscriptionNodes()
+	opts := baseOptions(t, behavior)
+	opts.Nodes = nodes[:1]
+	rec := &clashtester.Recorder{}
+	opts.Checks.Recorder = rec
+
+	runner, err := clashtester.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var checks []clashtester.CheckRecord = rec.Checks()
+	if len(checks) == 0 {
+		t.Fatal("no checks recorded")
+	}
+	for _, check := range checks {
+		if len(check.Exchanges) == 0 {
+			t.Errorf("%s: no exchanges recorded", check.Service)
+		}
+	}
+}
+
+func TestRunnerHAR(t *testing.T) {
+	behavior, nodes := fakenet.SubscriptionBehavior(t), fakenet.SubscriptionNodes()
+	opts := baseOptions(t, behavior)
+	opts.Nodes = nodes[:2]
+	opts.Checks.HARDir = t.TempDir()
+
+	runner, err := clashtester.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := runner.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range report.Results {
+		if r.NodeName == fakenet.NodeUS {
+			if r.HARFile != "" {
+				t.Errorf("HAR saved for a fully unlocked node: %s", r.HARFile)
+			}
+			continue
+		}
+		if filepath.Dir(r.HARFile) != opts.Checks.HARDir {
+			t.Fatalf("%s: HAR file = %q, want one in %s", r.NodeName, r.HARFile, opts.Checks.HARDir)
+		}
+		data, err := os.ReadFile(r.HARFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var har tester.HAR
+		if err := json.Unmarshal(data, &har); err != nil {
+			t.Fatal(err)
+		}
+		if har.Log.Creator.Version != version.Version || len(har.Log.Pages) == 0 || len(har.Log.Entries) == 0 {
+			t.Errorf("%s: creator %+v, %d pages, %d entries", r.NodeName, har.Log.Creator, len(har.Log.Pages), len(har.Log.Entries))
+		}
+	}
+}
+
+// TestRunnerResume 只恢复订阅源与筛选条件相同的检查点
+func TestRunnerResume(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   checkpoint.Header
+		restored bool
+	}{
+		{"same run", checkpoint.Header{Source: "", Filter: ""}, true},
+		{"other source", checkpoint.Header{Source: "https://old.example/sub?token=x"}, false},
+		{"other filter", checkpoint.Header{Filter: `{"Names":null,"Include":"US","Exclude":"","Types":null}`}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			behavior, nodes := fakenet.SubscriptionBehavior(t), fakenet.SubscriptionNodes()
+			opts := baseOptions(t, behavior)
+			opts.Nodes = nodes
+			opts.Resume = true
+
+			w, err := checkpoint.Create(opts.Checkpoint, tt.header, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			marker := models.NodeTestResult{NodeName: fakenet.NodeUS, Fingerprint: nodes[0].Fingerprint(), Error: "from checkpoint"}
+			if err := w.Append(marker); err != nil {
+				t.Fatal(err)
+			}
+			w.Close()
+
+			runner, err := clashtester.New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			report, err := runner.Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			restored := false
+			for _, r := range report.Results {
+				if r.Error == marker.Error {
+					restored = true
+				}
+			}
+			if restored != tt.restored || report.TestedNodes != 3 {
+				t.Errorf("restored = %v, tested = %d, want %v and 3", restored, report.TestedNodes, tt.restored)
+			}
+		})
+	}
+}
+
+// TestRunnerDuplicateNames 重名节点只测试第一个，避免其余节点记录第一个节点的结果
+func TestRunnerDuplicateNames(t *testing.T) {
+	behavior, nodes := fakenet.SubscriptionBehavior(t), fakenet.SubscriptionNodes()
+	duplicate := nodes[0]
+	duplicate.Server = "9.9.9.9"
+	opts := baseOptions(t, behavior)
+	opts.Nodes = append(nodes, duplicate)
+	var logs bytes.Buffer
+	opts.Logger = log.New(&logs, "", 0)
+
+	runner, err := clashtester.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := runner.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.TotalNodes != 3 || report.TestedNodes != 3 || len(runner.Nodes()) != 3 {
+		t.Errorf("total/tested/nodes = %d/%d/%d, want 3/3/3", report.TotalNodes, report.TestedNodes, len(runner.Nodes()))
+	}
+	for _, r := range report.Results {
+		if r.Fingerprint == duplicate.Fingerprint() {
+			t.Errorf("duplicate %q was tested", r.NodeName)
+		}
+	}
+	if !strings.Contains(logs.String(), fakenet.NodeUS) {
+		t.Errorf("no warning about the duplicate name, logs: %q", logs.String())
+	}
+}
+
+// TestRunnerGlobalOutage 直连探测全部失败时判定为全局故障，结果不写入增量测试状态
+func TestRunnerGlobalOutage(t *testing.T) {
+	tests := []struct {
+		name     string
+		behavior map[string]fakenet.Node
+		down     bool // 测试前自检即失败；否则在第一个节点完成后断网
+	}{
+		{name: "before", behavior: fakenet.SubscriptionBehavior(t), down: true},
+		{name: "after", behavior: map[string]fakenet.Node{fakenet.NodeUS: {}, fakenet.NodeCN: {}, fakenet.NodeDown: {}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			direct := fakenet.NewTestInternet(t, tester.DefaultEndpoints, fakenet.Unlocked("US"))
+			endpoints := tester.DefaultEndpoints
+			endpoints.Canary = []tester.CanaryTarget{{Name: "openai", URL: direct.DirectURL("/")}}
+
+			opts := baseOptions(t, tt.behavior)
+			opts.Nodes = fakenet.SubscriptionNodes()
+			opts.Checks.Endpoints = &endpoints
+			opts.Canary.Enabled = true
+			opts.StatePath = filepath.Join(t.TempDir(), "state.json")
+			var once sync.Once
+			opts.OnResult = func(models.NodeTestResult) { once.Do(direct.Close) }
+			if tt.down {
+				direct.Close()
+			}
+
+			runner, err := clashtester.New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			report, err := runner.Run(context.Background())
+			if tt.down != errors.Is(err, clashtester.ErrGlobalOutage) {
+				t.Fatalf("err = %v, want ErrGlobalOutage %v", err, tt.down)
+			}
+			if report.Canary == nil || !report.Canary.GlobalOutage {
+				t.Fatalf("canary = %+v, want a global outage", report.Canary)
+			}
+			if tt.down && report.TestedNodes != 0 {
+				t.Errorf("tested %d nodes after the canary failed", report.TestedNodes)
+			}
+			if !tt.down && (report.Canary.Before.Outage || report.Canary.After == nil || !report.Canary.After.Outage) {
+				t.Errorf("canary before/after = %+v / %+v, want only the second check to fail", report.Canary.Before, report.Canary.After)
+			}
+			if _, err := os.Stat(opts.StatePath); !os.IsNotExist(err) {
+				t.Errorf("state saved during a global outage (err %v)", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package clashtester
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"Clash-tester/internal/checkpoint"
+	"Clash-tester/internal/proxy"
+	"Clash-tester/internal/reporter"
+	"Clash-tester/internal/tester"
+	"Clash-tester/pkg/models"
+)
+
+// nodeJob 一个待测试节点及其增量检测计划
+type nodeJob struct {
+	Node     models.ProxyNode
+	Services []string               // 需要检测的服务，nil 表示全部
+	Prev     *models.NodeTestResult // 上次的结果，用于合并仍在有效期内的检测
+	Round    int                    // 当前轮次，从 1 开始
+	Index    int                    // 在本次任务列表中的序号
+}
+
+// jobResult 一个任务 (某节点的某一轮) 的执行结果
+type jobResult struct {
+	Job    nodeJob
+	Result models.NodeTestResult
+	OK     bool // false 表示未能测试 (切换失败、已停止派发或运行被中止)
+}
+
+// worker 一个代理核心及其临时配置
+type worker struct {
+	id         int
+	core       proxy.Core
+	configPath string
+	tested     int // 只由该 Worker 自己的 goroutine 修改
+	restarts   int // 因核心失去响应而重启的次数，同上
+}
+
+// workerPorts 各 Worker 的代理端口与控制端口
+type workerPorts struct {
+	proxy, api []int
+}
+
+// allocatePorts 为 n 个 Worker 分配端口
+// PortBase / APIPortBase 为 0 时选择空闲端口，避免与同一台机器上的其他 Runner 冲突
+func (r *Runner) allocatePorts(n int) (workerPorts, error) {
+	ports := workerPorts{proxy: make([]int, n), api: make([]int, n)}
+	// mihomo 额外使用代理端口 +1 作为 SOCKS 端口
+	span := 1
+	if r.opts.Core == proxy.KindMihomo {
+		span = 2
+	}
+
+	// 分配完之前保持监听，避免同一个端口分给两个 Worker
+	var held []net.Listener
+	defer func() {
+		for _, ln := range held {
+			ln.Close()
+		}
+	}()
+	for i := 0; i < n; i++ {
+		if r.opts.PortBase > 0 {
+			ports.proxy[i] = r.opts.PortBase + i*r.opts.PortStep
+		} else {
+			port, lns, err := freePort(span)
+			if err != nil {
+				return ports, err
+			}
+			ports.proxy[i] = port
+			held = append(held, lns...)
+		}
+		if r.opts.APIPortBase > 0 {
+			ports.api[i] = r.opts.APIPortBase + i
+		} else {
+			port, lns, err := freePort(1)
+			if err != nil {
+				return ports, err
+			}
+			ports.api[i] = port
+			held = append(held, lns...)
+		}
+	}
+	return ports, nil
+}
+
+// freePort 找到 span 个连续的空闲端口，返回第一个端口以及占用这些端口的监听
+func freePort(span int) (int, []net.Listener, error) {
+	for attempt := 0; attempt < 20; attempt++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return 0, nil, err
+		}
+		port := ln.Addr().(*net.TCPAddr).Port
+		lns := []net.Listener{ln}
+		for j := 1; j < span; j++ {
+			next, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port+j))
+			if err != nil {
+				break
+			}
+			lns = append(lns, next)
+		}
+		if len(lns) == span {
+			return port, lns, nil
+		}
+		for _, l := range lns {
+			l.Close()
+		}
+	}
+	return 0, nil, fmt.Errorf("no %d consecutive free ports found", span)
+}
+
+// startWorker 在 workDir 中生成配置并启动第 i 个 Worker 的代理核心
+// 启动失败时仍返回 worker，以便调用方清理临时配置
+func (r *Runner) startWorker(ctx context.Context, i int, nodes []models.ProxyNode, workDir string, ports workerPorts) (*worker, error) {
+	id := i + 1
+	// mihomo 使用 YAML 配置，sing-box 与 xray 使用 JSON
+	ext := "json"
+	if r.opts.Core == proxy.KindMihomo {
+		ext = "yaml"
+	}
+	tempConfig := filepath.Join(workDir, fmt.Sprintf("worker_%d.%s", id, ext))
+
+	port := ports.proxy[i]
+	apiPort := ports.api[i]
+
+	binary := r.opts.CorePath
+	if binary == "" && r.opts.Core == proxy.KindMihomo {
+		binary = r.opts.MihomoPath
+	}
+	newCore := proxy.New
+	if r.opts.NewCore != nil {
+		newCore = r.opts.NewCore
+	}
+	core, err := newCore(proxy.Options{
+		Kind:       r.opts.Core,
+		BinaryPath: binary,
+		ConfigPath: tempConfig,
+		Port:       port,
+		APIPort:    apiPort,
+		Nodes:      nodes,
+	})
+	if err != nil {
+		os.Remove(tempConfig)
+		return nil, fmt.Errorf("failed to generate config for worker %d: %w", id, err)
+	}
+
+	w := &worker{id: id, configPath: tempConfig}
+	if err := core.Start(ctx); err != nil {
+		return w, &kindError{ErrCoreStartup, fmt.Errorf("failed to start worker %d: %w", id, err)}
+	}
+	w.core = core
+
+	r.logf("  ✅ Worker %d started (Port: %d, API: %d)\n", id, port, apiPort)
+	return w, nil
+}
+
+// close 停止代理核心并删除临时配置
+func (w *worker) close() {
+	if w.core != nil {
+		w.core.Stop()
+	}
+	if w.configPath != "" {
+		os.Remove(w.configPath)
+	}
+}
+
+func (w *worker) stats() models.WorkerStats {
+	return models.WorkerStats{ID: w.id, Tested: w.tested, Restarts: w.restarts}
+}
+
+// testWithWorker 在指定 Worker 上切换并测试一个节点
+// 运行被中止时返回 false，此时的结果不完整，应当丢弃
+func (r *Runner) testWithWorker(runCtx context.Context, w *worker, job nodeJob) (models.NodeTestResult, bool) {
+	node := job.Node
+	nodeCtx, cancel := runCtx, context.CancelFunc(func() {})
+	if r.opts.NodeTimeout > 0 {
+		nodeCtx, cancel = context.WithTimeout(runCtx, r.opts.NodeTimeout)
+	}
+	defer cancel()
+
+	// 切换节点；核心失去响应时重启后再试一次
+	err := w.core.SelectNode(nodeCtx, node.Name)
+	if err != nil && runCtx.Err() == nil {
+		restarted, restartErr := proxy.EnsureRunning(nodeCtx, w.core)
+		if restarted {
+			w.restarts++
+		}
+		if restartErr != nil {
+			r.opts.Logger.Printf("⚠️  [Worker %d] Failed to restart core: %v", w.id, restartErr)
+		} else if restarted {
+			r.opts.Logger.Printf("🔁 [Worker %d] Core was unresponsive and has been restarted", w.id)
+			err = w.core.SelectNode(nodeCtx, node.Name)
+		}
+	}
+	if err != nil {
+		if runCtx.Err() == nil {
+			r.opts.Logger.Printf("⚠️  [Worker %d] Failed to switch to %s: %v", w.id, node.Name, err)
+		}
+		return models.NodeTestResult{}, false
+	}
+
+	// 等待生效
+	select {
+	case <-nodeCtx.Done():
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// 测试 (增量模式下只测试过期的服务)
+	testerOpts := r.opts.Checks.testerOptions()
+	testerOpts.Only = job.Services
+	var result models.NodeTestResult
+	if dir := r.opts.Checks.HARDir; dir != "" {
+		var failed []tester.CheckRecord
+		result, failed = tester.TestNodeFailures(nodeCtx, node, w.core.ProxyURL(), testerOpts)
+		path, err := reporter.SaveHAR(dir, result, failed)
+		if err != nil {
+			r.opts.Logger.Printf("⚠️  Failed to save HAR for %s: %v", node.Name, err)
+		}
+		result.HARFile = path
+	} else {
+		result = tester.TestNode(nodeCtx, node, w.core.ProxyURL(), testerOpts)
+	}
+	if runCtx.Err() != nil {
+		return result, false
+	}
+	if nodeCtx.Err() == context.DeadlineExceeded {
+		result.Error = fmt.Sprintf("node time budget (%s) exceeded", r.opts.NodeTimeout)
+	}
+	return result, true
+}
+
+// errCheckpointMismatch 检查点由订阅源或筛选条件不同的运行写入
+var errCheckpointMismatch = errors.New("written for a different source or filter")
+
+// restoreCheckpoint 读取检查点，按指纹拆分出已测试的结果和仍需测试的节点
+// 检查点不存在时视为从头开始；source 或 filter 与检查点不一致时返回 errCheckpointMismatch
+func restoreCheckpoint(path, source, filter string, nodes []models.ProxyNode) ([]models.NodeTestResult, []models.ProxyNode, time.Time, error) {
+	state, err := checkpoint.Load(path)
+	if os.IsNotExist(err) {
+		return nil, nodes, time.Now(), nil
+	}
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+	if state.Header.Source != source || state.Header.Filter != filter {
+		return nil, nodes, time.Now(), fmt.Errorf("%w (source %s, filter %q)",
+			errCheckpointMismatch, reporter.RedactSource(state.Header.Source), state.Header.Filter)
+	}
+
+	var restored []models.NodeTestResult
+	var remaining []models.ProxyNode
+	for _, node := range nodes {
+		if result, ok := state.Results[node.Fingerprint()]; ok {
+			restored = append(restored, result)
+		} else {
+			remaining = append(remaining, node)
+		}
+	}
+
+	testTime := state.Header.TestTime
+	if testTime.IsZero() {
+		testTime = time.Now()
+	}
+
+	return restored, remaining, testTime, nil
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Fingerprint 节点指纹，由节点的全部配置计算得出
+// 名称、地址或任一参数变化都会得到不同的指纹，用于跨运行匹配同一节点
+func (n ProxyNode) Fingerprint() string {
+	// Params 为 map，encoding/json 会按 key 排序输出，结果稳定
+	data, _ := json.Marshal(struct {
+		Name     string                 `json:"name"`
+		Type     string                 `json:"type"`
+		Server   string                 `json:"server"`
+		Port     int                    `json:"port"`
+		Password string                 `json:"password"`
+		UUID     string                 `json:"uuid"`
+		Cipher   string                 `json:"cipher"`
+		Params   map[string]interface{} `json:"params"`
+	}{n.Name, n.Type, n.Server, n.Port, n.Password, n.UUID, n.Cipher, n.Params})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
@@ -14,55 +14,163 @@ type ProxyNode struct {
 	Params   map[string]interface{} `yaml:",inline"` // 其他参数
 }
 
+// Timing 单次请求的连接耗时分解 (毫秒)
+type Timing struct {
+	DNS          int  `json:"dns_ms,omitempty"`
+	Connect      int  `json:"connect_ms"`                 // TCP 建连 (代理模式下为连接本地代理)
+	ProxyConnect int  `json:"proxy_connect_ms,omitempty"` // 代理 CONNECT 隧道建立
+	TLSHandshake int  `json:"tls_handshake_ms,omitempty"`
+	TTFB         int  `json:"ttfb_ms"`          // 请求发送完毕到收到首字节
+	Total        int  `json:"total_ms"`         // 获取连接到收到首字节
+	Reused       bool `json:"reused,omitempty"` // 复用了已有连接，无建连耗时
+}
+
 // ServiceTest 单个服务的测试结果 (AI Services)
 type ServiceTest struct {
-	Service      string `json:"service"` // OpenAI/Gemini/Claude
-	Available    bool   `json:"available"`
-	Country      string `json:"country,omitempty"`
-	Region       string `json:"region,omitempty"`
-	StatusCode   int    `json:"status_code,omitempty"`
-	ResponseTime int    `json:"response_time_ms,omitempty"`
-	Error        string `json:"error,omitempty"`
-	Attempts     int    `json:"attempts"` // 尝试次数
+	Service      string    `json:"service"` // OpenAI/Gemini/Claude
+	Available    bool      `json:"available"`
+	Country      string    `json:"country,omitempty"`
+	Region       string    `json:"region,omitempty"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	ResponseTime int       `json:"response_time_ms,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Attempts     int       `json:"attempts"` // 尝试次数
+	Timing       *Timing   `json:"timing,omitempty"`
+	TestedAt     time.Time `json:"tested_at"` // 检测时间，增量测试时可能早于本次运行
 }
 
 // StreamTest 单个流媒体服务的测试结果
 type StreamTest struct {
-	Service      string `json:"service"` // Netflix, Disney+, etc.
-	Available    bool   `json:"available"`
-	Region       string `json:"region,omitempty"` // US, SG, HK, or "Originals Only"
-	Details      string `json:"details,omitempty"`
-	ResponseTime int    `json:"response_time_ms,omitempty"`
-	Error        string `json:"error,omitempty"`
+	Service      string    `json:"service"` // Netflix, Disney+, etc.
+	Available    bool      `json:"available"`
+	Region       string    `json:"region,omitempty"` // US, SG, HK, or "Originals Only"
+	Details      string    `json:"details,omitempty"`
+	ResponseTime int       `json:"response_time_ms,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Timing       *Timing   `json:"timing,omitempty"`
+	TestedAt     time.Time `json:"tested_at"` // 检测时间，增量测试时可能早于本次运行
+}
+
+// SpeedTest 下载测速结果
+type SpeedTest struct {
+	URL          string  `json:"url"`
+	Available    bool    `json:"available"`
+	DownloadMbps float64 `json:"download_mbps"`
+	TTFB         int     `json:"ttfb_ms"` // 首字节时间
+	Bytes        int64   `json:"bytes"`   // 实际下载字节数
+	Duration     int     `json:"duration_ms"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// ServiceStability 多轮测试中单个服务的稳定性统计
+type ServiceStability struct {
+	Rounds       int     `json:"rounds"`
+	Successes    int     `json:"successes"`
+	SuccessRatio float64 `json:"success_ratio"`
+	LatencyMin   int     `json:"latency_min_ms,omitempty"` // 以下延迟只统计成功的轮次
+	LatencyMax   int     `json:"latency_max_ms,omitempty"`
+	LatencyAvg   int     `json:"latency_avg_ms,omitempty"`
+	LatencyStdev int     `json:"latency_stdev_ms,omitempty"`
+	Class        string  `json:"class"` // stable: 全部成功, flaky: 部分成功, down: 全部失败
 }
 
 // NodeTestResult 单个节点的完整测试结果
 type NodeTestResult struct {
-	NodeName    string                 `json:"node_name"`
-	NodeType    string                 `json:"node_type"`
-	Server      string                 `json:"server"`
-	Tests       map[string]ServiceTest `json:"tests"`        // key: openai/gemini/claude
-	StreamTests map[string]StreamTest  `json:"stream_tests"` // key: netflix/disney/youtube
-	TotalTime   int                    `json:"total_time_ms"`
+	NodeName    string                      `json:"node_name"`
+	NodeType    string                      `json:"node_type"`
+	Server      string                      `json:"server"`
+	Fingerprint string                      `json:"fingerprint,omitempty"` // 节点配置指纹，用于续测匹配
+	Tests       map[string]ServiceTest      `json:"tests"`                 // key: openai/gemini/claude
+	StreamTests map[string]StreamTest       `json:"stream_tests"`          // key: netflix/disney/youtube
+	SpeedTest   *SpeedTest                  `json:"speed_test,omitempty"`
+	Stability   map[string]ServiceStability `json:"stability,omitempty"` // 多轮测试时各服务的稳定性，key 同 Tests/StreamTests
+	TotalTime   int                         `json:"total_time_ms"`
+	TestedAt    time.Time                   `json:"tested_at"`          // 节点最近一次实际测试的时间
+	Error       string                      `json:"error,omitempty"`    // 节点级错误，如超出单节点时间预算
+	HARFile     string                      `json:"har_file,omitempty"` // 失败检测的 HTTP 交互记录 (HAR 1.2)
 }
 
 // TestReport 完整测试报告
 type TestReport struct {
-	TestTime     time.Time        `json:"test_time"`
-	Source       string           `json:"source"` // 订阅URL或文件路径
-	TotalNodes   int              `json:"total_nodes"`
-	TestedNodes  int              `json:"tested_nodes"`
-	SuccessNodes int              `json:"success_nodes"` // 至少一个服务可用
-	Results      []NodeTestResult `json:"results"`
-	Summary      TestSummary      `json:"summary"`
+	TestTime         time.Time        `json:"test_time"`
+	Source           string           `json:"source"`         // 订阅URL或文件路径
+	Core             string           `json:"core,omitempty"` // 使用的代理核心 (mihomo / sing-box / xray)
+	TotalNodes       int              `json:"total_nodes"`
+	TestedNodes      int              `json:"tested_nodes"`
+	SuccessNodes     int              `json:"success_nodes"`          // 至少一个服务可用
+	CachedNodes      int              `json:"cached_nodes,omitempty"` // 结果仍在有效期内、本次未重新测试的节点
+	Incomplete       bool             `json:"incomplete,omitempty"`   // 运行被中断或超时，结果不完整
+	IncompleteReason string           `json:"incomplete_reason,omitempty"`
+	GateFailures     []string         `json:"gate_failures,omitempty"` // 未通过的质量检查，非空时结果不会发布
+	Canary           *CanaryReport    `json:"canary,omitempty"`        // 测试前后的本机连通性自检
+	Workers          []WorkerStats    `json:"workers,omitempty"`
+	Results          []NodeTestResult `json:"results"`
+	Summary          TestSummary      `json:"summary"`
+}
+
+// WorkerStats 单个 Worker (一个代理核心) 的运行统计
+type WorkerStats struct {
+	ID       int `json:"id"`
+	Tested   int `json:"tested"`   // 完成的测试次数 (多轮模式下每轮计一次)
+	Restarts int `json:"restarts"` // 核心失去响应后重启的次数
+}
+
+// CanaryReport 测试前后的本机连通性自检
+type CanaryReport struct {
+	Before       *CanaryResult `json:"before,omitempty"`
+	After        *CanaryResult `json:"after,omitempty"`
+	GlobalOutage bool          `json:"global_outage"` // 节点全部失败是本机或网络故障导致，而非节点本身
+	Reason       string        `json:"reason,omitempty"`
+}
+
+// CanaryResult 一次自检的结果
+type CanaryResult struct {
+	Time      time.Time     `json:"time"`
+	Direct    []CanaryCheck `json:"direct"`              // 直连探测
+	Reference []CanaryCheck `json:"reference,omitempty"` // 通过参考代理探测
+	Outage    bool          `json:"outage"`
+	Reason    string        `json:"reason,omitempty"`
+}
+
+// CanaryCheck 对单个地址的探测结果
+type CanaryCheck struct {
+	Name         string `json:"name"`
+	URL          string `json:"url"`
+	Reachable    bool   `json:"reachable"`
+	StatusCode   int    `json:"status_code,omitempty"`
+	ResponseTime int    `json:"response_time_ms"`
+	Error        string `json:"error,omitempty"`
 }
 
 // TestSummary 测试摘要
 type TestSummary struct {
-	OpenAI    ServiceSummary            `json:"openai"`
-	Gemini    ServiceSummary            `json:"gemini"`
-	Claude    ServiceSummary            `json:"claude"`
-	Streaming map[string]ServiceSummary `json:"streaming"` // Netflix, Disney, etc.
+	OpenAI    ServiceSummary              `json:"openai"`
+	Gemini    ServiceSummary              `json:"gemini"`
+	Claude    ServiceSummary              `json:"claude"`
+	Streaming map[string]ServiceSummary   `json:"streaming"`           // Netflix, Disney, etc.
+	Stability map[string]StabilitySummary `json:"stability,omitempty"` // 多轮测试时各服务的稳定性分布
+}
+
+// Service 按名称 (openai/gemini/claude/netflix/...) 返回单个服务的统计
+func (s TestSummary) Service(name string) (ServiceSummary, bool) {
+	switch name {
+	case "openai":
+		return s.OpenAI, true
+	case "gemini":
+		return s.Gemini, true
+	case "claude":
+		return s.Claude, true
+	}
+	summary, ok := s.Streaming[name]
+	return summary, ok
+}
+
+// StabilitySummary 单个服务在所有节点上的稳定性分布
+type StabilitySummary struct {
+	Stable          int     `json:"stable"`
+	Flaky           int     `json:"flaky"`
+	Down            int     `json:"down"`
+	AvgSuccessRatio float64 `json:"avg_success_ratio"`
 }
 
 // ServiceSummary 单个服务的统计
@@ -70,4 +178,4 @@ type ServiceSummary struct {
 	Available   int      `json:"available_count"`
 	Unavailable int      `json:"unavailable_count"`
 	Countries   []string `json:"countries"` // 可用的国家列表
-}
\ No newline at end of file
+}